@@ -1,17 +1,27 @@
 package main
 
 import (
+	"context"
 	"log"
 	"log/slog"
 	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
 
-	"github.com/joho/godotenv"
 	"github.com/kevin-griley/api/docs"
 
+	"github.com/kevin-griley/api/internal/config"
 	"github.com/kevin-griley/api/internal/data"
 	"github.com/kevin-griley/api/internal/db"
 	"github.com/kevin-griley/api/internal/handlers"
+	"github.com/kevin-griley/api/internal/metrics"
 	"github.com/kevin-griley/api/internal/middleware"
+	"github.com/kevin-griley/api/internal/outbox"
+	"github.com/prometheus/client_golang/prometheus/collectors"
 	httpSwagger "github.com/swaggo/http-swagger"
 )
 
@@ -26,55 +36,315 @@ import (
 // @name						Authorization
 // @description					A valid JWT token with Bearer prefix
 func main() {
-	err := godotenv.Load()
+	// The migrate subcommand takes its own arguments, so only the environment and the
+	// settings file configure it.
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		cfg, err := config.Load(nil)
+		if err != nil {
+			log.Fatal("Invalid configuration: ", err)
+		}
+		if err := runMigrate(context.Background(), cfg, os.Args[2:]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	cfg, err := config.Load(os.Args[1:])
+	if err != nil {
+		log.Fatal("Invalid configuration: ", err)
+	}
+	if err := cfg.Validate(); err != nil {
+		log.Fatal("Invalid configuration: ", err)
+	}
+
+	logger, err := middleware.NewLogger(os.Stdout, cfg.LogFormat, cfg.LogLevel)
 	if err != nil {
-		log.Fatal("Failed to load embedded .env file:", err)
+		log.Fatal(err)
+	}
+	slog.SetDefault(logger)
+
+	if cfg.MigrateOnStart {
+		if err := migrateOnStart(context.Background(), cfg.DatabaseURL); err != nil {
+			log.Fatal("Failed to apply migrations:", err)
+		}
 	}
 
-	listenAddress := ":3000"
-	docs.SwaggerInfo.Host = "localhost:3000"
+	docs.SwaggerInfo.Host = cfg.PublicHost
+
+	shutdownTracing, err := setupTracing(context.Background(), cfg)
+	if err != nil {
+		log.Fatal("Invalid OTEL_EXPORTER_OTLP_ENDPOINT:", err)
+	}
+
+	// ctx is cancelled on SIGTERM or interrupt, which drains the server.
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, os.Interrupt)
+	defer stop()
+
+	var limiter middleware.Limiter = middleware.NewMemoryLimiter()
+	if cfg.RateLimitRedisURL != "" {
+		redisLimiter, err := middleware.NewRedisLimiter(cfg.RateLimitRedisURL, 16)
+		if err != nil {
+			log.Fatal("Invalid RATE_LIMIT_REDIS_URL:", err)
+		}
+		defer redisLimiter.Close()
+		limiter = redisLimiter
+	}
+
+	// Behind a load balancer, clients are told apart by the X-Forwarded-For hops it adds.
+	proxies := middleware.TrustedProxies(cfg.TrustedProxies)
+
+	jwtAuth := middleware.JwtAuthMiddleware(cfg.JWTSecret)
 
 	mux := http.NewServeMux()
 
 	mux.HandleFunc("GET /docs/", httpSwagger.WrapHandler)
-	mux.HandleFunc("POST /login", handlers.HandleApiError(handlers.HandlePostLogin))
-	mux.HandleFunc("POST /user", handlers.HandleApiError(handlers.HandlePostUser))
+	PostLogin := middleware.Chain(
+		handlers.HandleApiError(handlers.HandlePostLogin(cfg.JWTSecret)),
+		middleware.RateLimit(limiter, middleware.PerMinute("login", 10), proxies.KeyByIP),
+	)
+	mux.HandleFunc("POST /login", PostLogin)
+
+	PostUser := middleware.Chain(
+		handlers.HandleApiError(handlers.HandlePostUser),
+		middleware.RateLimit(limiter, middleware.PerHour("signup", 20), proxies.KeyByIP),
+		middleware.Idempotent(data.DefaultIdempotencyTTL),
+	)
+	mux.HandleFunc("POST /user", PostUser)
 
-	GetUserByKeyHandler := middleware.JwtAuthMiddleware(handlers.HandleApiError(handlers.HandleGetUserByKey))
+	GetUserByKeyHandler := jwtAuth(handlers.HandleApiError(handlers.HandleGetUserByKey))
 	mux.HandleFunc("GET /user/me", GetUserByKeyHandler)
 
-	PatchUserHandler := middleware.JwtAuthMiddleware(handlers.HandleApiError(handlers.HandlePatchUser))
+	PatchUserHandler := jwtAuth(handlers.HandleApiError(handlers.HandlePatchUser))
 	mux.HandleFunc("PATCH /user/me", PatchUserHandler)
 
+	DeleteUserHandler := jwtAuth(handlers.HandleApiError(handlers.HandleDeleteUser))
+	mux.HandleFunc("DELETE /user/me", DeleteUserHandler)
+
+	RestoreUser := middleware.Chain(
+		handlers.HandleApiError(handlers.HandleRestoreUser),
+		jwtAuth,
+		middleware.AdminMiddleware,
+	)
+	mux.HandleFunc("POST /user/{id}/restore", RestoreUser)
+
 	PostOrganization := middleware.Chain(
 		handlers.HandleApiError(handlers.HandlePostOrganization),
-		middleware.JwtAuthMiddleware,
+		jwtAuth,
 		middleware.ScopeMiddleware("organization:write"),
+		middleware.Idempotent(data.DefaultIdempotencyTTL),
 	)
 	mux.HandleFunc("POST /organization/{ID}", PostOrganization)
 
+	ListOrganizations := middleware.Chain(
+		handlers.HandleApiError(handlers.HandleListOrganizations),
+		jwtAuth,
+		middleware.ScopeMiddleware("organization:read"),
+	)
+	mux.HandleFunc("GET /organization", ListOrganizations)
+
+	HandleGetOrganizationByID := middleware.Chain(
+		handlers.HandleApiError(handlers.HandleGetOrganizationByID),
+		jwtAuth,
+		middleware.ScopeMiddleware("organization:read"),
+	)
+	mux.HandleFunc("GET /organization/{id}", HandleGetOrganizationByID)
+
 	HandlePatchOrganizationByID := middleware.Chain(
 		handlers.HandleApiError(handlers.HandlePatchOrganizationByID),
-		middleware.JwtAuthMiddleware,
+		jwtAuth,
+		middleware.ScopeMiddleware("organization:write"),
+	)
+	mux.HandleFunc("PATCH /organization/{id}", HandlePatchOrganizationByID)
+
+	HandleDeleteOrganizationByID := middleware.Chain(
+		handlers.HandleApiError(handlers.HandleDeleteOrganizationByID),
+		jwtAuth,
 		middleware.ScopeMiddleware("organization:write"),
 	)
-	mux.HandleFunc("PATCH /organization/{ID}", HandlePatchOrganizationByID)
+	mux.HandleFunc("DELETE /organization/{id}", HandleDeleteOrganizationByID)
+
+	HandleRestoreOrganizationByID := middleware.Chain(
+		handlers.HandleApiError(handlers.HandleRestoreOrganizationByID),
+		jwtAuth,
+		middleware.AdminMiddleware,
+	)
+	mux.HandleFunc("POST /organization/{id}/restore", HandleRestoreOrganizationByID)
+
+	ListUlds := middleware.Chain(
+		handlers.HandleApiError(handlers.HandleListUlds),
+		jwtAuth,
+		middleware.ScopeMiddleware("uld:read"),
+	)
+	mux.HandleFunc("GET /uld", ListUlds)
+
+	HandleGetUldByID := middleware.Chain(
+		handlers.HandleApiError(handlers.HandleGetUldByID),
+		jwtAuth,
+		middleware.ScopeMiddleware("uld:read"),
+	)
+	mux.HandleFunc("GET /uld/{id}", HandleGetUldByID)
+
+	HandleDeleteUldByID := middleware.Chain(
+		handlers.HandleApiError(handlers.HandleDeleteUldByID),
+		jwtAuth,
+		middleware.ScopeMiddleware("uld:write"),
+	)
+	mux.HandleFunc("DELETE /uld/{id}", HandleDeleteUldByID)
 
-	dbConn, err := db.Init()
+	HandlePatchUldStatus := middleware.Chain(
+		handlers.HandleApiError(handlers.HandlePatchUldStatus),
+		jwtAuth,
+		middleware.ScopeMiddleware("uld:write"),
+	)
+	mux.HandleFunc("PATCH /uld/{id}/status", HandlePatchUldStatus)
+
+	HandleRestoreUldByID := middleware.Chain(
+		handlers.HandleApiError(handlers.HandleRestoreUldByID),
+		jwtAuth,
+		middleware.AdminMiddleware,
+	)
+	mux.HandleFunc("POST /uld/{id}/restore", HandleRestoreUldByID)
+
+	ListManifests := middleware.Chain(
+		handlers.HandleApiError(handlers.HandleListManifests),
+		jwtAuth,
+		middleware.ScopeMiddleware("manifest:read"),
+	)
+	mux.HandleFunc("GET /manifest", ListManifests)
+
+	HandleGetManifestByID := middleware.Chain(
+		handlers.HandleApiError(handlers.HandleGetManifestByID),
+		jwtAuth,
+		middleware.ScopeMiddleware("manifest:read"),
+	)
+	mux.HandleFunc("GET /manifest/{id}", HandleGetManifestByID)
+
+	HandleDeleteManifestByID := middleware.Chain(
+		handlers.HandleApiError(handlers.HandleDeleteManifestByID),
+		jwtAuth,
+		middleware.ScopeMiddleware("manifest:write"),
+	)
+	mux.HandleFunc("DELETE /manifest/{id}", HandleDeleteManifestByID)
+
+	HandlePatchManifestStatus := middleware.Chain(
+		handlers.HandleApiError(handlers.HandlePatchManifestStatus),
+		jwtAuth,
+		middleware.ScopeMiddleware("manifest:write"),
+		middleware.Idempotent(data.DefaultIdempotencyTTL),
+	)
+	mux.HandleFunc("PATCH /manifest/{id}/status", HandlePatchManifestStatus)
+
+	HandleRestoreManifestByID := middleware.Chain(
+		handlers.HandleApiError(handlers.HandleRestoreManifestByID),
+		jwtAuth,
+		middleware.AdminMiddleware,
+	)
+	mux.HandleFunc("POST /manifest/{id}/restore", HandleRestoreManifestByID)
+
+	ListAuditLog := middleware.Chain(
+		handlers.HandleApiError(handlers.HandleListAuditLog),
+		jwtAuth,
+		middleware.AdminMiddleware,
+	)
+	mux.HandleFunc("GET /audit", ListAuditLog)
+
+	if cfg.MetricsEnabled {
+		GetMetrics := middleware.Chain(
+			metrics.Handler().ServeHTTP,
+			middleware.MetricsAuthMiddleware(cfg.MetricsToken),
+		)
+		mux.HandleFunc("GET /metrics", GetMetrics)
+	}
+
+	dbConn, replicas, err := db.Init(cfg.DatabaseURL, cfg.DatabaseReplicaURLs...)
 	if err != nil {
 		log.Fatal("Failed to connect to database:", err)
 	}
-	defer db.Close(dbConn)
 
-	store := data.NewStore(dbConn)
+	metrics.Registry.MustRegister(collectors.NewDBStatsCollector(dbConn, "primary"))
+	for i, replica := range replicas {
+		metrics.Registry.MustRegister(collectors.NewDBStatsCollector(replica, "replica"+strconv.Itoa(i)))
+	}
+
+	storeOpts := []data.Option{
+		data.WithReplicas(replicas...),
+		data.WithQueryTimeout(cfg.DBQueryTimeout),
+	}
+	if cfg.PageSizeMax > 0 {
+		storeOpts = append(storeOpts, data.WithMaxPageSize(cfg.PageSizeMax))
+	}
+	if cfg.DBStatementCacheSize >= 0 {
+		storeOpts = append(storeOpts, data.WithStatementCache(cfg.DBStatementCacheSize))
+	}
+
+	store := data.NewStore(dbConn, storeOpts...)
+
+	// The workers run until the server has drained, so that events produced by the last
+	// requests are still dispatched.
+	workerCtx, stopWorkers := context.WithCancel(context.Background())
+	defer stopWorkers()
+
+	var workers sync.WaitGroup
+	runWorker := func(run func(context.Context)) {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			run(workerCtx)
+		}()
+	}
+
+	runWorker(func(ctx context.Context) { store.PurgeDeletedEvery(ctx, cfg.SoftDeleteRetention, time.Hour) })
+	runWorker(func(ctx context.Context) { store.CheckReplicasEvery(ctx, 10*time.Second) })
+	runWorker(func(ctx context.Context) { store.PurgeIdempotencyKeysEvery(ctx, time.Hour) })
+
+	dispatcher := outbox.NewDispatcher(store)
+	dispatcher.Register(outbox.LogSink{})
+	runWorker(dispatcher.Run)
+
+	corsConfig := middleware.CORSConfig{
+		AllowedOrigins:   cfg.CORSAllowedOrigins,
+		AllowedMethods:   cfg.CORSAllowedMethods,
+		AllowedHeaders:   cfg.CORSAllowedHeaders,
+		AllowCredentials: cfg.CORSAllowCredentials,
+		MaxAge:           cfg.CORSMaxAge,
+	}
 
 	finalHandler := middleware.Chain(
 		mux.ServeHTTP,
 		middleware.LoggingMiddleware,
+		middleware.TracingMiddleware,
+		middleware.MetricsMiddleware,
+		middleware.RecoverMiddleware,
+		middleware.CORS(corsConfig, mux),
+		middleware.Compress(middleware.DefaultMinCompressSize),
 		middleware.StoreMiddleware(store),
+		middleware.RouteMiddleware,
 	)
 
-	slog.Info("Application", "Swagger Docs Url", "http://localhost:3000/docs")
+	scheme := "http"
+	if cfg.TLS() {
+		scheme = "https"
+	}
+	slog.Info("Application", "Listen Address", cfg.ListenAddress, "Swagger Docs Url", scheme+"://"+cfg.PublicHost+"/docs")
+
+	serveErr := serve(ctx, newServer(cfg, finalHandler), cfg)
+	stop()
 
-	http.ListenAndServe(listenAddress, finalHandler)
+	// The workers finish their current pass before the pools close, and queued spans,
+	// including those of the last queries, are flushed after them.
+	stopWorkers()
+	workers.Wait()
+	flushCtx, cancelFlush := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	defer cancelFlush()
+	if err := shutdownTracing(flushCtx); err != nil {
+		slog.Error("Application", "Flush Spans", err)
+	}
+	if err := db.Close(append(replicas, dbConn)...); err != nil {
+		slog.Error("Application", "Close Database", err)
+	}
+
+	if serveErr != nil {
+		log.Fatal("Server failed:", serveErr)
+	}
+	slog.Info("Application", "Shutdown", "complete")
 }
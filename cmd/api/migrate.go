@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"path/filepath"
+	"time"
+
+	"github.com/kevin-griley/api/cmd/migrations"
+	"github.com/kevin-griley/api/internal/config"
+	"github.com/kevin-griley/api/internal/db"
+	"github.com/pressly/goose/v3"
+	"github.com/pressly/goose/v3/lock"
+)
+
+const migrateUsage = "usage: api migrate up|down|status|create <name>"
+
+// runMigrate implements the migrate subcommand against cfg.DatabaseURL using the embedded
+// migrations. create writes a new file to cfg.MigrationDir.
+func runMigrate(ctx context.Context, cfg *config.Config, args []string) error {
+	if len(args) == 0 {
+		return errors.New(migrateUsage)
+	}
+
+	if args[0] == "create" {
+		if len(args) != 2 {
+			return errors.New(migrateUsage)
+		}
+		return goose.Create(nil, cfg.MigrationDir, args[1], "sql")
+	}
+
+	provider, closeDB, err := newMigrationProvider(cfg.DatabaseURL)
+	if err != nil {
+		return err
+	}
+	defer closeDB()
+
+	switch args[0] {
+	case "up":
+		results, err := provider.Up(ctx)
+		for _, r := range results {
+			fmt.Printf("Applied %s\n", filepath.Base(r.Source.Path))
+		}
+		if err == nil && len(results) == 0 {
+			fmt.Println("No pending migrations")
+		}
+		return err
+	case "down":
+		r, err := provider.Down(ctx)
+		if errors.Is(err, goose.ErrNoNextVersion) {
+			fmt.Println("No migrations to roll back")
+			return nil
+		}
+		if r != nil && r.Source != nil {
+			fmt.Printf("Rolled back %s\n", filepath.Base(r.Source.Path))
+		}
+		return err
+	case "status":
+		statuses, err := provider.Status(ctx)
+		if err != nil {
+			return err
+		}
+		for _, s := range statuses {
+			appliedAt := "Pending"
+			if s.State == goose.StateApplied {
+				appliedAt = s.AppliedAt.Format(time.RFC3339)
+			}
+			fmt.Printf("%-25s %s\n", appliedAt, filepath.Base(s.Source.Path))
+		}
+		return nil
+	}
+
+	return errors.New(migrateUsage)
+}
+
+// newMigrationProvider returns a goose provider for the embedded migrations. It takes a
+// Postgres advisory lock for each command, so that instances starting at once apply the
+// migrations only once.
+func newMigrationProvider(databaseURL string) (*goose.Provider, func() error, error) {
+	locker, err := lock.NewPostgresSessionLocker()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	dbConn, err := db.CreateDatabase(databaseURL)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	provider, err := goose.NewProvider(goose.DialectPostgres, dbConn, migrations.FS, goose.WithSessionLocker(locker))
+	if err != nil {
+		db.Close(dbConn)
+		return nil, nil, err
+	}
+	// Closing the provider closes dbConn.
+	return provider, provider.Close, nil
+}
+
+// migrateOnStart applies pending migrations before the server starts.
+func migrateOnStart(ctx context.Context, databaseURL string) error {
+	provider, closeDB, err := newMigrationProvider(databaseURL)
+	if err != nil {
+		return err
+	}
+	defer closeDB()
+
+	results, err := provider.Up(ctx)
+	for _, r := range results {
+		slog.Info("Applied migration", "version", r.Source.Version, "file", filepath.Base(r.Source.Path))
+	}
+	return err
+}
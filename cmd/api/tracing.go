@@ -0,0 +1,57 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/kevin-griley/api/internal/config"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+)
+
+// setupTracing installs the W3C trace context propagator, traces requests made with
+// http.DefaultClient so that they pass the trace on, and, when cfg.OTLPEndpoint is set, a
+// tracer provider batching spans to it over OTLP/HTTP. The sampler and extra resource
+// attributes follow the standard OTEL_TRACES_SAMPLER, OTEL_TRACES_SAMPLER_ARG and
+// OTEL_RESOURCE_ATTRIBUTES variables. The returned function flushes queued spans and must be
+// called once requests have drained.
+func setupTracing(ctx context.Context, cfg *config.Config) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+	http.DefaultClient.Transport = otelhttp.NewTransport(http.DefaultTransport)
+
+	if cfg.OTLPEndpoint == "" {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	// OTEL_EXPORTER_OTLP_ENDPOINT is the collector's base URL, to which the traces path is added.
+	endpoint := strings.TrimSuffix(cfg.OTLPEndpoint, "/") + "/v1/traces"
+	exporter, err := otlptracehttp.New(ctx, otlptracehttp.WithEndpointURL(endpoint))
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := resource.New(ctx,
+		resource.WithFromEnv(),
+		resource.WithTelemetrySDK(),
+		resource.WithHost(),
+		resource.WithProcessRuntimeName(),
+		resource.WithProcessRuntimeVersion(),
+		resource.WithAttributes(semconv.ServiceName(cfg.ServiceName)),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+	)
+	otel.SetTracerProvider(provider)
+	return provider.Shutdown, nil
+}
@@ -0,0 +1,22 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE "users" ADD COLUMN "deleted_at" TIMESTAMPTZ;
+ALTER TABLE "organizations" ADD COLUMN "deleted_at" TIMESTAMPTZ;
+ALTER TABLE "uld_inventories" ADD COLUMN "deleted_at" TIMESTAMPTZ;
+ALTER TABLE "delivery_manifests" ADD COLUMN "deleted_at" TIMESTAMPTZ;
+
+UPDATE "users" SET "deleted_at" = "updated_at" WHERE "is_deleted";
+
+CREATE INDEX IF NOT EXISTS "idx_users_deleted_at" ON "users" ("deleted_at") WHERE "deleted_at" IS NOT NULL;
+CREATE INDEX IF NOT EXISTS "idx_organizations_deleted_at" ON "organizations" ("deleted_at") WHERE "deleted_at" IS NOT NULL;
+CREATE INDEX IF NOT EXISTS "idx_uld_inventories_deleted_at" ON "uld_inventories" ("deleted_at") WHERE "deleted_at" IS NOT NULL;
+CREATE INDEX IF NOT EXISTS "idx_delivery_manifests_deleted_at" ON "delivery_manifests" ("deleted_at") WHERE "deleted_at" IS NOT NULL;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE "users" DROP COLUMN IF EXISTS "deleted_at";
+ALTER TABLE "organizations" DROP COLUMN IF EXISTS "deleted_at";
+ALTER TABLE "uld_inventories" DROP COLUMN IF EXISTS "deleted_at";
+ALTER TABLE "delivery_manifests" DROP COLUMN IF EXISTS "deleted_at";
+-- +goose StatementEnd
@@ -0,0 +1,21 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS "audit_log" (
+    "id" UUID PRIMARY KEY,
+    "created_at" TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    "actor_id" UUID,
+    "request_id" TEXT,
+    "table_name" TEXT NOT NULL,
+    "row_id" UUID NOT NULL,
+    "action" TEXT NOT NULL CHECK ("action" IN ('insert', 'update', 'delete', 'purge')),
+    "diff" JSONB NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS "idx_audit_log_row" ON "audit_log" ("table_name", "row_id");
+CREATE INDEX IF NOT EXISTS "idx_audit_log_actor_id" ON "audit_log" ("actor_id");
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS "audit_log";
+-- +goose StatementEnd
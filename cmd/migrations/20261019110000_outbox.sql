@@ -0,0 +1,22 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS "outbox" (
+    "id" UUID PRIMARY KEY,
+    "created_at" TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    "aggregate_type" TEXT NOT NULL,
+    "aggregate_id" UUID NOT NULL,
+    "event_type" TEXT NOT NULL,
+    "payload" JSONB NOT NULL,
+    "attempts" INT NOT NULL DEFAULT 0,
+    "next_attempt_at" TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    "published_at" TIMESTAMPTZ,
+    "last_error" TEXT
+);
+
+CREATE INDEX IF NOT EXISTS "idx_outbox_pending" ON "outbox" ("aggregate_id", "id") WHERE "published_at" IS NULL;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS "outbox";
+-- +goose StatementEnd
@@ -0,0 +1,9 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE "idempotency_keys" ADD COLUMN IF NOT EXISTS "locked_until" TIMESTAMPTZ;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE "idempotency_keys" DROP COLUMN IF EXISTS "locked_until";
+-- +goose StatementEnd
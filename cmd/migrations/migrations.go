@@ -0,0 +1,8 @@
+package migrations
+
+import "embed"
+
+// FS holds the goose SQL migrations, embedded so the api binary can apply them.
+//
+//go:embed *.sql
+var FS embed.FS
@@ -0,0 +1,78 @@
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"io/fs"
+	"os"
+	"testing"
+
+	_ "github.com/lib/pq"
+	"github.com/pressly/goose/v3"
+	"github.com/pressly/goose/v3/lock"
+)
+
+func TestSources(t *testing.T) {
+	// Opening does not connect, and goose reads the sources without touching the database.
+	dbConn, err := sql.Open("postgres", "postgres://localhost/unused")
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	provider, err := goose.NewProvider(goose.DialectPostgres, dbConn, FS)
+	if err != nil {
+		t.Fatalf("Failed to create provider: %v", err)
+	}
+	defer provider.Close()
+
+	files, err := fs.Glob(FS, "*.sql")
+	if err != nil {
+		t.Fatalf("Failed to list migrations: %v", err)
+	}
+	if sources := provider.ListSources(); len(sources) != len(files) || len(files) == 0 {
+		t.Errorf("Expected every one of %d files to be a migration, got %d", len(files), len(sources))
+	}
+}
+
+func TestUpDown(t *testing.T) {
+	if os.Getenv("DATABASE_URL") == "" {
+		t.Skip("DATABASE_URL not set")
+	}
+
+	dbConn, err := sql.Open("postgres", os.Getenv("DATABASE_URL"))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	locker, err := lock.NewPostgresSessionLocker()
+	if err != nil {
+		t.Fatalf("Failed to create locker: %v", err)
+	}
+	provider, err := goose.NewProvider(goose.DialectPostgres, dbConn, FS, goose.WithSessionLocker(locker))
+	if err != nil {
+		t.Fatalf("Failed to create provider: %v", err)
+	}
+	defer provider.Close()
+
+	ctx := context.Background()
+	if _, err := provider.Up(ctx); err != nil {
+		t.Fatalf("Failed to migrate up: %v", err)
+	}
+
+	// The latest migration must roll back and apply again cleanly.
+	if _, err := provider.Down(ctx); err != nil {
+		t.Fatalf("Failed to migrate down: %v", err)
+	}
+	results, err := provider.Up(ctx)
+	if err != nil || len(results) != 1 {
+		t.Fatalf("Expected the rolled back migration to be applied again, got %v, %v", results, err)
+	}
+
+	statuses, err := provider.Status(ctx)
+	if err != nil {
+		t.Fatalf("Failed to get status: %v", err)
+	}
+	for _, s := range statuses {
+		if s.State != goose.StateApplied {
+			t.Errorf("Expected %s to be applied", s.Source.Path)
+		}
+	}
+}
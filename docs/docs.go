@@ -345,9 +345,9 @@ const docTemplate = `{
         "data.OrganizationType": {
             "type": "string",
             "enum": [
-                "Airline",
-                "Carrier",
-                "Warehouse"
+                "airline",
+                "carrier",
+                "warehouse"
             ],
             "x-enum-varnames": [
                 "Airline",
@@ -0,0 +1,339 @@
+// Package config loads the API's settings from defaults, a .env style file, the
+// environment and command-line flags, in increasing order of precedence.
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"net/netip"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/joho/godotenv"
+)
+
+// DefaultFile is read for settings when present and no other file is named.
+const DefaultFile = ".env"
+
+// Config holds every setting of the API. Each field is read from the environment variable
+// named in settings, or from the flag of the same name in lower case with dashes, such as
+// -db-query-timeout for DB_QUERY_TIMEOUT.
+type Config struct {
+	// ListenAddress is the address the HTTP server listens on.
+	ListenAddress string
+	// PublicHost is the host clients reach the API at, as shown in the Swagger docs.
+	PublicHost string
+
+	ReadTimeout       time.Duration
+	ReadHeaderTimeout time.Duration
+	WriteTimeout      time.Duration
+	IdleTimeout       time.Duration
+	// ShutdownTimeout bounds how long in-flight requests may take to finish on shutdown.
+	ShutdownTimeout time.Duration
+	// TLSCertFile and TLSKeyFile serve HTTPS when both are set.
+	TLSCertFile string
+	TLSKeyFile  string
+
+	// JWTSecret signs and validates the tokens issued at login.
+	JWTSecret string
+
+	DatabaseURL         string
+	DatabaseReplicaURLs []string
+	// DBQueryTimeout bounds every query; zero disables it.
+	DBQueryTimeout time.Duration
+	// DBStatementCacheSize is the number of prepared statements cached per pool. A negative
+	// size leaves the cache off and zero picks the data layer's default size.
+	DBStatementCacheSize int
+	// PageSizeMax is the largest page list endpoints return; zero keeps the data layer's default.
+	PageSizeMax         int
+	SoftDeleteRetention time.Duration
+	MigrationDir        string
+	// MigrateOnStart applies pending migrations before serving.
+	MigrateOnStart bool
+
+	// RateLimitRedisURL shares rate limits between instances; buckets are kept in process when empty.
+	RateLimitRedisURL string
+	// TrustedProxies are the load balancers whose X-Forwarded-For hops are believed when
+	// working out a client's address. With none, clients are known by the connection's address.
+	TrustedProxies []netip.Prefix
+
+	LogFormat string
+	LogLevel  string
+
+	MetricsEnabled bool
+	MetricsToken   string
+
+	// OTLPEndpoint is the collector spans are exported to; tracing is off when empty.
+	OTLPEndpoint string
+	ServiceName  string
+
+	CORSAllowedOrigins   []string
+	CORSAllowedMethods   []string
+	CORSAllowedHeaders   []string
+	CORSAllowCredentials bool
+	CORSMaxAge           time.Duration
+}
+
+// Default returns the settings used when nothing overrides them.
+func Default() *Config {
+	return &Config{
+		ListenAddress:        ":3000",
+		PublicHost:           "localhost:3000",
+		ReadTimeout:          15 * time.Second,
+		ReadHeaderTimeout:    5 * time.Second,
+		WriteTimeout:         30 * time.Second,
+		IdleTimeout:          120 * time.Second,
+		ShutdownTimeout:      30 * time.Second,
+		DBQueryTimeout:       5 * time.Second,
+		DBStatementCacheSize: -1,
+		SoftDeleteRetention:  30 * 24 * time.Hour,
+		MigrationDir:         "cmd/migrations",
+		LogFormat:            "text",
+		LogLevel:             "info",
+		ServiceName:          "api",
+	}
+}
+
+// TLS reports whether the server is configured to serve HTTPS.
+func (c *Config) TLS() bool {
+	return c.TLSCertFile != "" && c.TLSKeyFile != ""
+}
+
+// Load reads the settings from the environment and the flags in args. A -config flag, or
+// the CONFIG_FILE variable, names a file of KEY=value lines read beneath the environment;
+// DefaultFile is read instead when present. Load does not validate the result.
+func Load(args []string) (*Config, error) {
+	return load(args, os.LookupEnv)
+}
+
+func load(args []string, lookupEnv func(string) (string, bool)) (*Config, error) {
+	cfg := Default()
+	settings := cfg.settings()
+
+	flags := flag.NewFlagSet("api", flag.ContinueOnError)
+	configFile := flags.String("config", "", "file of KEY=value settings (default "+DefaultFile+" when present)")
+	flagged := map[string]string{}
+	for _, s := range settings {
+		name := s.name
+		record := func(v string) error {
+			flagged[name] = v
+			return nil
+		}
+		if s.isBool {
+			flags.BoolFunc(flagName(name), s.usage, record)
+		} else {
+			flags.Func(flagName(name), s.usage, record)
+		}
+	}
+	if err := flags.Parse(args); err != nil {
+		return nil, err
+	}
+	if flags.NArg() > 0 {
+		return nil, fmt.Errorf("unexpected argument %q", flags.Arg(0))
+	}
+
+	path := *configFile
+	if path == "" {
+		path, _ = lookupEnv("CONFIG_FILE")
+	}
+	file, err := readFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var errs []error
+	for _, s := range settings {
+		v, ok := flagged[s.name]
+		if !ok {
+			v, ok = lookupEnv(s.name)
+		}
+		if !ok || v == "" {
+			v, ok = file[s.name]
+		}
+		// Empty values, such as the blanks in .env.example, leave the default in place.
+		if !ok || v == "" {
+			continue
+		}
+		if err := s.set(strings.TrimSpace(v)); err != nil {
+			errs = append(errs, fmt.Errorf("invalid %s: %w", s.name, err))
+		}
+	}
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	return cfg, nil
+}
+
+// readFile reads the settings file at path, or DefaultFile when path is empty. Only a
+// missing DefaultFile is allowed; a file that was asked for must exist.
+func readFile(path string) (map[string]string, error) {
+	if path != "" {
+		return godotenv.Read(path)
+	}
+	values, err := godotenv.Read(DefaultFile)
+	if errors.Is(err, fs.ErrNotExist) {
+		return map[string]string{}, nil
+	}
+	return values, err
+}
+
+// Validate reports every setting the server cannot start with.
+func (c *Config) Validate() error {
+	var errs []error
+	switch c.JWTSecret {
+	case "":
+		errs = append(errs, errors.New("JWT_SECRET must be set"))
+	case "secret":
+		errs = append(errs, errors.New("JWT_SECRET must not be the example value \"secret\""))
+	}
+	if c.DatabaseURL == "" {
+		errs = append(errs, errors.New("DATABASE_URL must be set"))
+	}
+	if (c.TLSCertFile == "") != (c.TLSKeyFile == "") {
+		errs = append(errs, errors.New("TLS_CERT_FILE and TLS_KEY_FILE must be set together"))
+	}
+	if c.PageSizeMax < 0 {
+		errs = append(errs, errors.New("PAGE_SIZE_MAX must not be negative"))
+	}
+	if c.CORSAllowCredentials && slices.Contains(c.CORSAllowedOrigins, "*") {
+		errs = append(errs, errors.New("CORS_ALLOWED_ORIGINS must not be * when CORS_ALLOW_CREDENTIALS is true"))
+	}
+	if c.ShutdownTimeout <= 0 {
+		errs = append(errs, errors.New("SHUTDOWN_TIMEOUT must be positive"))
+	}
+	return errors.Join(errs...)
+}
+
+// setting binds an environment variable, and the flag named after it, to a field.
+type setting struct {
+	name   string
+	usage  string
+	isBool bool
+	set    func(string) error
+}
+
+func (c *Config) settings() []setting {
+	return []setting{
+		stringSetting("LISTEN_ADDRESS", "address the HTTP server listens on", &c.ListenAddress),
+		stringSetting("PUBLIC_HOST", "host clients reach the API at, shown in the Swagger docs", &c.PublicHost),
+		durationSetting("HTTP_READ_TIMEOUT", "longest time to read a request", &c.ReadTimeout),
+		durationSetting("HTTP_READ_HEADER_TIMEOUT", "longest time to read request headers", &c.ReadHeaderTimeout),
+		durationSetting("HTTP_WRITE_TIMEOUT", "longest time to write a response", &c.WriteTimeout),
+		durationSetting("HTTP_IDLE_TIMEOUT", "longest time a keep-alive connection stays idle", &c.IdleTimeout),
+		durationSetting("SHUTDOWN_TIMEOUT", "longest time in-flight requests may run after SIGTERM", &c.ShutdownTimeout),
+		stringSetting("TLS_CERT_FILE", "certificate to serve HTTPS with", &c.TLSCertFile),
+		stringSetting("TLS_KEY_FILE", "private key of TLS_CERT_FILE", &c.TLSKeyFile),
+		stringSetting("JWT_SECRET", "key tokens are signed with", &c.JWTSecret),
+		stringSetting("DATABASE_URL", "primary database connection string", &c.DatabaseURL),
+		listSetting("DATABASE_REPLICA_URLS", "comma-separated read replica connection strings", &c.DatabaseReplicaURLs),
+		durationSetting("DB_QUERY_TIMEOUT", "per-query timeout, 0 to disable", &c.DBQueryTimeout),
+		intSetting("DB_STATEMENT_CACHE_SIZE", "prepared statements cached per pool, 0 for the default size", &c.DBStatementCacheSize),
+		intSetting("PAGE_SIZE_MAX", "largest page list endpoints return", &c.PageSizeMax),
+		durationSetting("SOFT_DELETE_RETENTION", "how long soft-deleted rows are kept", &c.SoftDeleteRetention),
+		stringSetting("GOOSE_MIGRATION_DIR", "directory migrate create writes to", &c.MigrationDir),
+		boolSetting("MIGRATE", "apply pending migrations before serving", &c.MigrateOnStart),
+		stringSetting("RATE_LIMIT_REDIS_URL", "Redis shared by every instance for rate limiting", &c.RateLimitRedisURL),
+		prefixListSetting("TRUSTED_PROXIES", "comma-separated addresses or CIDR ranges of trusted reverse proxies", &c.TrustedProxies),
+		stringSetting("LOG_FORMAT", "log output, text or json", &c.LogFormat),
+		stringSetting("LOG_LEVEL", "debug, info, warn or error", &c.LogLevel),
+		boolSetting("METRICS_ENABLED", "serve Prometheus metrics on GET /metrics", &c.MetricsEnabled),
+		stringSetting("METRICS_TOKEN", "bearer token scrapes must send", &c.MetricsToken),
+		stringSetting("OTEL_EXPORTER_OTLP_ENDPOINT", "OTLP/HTTP collector endpoint", &c.OTLPEndpoint),
+		stringSetting("OTEL_SERVICE_NAME", "service name spans are reported under", &c.ServiceName),
+		listSetting("CORS_ALLOWED_ORIGINS", "comma-separated origins browsers may call from", &c.CORSAllowedOrigins),
+		listSetting("CORS_ALLOWED_METHODS", "comma-separated methods allowed across origins", &c.CORSAllowedMethods),
+		listSetting("CORS_ALLOWED_HEADERS", "comma-separated request headers allowed across origins", &c.CORSAllowedHeaders),
+		boolSetting("CORS_ALLOW_CREDENTIALS", "allow credentialed cross-origin requests", &c.CORSAllowCredentials),
+		durationSetting("CORS_MAX_AGE", "how long browsers may cache a preflight", &c.CORSMaxAge),
+	}
+}
+
+// flagName turns an environment variable name into its flag, e.g. LOG_LEVEL to log-level.
+func flagName(name string) string {
+	return strings.ToLower(strings.ReplaceAll(name, "_", "-"))
+}
+
+func stringSetting(name, usage string, dst *string) setting {
+	return setting{name: name, usage: usage, set: func(v string) error {
+		*dst = v
+		return nil
+	}}
+}
+
+func durationSetting(name, usage string, dst *time.Duration) setting {
+	return setting{name: name, usage: usage, set: func(v string) error {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return err
+		}
+		*dst = d
+		return nil
+	}}
+}
+
+func intSetting(name, usage string, dst *int) setting {
+	return setting{name: name, usage: usage, set: func(v string) error {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return err
+		}
+		*dst = n
+		return nil
+	}}
+}
+
+func boolSetting(name, usage string, dst *bool) setting {
+	return setting{name: name, usage: usage, isBool: true, set: func(v string) error {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return err
+		}
+		*dst = b
+		return nil
+	}}
+}
+
+// listSetting splits a comma-separated value, dropping blank entries.
+func listSetting(name, usage string, dst *[]string) setting {
+	return setting{name: name, usage: usage, set: func(v string) error {
+		var items []string
+		for _, item := range strings.Split(v, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		*dst = items
+		return nil
+	}}
+}
+
+// prefixListSetting parses a comma-separated list of addresses and CIDR ranges, dropping
+// blank entries. An address stands for the range holding only itself.
+func prefixListSetting(name, usage string, dst *[]netip.Prefix) setting {
+	return setting{name: name, usage: usage, set: func(v string) error {
+		var prefixes []netip.Prefix
+		for _, item := range strings.Split(v, ",") {
+			item = strings.TrimSpace(item)
+			if item == "" {
+				continue
+			}
+			if addr, err := netip.ParseAddr(item); err == nil {
+				addr = addr.Unmap()
+				prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+				continue
+			}
+			prefix, err := netip.ParsePrefix(item)
+			if err != nil {
+				return err
+			}
+			prefixes = append(prefixes, prefix.Masked())
+		}
+		*dst = prefixes
+		return nil
+	}}
+}
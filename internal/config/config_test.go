@@ -0,0 +1,126 @@
+package config
+
+import (
+	"net/netip"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLoadPrecedence(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "settings.env")
+	contents := "JWT_SECRET='from-file'\nLOG_LEVEL='warn'\nPAGE_SIZE_MAX='50'\nDB_QUERY_TIMEOUT=''\n"
+	if err := os.WriteFile(file, []byte(contents), 0o600); err != nil {
+		t.Fatalf("Failed to write settings file: %v", err)
+	}
+
+	env := map[string]string{
+		"CONFIG_FILE":          file,
+		"LOG_LEVEL":            "error",
+		"CORS_ALLOWED_ORIGINS": "http://localhost:8081, ,https://*.example.com",
+		"METRICS_ENABLED":      "true",
+		"TRUSTED_PROXIES":      "10.0.0.0/8, 192.168.1.7,::1",
+	}
+	lookupEnv := func(name string) (string, bool) {
+		v, ok := env[name]
+		return v, ok
+	}
+
+	cfg, err := load([]string{"-log-level=debug", "-migrate", "-shutdown-timeout", "5s"}, lookupEnv)
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+
+	if cfg.JWTSecret != "from-file" || cfg.PageSizeMax != 50 {
+		t.Errorf("Expected file values, got %q and %d", cfg.JWTSecret, cfg.PageSizeMax)
+	}
+	if cfg.LogLevel != "debug" {
+		t.Errorf("Expected the flag to win over the environment and file, got %q", cfg.LogLevel)
+	}
+	if !cfg.MigrateOnStart || !cfg.MetricsEnabled || cfg.ShutdownTimeout != 5*time.Second {
+		t.Errorf("Unexpected flag or environment values: %+v", cfg)
+	}
+	if expected := []string{"http://localhost:8081", "https://*.example.com"}; !reflect.DeepEqual(cfg.CORSAllowedOrigins, expected) {
+		t.Errorf("Expected origins %v, got %v", expected, cfg.CORSAllowedOrigins)
+	}
+	proxies := []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8"), netip.MustParsePrefix("192.168.1.7/32"), netip.MustParsePrefix("::1/128")}
+	if !reflect.DeepEqual(cfg.TrustedProxies, proxies) {
+		t.Errorf("Expected trusted proxies %v, got %v", proxies, cfg.TrustedProxies)
+	}
+	if cfg.DBQueryTimeout != Default().DBQueryTimeout || cfg.ListenAddress != ":3000" {
+		t.Errorf("Expected blank and unset values to keep their defaults, got %v and %q", cfg.DBQueryTimeout, cfg.ListenAddress)
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	noEnv := func(string) (string, bool) { return "", false }
+
+	testCases := []struct {
+		name     string
+		args     []string
+		expected string
+	}{
+		{"Invalid Duration", []string{"-db-query-timeout=soon"}, "invalid DB_QUERY_TIMEOUT"},
+		{"Invalid Number", []string{"-page-size-max=many"}, "invalid PAGE_SIZE_MAX"},
+		{"Unknown Flag", []string{"-verbose"}, "flag provided but not defined"},
+		{"Missing File", []string{"-config=" + filepath.Join(t.TempDir(), "missing.env")}, "no such file"},
+		{"Extra Argument", []string{"serve"}, "unexpected argument"},
+		{"Invalid Proxy", []string{"-trusted-proxies=10.0.0.0/8,proxy.internal"}, "invalid TRUSTED_PROXIES"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := load(tc.args, noEnv)
+			if err == nil || !strings.Contains(err.Error(), tc.expected) {
+				t.Errorf("Expected error containing %q, got %v", tc.expected, err)
+			}
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	valid := func() *Config {
+		cfg := Default()
+		cfg.JWTSecret = "a-long-random-key"
+		cfg.DatabaseURL = "postgres://localhost/api"
+		return cfg
+	}
+
+	testCases := []struct {
+		name     string
+		modify   func(*Config)
+		expected string
+	}{
+		{"Valid", func(*Config) {}, ""},
+		{"Empty JWT Secret", func(c *Config) { c.JWTSecret = "" }, "JWT_SECRET must be set"},
+		{"Example JWT Secret", func(c *Config) { c.JWTSecret = "secret" }, "JWT_SECRET must not be"},
+		{"Missing Database", func(c *Config) { c.DatabaseURL = "" }, "DATABASE_URL must be set"},
+		{"Certificate Without Key", func(c *Config) { c.TLSCertFile = "cert.pem" }, "TLS_CERT_FILE and TLS_KEY_FILE"},
+		{"Negative Page Size", func(c *Config) { c.PageSizeMax = -1 }, "PAGE_SIZE_MAX"},
+		{"Any Origin With Credentials", func(c *Config) {
+			c.CORSAllowedOrigins = []string{"http://localhost:8081", "*"}
+			c.CORSAllowCredentials = true
+		}, "CORS_ALLOWED_ORIGINS must not be *"},
+		{"Any Origin Without Credentials", func(c *Config) { c.CORSAllowedOrigins = []string{"*"} }, ""},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := valid()
+			tc.modify(cfg)
+			err := cfg.Validate()
+			if tc.expected == "" {
+				if err != nil {
+					t.Errorf("Expected no error, got %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tc.expected) {
+				t.Errorf("Expected error containing %q, got %v", tc.expected, err)
+			}
+		})
+	}
+}
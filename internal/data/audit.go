@@ -0,0 +1,301 @@
+package data
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	contextKeyActor     ContextKey = "contextKeyActor"
+	contextKeyRequestID ContextKey = "contextKeyRequestID"
+)
+
+// WithActor records the user responsible for the mutations made with ctx in the audit log.
+func WithActor(ctx context.Context, actorID uuid.UUID) context.Context {
+	return context.WithValue(ctx, contextKeyActor, actorID)
+}
+
+// WithRequestID records the request the mutations made with ctx belong to in the audit log.
+func WithRequestID(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, contextKeyRequestID, requestID)
+}
+
+type AuditAction string
+
+const (
+	AuditInsert AuditAction = "insert"
+	AuditUpdate AuditAction = "update"
+	AuditDelete AuditAction = "delete"
+	AuditPurge  AuditAction = "purge"
+)
+
+// auditRedacted replaces the values of columns that must never be copied into the audit log.
+var auditRedacted = map[string]json.RawMessage{
+	"hashed_password": json.RawMessage(`"[redacted]"`),
+}
+
+// AuditChange holds a column's JSON value before and after a mutation. Before is null
+// for inserts and After is null for purges.
+type AuditChange struct {
+	Before json.RawMessage `json:"before"`
+	After  json.RawMessage `json:"after"`
+}
+
+// AuditDiff maps each changed column to its change.
+type AuditDiff map[string]AuditChange
+
+func (d AuditDiff) Value() (driver.Value, error) {
+	return json.Marshal(d)
+}
+
+func (d *AuditDiff) Scan(src any) error {
+	switch v := src.(type) {
+	case []byte:
+		return json.Unmarshal(v, d)
+	case string:
+		return json.Unmarshal([]byte(v), d)
+	case nil:
+		*d = nil
+		return nil
+	}
+	return fmt.Errorf("cannot scan %T into AuditDiff", src)
+}
+
+type AuditEntry struct {
+	ID        uuid.UUID   `json:"id" db:"id"`
+	CreatedAt time.Time   `json:"created_at" db:"created_at"`
+	ActorID   *uuid.UUID  `json:"actor_id" db:"actor_id"`
+	RequestID string      `json:"request_id" db:"request_id"`
+	TableName string      `json:"table_name" db:"table_name"`
+	RowID     uuid.UUID   `json:"row_id" db:"row_id"`
+	Action    AuditAction `json:"action" db:"action"`
+	Diff      AuditDiff   `json:"diff" db:"diff"`
+}
+
+// auditDiff compares every column of before and after and returns the ones that changed.
+// Either side may be nil.
+func auditDiff[T any](before, after *T) (AuditDiff, error) {
+	diff := AuditDiff{}
+	for _, column := range columnsOf[T]() {
+		var change AuditChange
+		if before != nil {
+			b, err := json.Marshal(columnValue(before, column))
+			if err != nil {
+				return nil, err
+			}
+			change.Before = b
+		}
+		if after != nil {
+			a, err := json.Marshal(columnValue(after, column))
+			if err != nil {
+				return nil, err
+			}
+			change.After = a
+		}
+		if bytes.Equal(change.Before, change.After) || isNullChange(change) {
+			continue
+		}
+
+		if redacted, ok := auditRedacted[column]; ok {
+			if change.Before != nil {
+				change.Before = redacted
+			}
+			if change.After != nil {
+				change.After = redacted
+			}
+		}
+		diff[column] = change
+	}
+	return diff, nil
+}
+
+// isNullChange reports whether an insert or purge change only involves a NULL value.
+func isNullChange(c AuditChange) bool {
+	null := []byte("null")
+	return (c.Before == nil && bytes.Equal(c.After, null)) || (c.After == nil && bytes.Equal(c.Before, null))
+}
+
+func newAuditEntry[T any](ctx context.Context, tableName string, action AuditAction, rowID uuid.UUID, before, after *T) (*AuditEntry, error) {
+	diff, err := auditDiff(before, after)
+	if err != nil {
+		return nil, err
+	}
+
+	id, err := uuid.NewV7()
+	if err != nil {
+		return nil, err
+	}
+
+	entry := &AuditEntry{
+		ID:        id,
+		CreatedAt: time.Now().UTC(),
+		TableName: tableName,
+		RowID:     rowID,
+		Action:    action,
+		Diff:      diff,
+	}
+	if actorID, ok := ctx.Value(contextKeyActor).(uuid.UUID); ok {
+		entry.ActorID = &actorID
+	}
+	entry.RequestID, _ = ctx.Value(contextKeyRequestID).(string)
+
+	return entry, nil
+}
+
+// writeAudit inserts the audit entry for a mutation of rowID on db, which must be the
+// transaction the mutation ran in.
+func writeAudit[T any](ctx context.Context, db *DB, tableName string, action AuditAction, rowID uuid.UUID, before, after *T) error {
+	entry, err := newAuditEntry(ctx, tableName, action, rowID, before, after)
+	if err != nil {
+		return err
+	}
+
+	data := map[string]any{
+		"id":         entry.ID,
+		"created_at": entry.CreatedAt,
+		"actor_id":   entry.ActorID,
+		"request_id": sql.NullString{String: entry.RequestID, Valid: entry.RequestID != ""},
+		"table_name": entry.TableName,
+		"row_id":     entry.RowID,
+		"action":     entry.Action,
+		"diff":       entry.Diff,
+	}
+
+	query, values, err := BuildInsertQuery("audit_log", data)
+	if err != nil {
+		return err
+	}
+
+	_, err = queryOne(ctx, db, scanRow[AuditEntry], query, values...)
+	return err
+}
+
+// insertRow runs the INSERT built by BuildInsertQuery for data and records it in the audit
+// log in the same transaction. It returns sql.ErrNoRows when nothing was inserted.
+func insertRow[T any](ctx context.Context, db *DB, tableName string, data map[string]any) (*T, error) {
+	query, values, err := BuildInsertQuery(tableName, data)
+	if err != nil {
+		return nil, err
+	}
+
+	var item *T
+	err = db.inTx(ctx, func(db *DB) error {
+		item, err = queryOne(ctx, db, scanRow[T], query, values...)
+		if err != nil {
+			return err
+		}
+		return writeAudit(ctx, db, tableName, AuditInsert, rowID(item), nil, item)
+	})
+	return item, err
+}
+
+// updateRow runs the UPDATE built by BuildUpdateQuery and records the row's before and after
+// values in the audit log in the same transaction. conditions must include the row's id.
+// It returns sql.ErrNoRows when no row matched.
+func updateRow[T any](ctx context.Context, db *DB, tableName string, action AuditAction, updateData, conditions map[string]any) (*T, error) {
+	id, ok := conditions["id"].(uuid.UUID)
+	if !ok {
+		return nil, fmt.Errorf("audited update of %s requires an id condition", tableName)
+	}
+
+	query, values, err := BuildUpdateQuery(tableName, updateData, conditions)
+	if err != nil {
+		return nil, err
+	}
+
+	var item *T
+	err = db.inTx(ctx, func(db *DB) error {
+		before, err := lockRow[T](ctx, db, tableName, id)
+		if err != nil {
+			return err
+		}
+
+		item, err = queryOne(ctx, db, scanRow[T], query, values...)
+		if err != nil {
+			return err
+		}
+		return writeAudit(ctx, db, tableName, action, id, before, item)
+	})
+	return item, err
+}
+
+// deleteRow permanently deletes the row with id when match accepts its current values, and
+// records them in the audit log in the same transaction. The row is locked before match is
+// called. It returns sql.ErrNoRows when there is no such row or match rejects it.
+func deleteRow[T any](ctx context.Context, db *DB, tableName string, id uuid.UUID, match func(*T) bool) error {
+	query := fmt.Sprintf("DELETE FROM %s WHERE id = $1", tableName)
+
+	return db.inTx(ctx, func(db *DB) error {
+		before, err := lockRow[T](ctx, db, tableName, id)
+		if err != nil {
+			return err
+		}
+		if before == nil || !match(before) {
+			return sql.ErrNoRows
+		}
+
+		if err := execDelete(ctx, db, query, id); err != nil {
+			return err
+		}
+		return writeAudit[T](ctx, db, tableName, AuditPurge, id, before, nil)
+	})
+}
+
+func execDelete(ctx context.Context, db *DB, query string, id uuid.UUID) error {
+	ctx, span := startQuerySpan(ctx, query)
+	defer span.End()
+
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+	if _, err := db.conn.ExecContext(ctx, query, id); err != nil {
+		setSpanError(span, err)
+		return translateError(err)
+	}
+	return nil
+}
+
+// lockRow reads the current row with id for update, including soft-deleted rows.
+// It returns a nil row when there is none.
+func lockRow[T any](ctx context.Context, db *DB, tableName string, id uuid.UUID) (*T, error) {
+	query, values, err := buildSelectQuery(tableName, map[string]any{"id": id}, true)
+	if err != nil {
+		return nil, err
+	}
+
+	row, err := queryOne(ctx, db, scanRow[T], query+" FOR UPDATE", values...)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	return row, err
+}
+
+func rowID[T any](item *T) uuid.UUID {
+	id, _ := columnValue(item, "id").(uuid.UUID)
+	return id
+}
+
+func (s *auditLogStoreImpl) ListAuditLog(ctx context.Context, page PageRequest) (*Page[AuditEntry], error) {
+	return queryPage(ctx, s.db, scanRow[AuditEntry], columnValue[AuditEntry], "audit_log", nil, page)
+}
+
+type auditLogStoreImpl struct {
+	db *DB
+}
+
+var NewAuditLogStore = func(db *DB) AuditLogStore {
+	return &auditLogStoreImpl{
+		db: db,
+	}
+}
+
+type AuditLogStore interface {
+	ListAuditLog(ctx context.Context, page PageRequest) (*Page[AuditEntry], error)
+}
@@ -0,0 +1,144 @@
+package data
+
+import (
+	"database/sql"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// tableColumns lists the columns selected and returned for each table that has a model.
+// Columns come from the model's `db` struct tags, in field order, so adding a column to
+// a migration only requires adding the matching tagged field.
+var tableColumns = map[string][]string{
+	"organizations":      columnsOf[Organization](),
+	"users":              columnsOf[User](),
+	"user_associations":  columnsOf[UserAssociation](),
+	"uld_inventories":    columnsOf[UldInventory](),
+	"delivery_manifests": columnsOf[DeliveryManifest](),
+	"audit_log":          columnsOf[AuditEntry](),
+	"outbox":             columnsOf[OutboxEvent](),
+	"idempotency_keys":   columnsOf[IdempotencyKey](),
+}
+
+func selectColumns(tableName string) (string, error) {
+	columns, ok := tableColumns[tableName]
+	if !ok {
+		return "", fmt.Errorf("no model registered for table: %s", tableName)
+	}
+	return strings.Join(columns, ", "), nil
+}
+
+type fieldMap struct {
+	columns []string
+	index   map[string][]int
+}
+
+var fieldMaps sync.Map // reflect.Type => *fieldMap
+
+func fieldsOf(t reflect.Type) *fieldMap {
+	if fm, ok := fieldMaps.Load(t); ok {
+		return fm.(*fieldMap)
+	}
+
+	fm := &fieldMap{index: make(map[string][]int)}
+	for _, f := range reflect.VisibleFields(t) {
+		column := f.Tag.Get("db")
+		if column == "" || column == "-" || !f.IsExported() {
+			continue
+		}
+		fm.columns = append(fm.columns, column)
+		fm.index[column] = f.Index
+	}
+
+	actual, _ := fieldMaps.LoadOrStore(t, fm)
+	return actual.(*fieldMap)
+}
+
+// columnsOf returns the `db` tag names of T's fields in declaration order.
+func columnsOf[T any]() []string {
+	return fieldsOf(reflect.TypeFor[T]()).columns
+}
+
+// columnValue returns the value of the field of item tagged with column, or nil if there is none.
+func columnValue[T any](item *T, column string) any {
+	index, ok := fieldsOf(reflect.TypeFor[T]()).index[column]
+	if !ok {
+		return nil
+	}
+	return reflect.ValueOf(item).Elem().FieldByIndex(index).Interface()
+}
+
+// setColumnValue sets the field of item tagged with column. A nil value zeroes the field,
+// and a non-pointer value is stored through a new pointer for pointer fields.
+func setColumnValue[T any](item *T, column string, value any) {
+	index, ok := fieldsOf(reflect.TypeFor[T]()).index[column]
+	if !ok {
+		return
+	}
+
+	field := reflect.ValueOf(item).Elem().FieldByIndex(index)
+	if value == nil {
+		field.SetZero()
+		return
+	}
+
+	v := reflect.ValueOf(value)
+	if field.Kind() == reflect.Pointer && v.Kind() != reflect.Pointer {
+		p := reflect.New(field.Type().Elem())
+		p.Elem().Set(v.Convert(field.Type().Elem()))
+		field.Set(p)
+		return
+	}
+	field.Set(v.Convert(field.Type()))
+}
+
+// scanRow scans the current row into a new T, matching result columns to fields by their
+// `db` tag. Columns without a matching field are discarded, and NULL is scanned into
+// string fields as the empty string.
+func scanRow[T any](rows *sql.Rows) (*T, error) {
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+
+	item := new(T)
+	v := reflect.ValueOf(item).Elem()
+	fm := fieldsOf(v.Type())
+
+	dest := make([]any, len(columns))
+	for i, column := range columns {
+		index, ok := fm.index[column]
+		if !ok {
+			dest[i] = new(any)
+			continue
+		}
+
+		field := v.FieldByIndex(index)
+		if field.Kind() == reflect.String {
+			dest[i] = nullString{field}
+		} else {
+			dest[i] = field.Addr().Interface()
+		}
+	}
+
+	if err := rows.Scan(dest...); err != nil {
+		return nil, err
+	}
+	return item, nil
+}
+
+// nullString scans a nullable text column into a string field of any string type.
+type nullString struct {
+	field reflect.Value
+}
+
+func (n nullString) Scan(src any) error {
+	var s sql.NullString
+	if err := s.Scan(src); err != nil {
+		return err
+	}
+	n.field.SetString(s.String)
+	return nil
+}
@@ -0,0 +1,87 @@
+package data
+
+import (
+	"context"
+	"os"
+	"reflect"
+	"slices"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/kevin-griley/api/internal/db"
+)
+
+func TestColumnsOf(t *testing.T) {
+	expected := []string{
+		"id",
+		"created_at",
+		"updated_at",
+		"name",
+		"unique_url",
+		"address",
+		"contact_info",
+		"organization_type",
+		"deleted_at",
+	}
+	if got := columnsOf[Organization](); !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected columns %v, got %v", expected, got)
+	}
+
+	o := &Organization{ID: uuid.Must(uuid.NewV7()), Name: "ACME"}
+	if got := columnValue(o, "name"); got != "ACME" {
+		t.Errorf("Expected name ACME, got %v", got)
+	}
+	if got := columnValue(o, "id"); got != o.ID {
+		t.Errorf("Expected id %v, got %v", o.ID, got)
+	}
+	if got := columnValue(o, "missing"); got != nil {
+		t.Errorf("Expected nil for unknown column, got %v", got)
+	}
+}
+
+// TestModelsMatchSchema checks every model against the live database so a migration
+// that adds, drops or renames a column fails here rather than at runtime.
+func TestModelsMatchSchema(t *testing.T) {
+	if os.Getenv("DATABASE_URL") == "" {
+		t.Skip("DATABASE_URL not set")
+	}
+
+	dbConn, _, err := db.Init(os.Getenv("DATABASE_URL"))
+	if err != nil {
+		t.Fatalf("Failed to connect to database: %v", err)
+	}
+	defer db.Close(dbConn)
+
+	for tableName, columns := range tableColumns {
+		t.Run(tableName, func(t *testing.T) {
+			rows, err := dbConn.QueryContext(context.Background(),
+				"SELECT column_name FROM information_schema.columns WHERE table_schema = current_schema() AND table_name = $1",
+				tableName,
+			)
+			if err != nil {
+				t.Fatalf("Failed to read schema: %v", err)
+			}
+			defer rows.Close()
+
+			var schema []string
+			for rows.Next() {
+				var column string
+				if err := rows.Scan(&column); err != nil {
+					t.Fatalf("Failed to scan column: %v", err)
+				}
+				schema = append(schema, column)
+			}
+
+			for _, column := range schema {
+				if !slices.Contains(columns, column) {
+					t.Errorf("Column %s.%s has no model field", tableName, column)
+				}
+			}
+			for _, column := range columns {
+				if !slices.Contains(schema, column) {
+					t.Errorf("Model field %s does not exist in table %s", column, tableName)
+				}
+			}
+		})
+	}
+}
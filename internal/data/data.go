@@ -3,25 +3,167 @@ package data
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"math/rand"
 	"sort"
 	"strings"
+	"time"
 )
 
 type ContextKey string
 
 const ContextKeyStore ContextKey = "ContextKeyStore"
 
+// ErrPreconditionFailed is returned by an update whose expected updated_at no longer
+// matches the row, meaning it was modified since the caller read it.
+var ErrPreconditionFailed = errors.New("row was modified since it was read")
+
+// DefaultQueryTimeout bounds every query issued by a store unless overridden with WithQueryTimeout.
+const DefaultQueryTimeout = 5 * time.Second
+
+// DBTX is the subset of *sql.DB and *sql.Tx the stores rely on.
+type DBTX interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+// DB is the handle shared by every store. It carries the per-query timeout
+// applied on top of the caller's context, and the read replicas, if any.
+type DB struct {
+	conn         DBTX
+	replicas     *replicaSet
+	queryTimeout time.Duration
+	maxPageSize  int
+
+	stmtCacheSize int
+}
+
+type Option func(*DB)
+
+// WithQueryTimeout sets the deadline applied to each query. A zero or negative
+// duration disables the per-query timeout and relies on the caller's context alone.
+func WithQueryTimeout(d time.Duration) Option {
+	return func(db *DB) {
+		db.queryTimeout = d
+	}
+}
+
+func NewDB(conn DBTX, opts ...Option) *DB {
+	db := &DB{
+		conn:         conn,
+		queryTimeout: DefaultQueryTimeout,
+		maxPageSize:  DefaultMaxPageSize,
+	}
+	for _, opt := range opts {
+		opt(db)
+	}
+	db.withStatementCache()
+	return db
+}
+
+// withConn returns a copy of db that issues its queries on conn, such as a transaction.
+func (db *DB) withConn(conn DBTX) *DB {
+	c := *db
+	c.conn = conn
+	return &c
+}
+
+func (db *DB) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if db.queryTimeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, db.queryTimeout)
+}
+
+// queryOne runs query and scans the first row with scan.
+// It returns sql.ErrNoRows when the query produces no rows.
+func queryOne[T any](ctx context.Context, db *DB, scan func(*sql.Rows) (*T, error), query string, args ...any) (*T, error) {
+	ctx, span := startQuerySpan(ctx, query)
+	defer span.End()
+
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := db.conn.QueryContext(ctx, query, args...)
+	if err != nil {
+		setSpanError(span, err)
+		return nil, translateError(err)
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		if err := rows.Err(); err != nil {
+			setSpanError(span, err)
+			return nil, translateError(err)
+		}
+		return nil, sql.ErrNoRows
+	}
+
+	item, err := scan(rows)
+	setSpanError(span, err)
+	return item, err
+}
+
+// queryAll runs query and scans every row with scan.
+func queryAll[T any](ctx context.Context, db *DB, scan func(*sql.Rows) (*T, error), query string, args ...any) ([]*T, error) {
+	ctx, span := startQuerySpan(ctx, query)
+	defer span.End()
+
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := db.conn.QueryContext(ctx, query, args...)
+	if err != nil {
+		setSpanError(span, err)
+		return nil, translateError(err)
+	}
+	defer rows.Close()
+
+	items := []*T{}
+	for rows.Next() {
+		item, err := scan(rows)
+		if err != nil {
+			setSpanError(span, err)
+			return nil, err
+		}
+		items = append(items, item)
+	}
+
+	setSpanError(span, rows.Err())
+	return items, translateError(rows.Err())
+}
+
 type Store struct {
-	User         UserStore
-	Organization OrganizationStore
+	User             UserStore
+	Organization     OrganizationStore
+	UserAssociation  UserAssociationStore
+	UldInventory     UldInventoryStore
+	DeliveryManifest DeliveryManifestStore
+	AuditLog         AuditLogStore
+	Outbox           OutboxStore
+	Idempotency      IdempotencyStore
+
+	db *DB
+	// memory is set instead of db for stores created by NewMemoryStore.
+	memory *memoryDB
 }
 
-func NewStore(db *sql.DB) *Store {
+func NewStore(conn *sql.DB, opts ...Option) *Store {
+	return newStore(NewDB(conn, opts...))
+}
+
+func newStore(db *DB) *Store {
 	return &Store{
-		User:         NewUserStore(db),
-		Organization: NewOrganizationStore(db),
+		User:             NewUserStore(db),
+		Organization:     NewOrganizationStore(db),
+		UserAssociation:  NewUserAssociationStore(db),
+		UldInventory:     NewUldInventoryStore(db),
+		DeliveryManifest: NewDeliveryManifestStore(db),
+		AuditLog:         NewAuditLogStore(db),
+		Outbox:           NewOutboxStore(db),
+		Idempotency:      NewIdempotencyStore(db),
+		db:               db,
 	}
 }
 
@@ -36,7 +178,8 @@ func GetStore(ctx context.Context) (*Store, bool) {
 
 // BuildInsertQuery builds an INSERT query for the given table and data map.
 // It returns a query string with numbered placeholders and a slice of argument values.
-// Note the use of "RETURNING *", which allows you to return the full inserted row.
+// The RETURNING clause lists every column of the table's model, so the full inserted row
+// can be scanned with scanRow.
 //
 // Example usage:
 //
@@ -45,7 +188,7 @@ func GetStore(ctx context.Context) (*Store, bool) {
 //	     "age":  30,
 //	}
 //	query, args := BuildInsertQuery("users", data)
-//	// query => "INSERT INTO users (age, name) VALUES ($1, $2) RETURNING *"
+//	// query => "INSERT INTO users (age, name) VALUES ($1, $2) RETURNING id, name, age"
 //	// args  => []any{30, "Alice"}
 func BuildInsertQuery(tableName string, data map[string]any) (string, []any, error) {
 	if !isValidTable(tableName) {
@@ -54,6 +197,10 @@ func BuildInsertQuery(tableName string, data map[string]any) (string, []any, err
 	if len(data) == 0 {
 		return "", nil, fmt.Errorf("no data provided for insert query")
 	}
+	returning, err := selectColumns(tableName)
+	if err != nil {
+		return "", nil, err
+	}
 
 	// Use sorted keys to ensure deterministic output.
 	keys := sortedKeys(data)
@@ -68,10 +215,11 @@ func BuildInsertQuery(tableName string, data map[string]any) (string, []any, err
 	}
 
 	query := fmt.Sprintf(
-		"INSERT INTO %s (%s) VALUES (%s) RETURNING *",
+		"INSERT INTO %s (%s) VALUES (%s) RETURNING %s",
 		tableName,
 		strings.Join(columns, ", "),
 		strings.Join(placeholders, ", "),
+		returning,
 	)
 
 	return query, values, nil
@@ -79,8 +227,9 @@ func BuildInsertQuery(tableName string, data map[string]any) (string, []any, err
 
 // BuildUpdateQuery builds an UPDATE query for a given table, data map and conditions map.
 // Both data and conditions maps are sorted alphabetically (to guarantee consistent ordering)
-// and then converted to placeholder queries. A non-empty conditions map is required to prevent accidental updates.
-// The returned query uses "RETURNING *" to retrieve the updated row.
+// and then converted to placeholder queries. A nil condition value matches NULL.
+// A non-empty conditions map is required to prevent accidental updates.
+// The returned query lists the model's columns in its RETURNING clause to retrieve the updated row.
 //
 // Example usage:
 //
@@ -91,7 +240,7 @@ func BuildInsertQuery(tableName string, data map[string]any) (string, []any, err
 //	     "id": 1,
 //	}
 //	query, args := BuildUpdateQuery("users", updateData, conditions)
-//	// query => "UPDATE users SET name = $1 WHERE id = $2 RETURNING *"
+//	// query => "UPDATE users SET name = $1 WHERE id = $2 RETURNING id, name, age"
 //	// args  => []any{"Bob", 1}
 func BuildUpdateQuery(tableName string, updateData, conditions map[string]any) (string, []any, error) {
 	if !isValidTable(tableName) {
@@ -103,9 +252,12 @@ func BuildUpdateQuery(tableName string, updateData, conditions map[string]any) (
 	if len(conditions) == 0 {
 		return "", nil, fmt.Errorf("conditions cannot be empty for update query")
 	}
+	returning, err := selectColumns(tableName)
+	if err != nil {
+		return "", nil, err
+	}
 
 	dataKeys := sortedKeys(updateData)
-	conditionKeys := sortedKeys(conditions)
 
 	setClauses := make([]string, 0, len(updateData))
 	values := make([]any, 0, len(updateData)+len(conditions))
@@ -115,27 +267,25 @@ func BuildUpdateQuery(tableName string, updateData, conditions map[string]any) (
 		values = append(values, updateData[col])
 	}
 
-	// Build WHERE clause using sorted condition keys.
-	whereClauses := make([]string, 0, len(conditions))
-	for j, col := range conditionKeys {
-		// Note: j+1+len(updateData) is used to start numbering after data placeholders.
-		placeholderIdx := len(updateData) + j + 1
-		whereClauses = append(whereClauses, fmt.Sprintf("%s = $%d", col, placeholderIdx))
-		values = append(values, conditions[col])
-	}
+	// Build WHERE clause, numbering placeholders after the data placeholders.
+	whereClauses, conditionValues := conditionClauses(conditions, len(values))
+	values = append(values, conditionValues...)
 
-	query := fmt.Sprintf("UPDATE %s SET %s WHERE %s RETURNING *",
+	query := fmt.Sprintf("UPDATE %s SET %s WHERE %s RETURNING %s",
 		tableName,
 		strings.Join(setClauses, ", "),
 		strings.Join(whereClauses, " AND "),
+		returning,
 	)
 
 	return query, values, nil
 }
 
 // BuildSelectQuery builds a generic SELECT query for the given table.
-// If a non-empty conditions map is provided, it will be used to generate a WHERE clause.
-// This query returns all columns, making it a good match for a GET endpoint.
+// If a non-empty conditions map is provided, it will be used to generate a WHERE clause,
+// where a nil value matches NULL. Soft-deleted rows are excluded for tables whose model
+// has a deleted_at column.
+// This query selects every column of the table's model, making it a good match for a GET endpoint.
 //
 // Example usage:
 //
@@ -143,29 +293,85 @@ func BuildUpdateQuery(tableName string, updateData, conditions map[string]any) (
 //	     "id": 1,
 //	}
 //	query, args := BuildSelectQuery("users", conditions)
-//	// query => "SELECT * FROM users WHERE id = $1"
+//	// query => "SELECT id, name, age FROM users WHERE deleted_at IS NULL AND id = $1"
 //	// args  => []any{1}
 func BuildSelectQuery(tableName string, conditions map[string]any) (string, []any, error) {
+	return buildSelectQuery(tableName, conditions, false)
+}
+
+func buildSelectQuery(tableName string, conditions map[string]any, includeDeleted bool) (string, []any, error) {
+	query, whereClauses, values, err := selectQuery(tableName, conditions, includeDeleted)
+	if err != nil {
+		return "", nil, err
+	}
+
+	if len(whereClauses) > 0 {
+		query = fmt.Sprintf("%s WHERE %s", query, strings.Join(whereClauses, " AND "))
+	}
+
+	return query, values, nil
+}
+
+// selectQuery returns the SELECT clause for a table along with its WHERE clauses and values,
+// so callers can append further clauses before joining them.
+func selectQuery(tableName string, conditions map[string]any, includeDeleted bool) (string, []string, []any, error) {
 	if !isValidTable(tableName) {
-		return "", nil, fmt.Errorf("invalid table name: %s", tableName)
+		return "", nil, nil, fmt.Errorf("invalid table name: %s", tableName)
 	}
 
-	query := fmt.Sprintf("SELECT * FROM %s", tableName)
-	values := []any{}
+	columns, err := selectColumns(tableName)
+	if err != nil {
+		return "", nil, nil, err
+	}
 
-	if len(conditions) > 0 {
-		condKeys := sortedKeys(conditions)
-		whereClauses := make([]string, 0, len(conditions))
+	query := fmt.Sprintf("SELECT %s FROM %s", columns, tableName)
 
-		for i, col := range condKeys {
-			whereClauses = append(whereClauses, fmt.Sprintf("%s = $%d", col, i+1))
-			values = append(values, conditions[col])
+	whereClauses := []string{}
+	if !includeDeleted && isSoftDeletable(tableName) {
+		if _, ok := conditions["deleted_at"]; !ok {
+			whereClauses = append(whereClauses, "deleted_at IS NULL")
 		}
+	}
 
-		query = fmt.Sprintf("%s WHERE %s", query, strings.Join(whereClauses, " AND "))
+	conditionWhere, values := conditionClauses(conditions, 0)
+	whereClauses = append(whereClauses, conditionWhere...)
+
+	return query, whereClauses, values, nil
+}
+
+// inSubquery is a condition value matching a column against the rows of a subquery.
+// Query holds a %s verb for each of args, which are numbered as placeholders when rendered.
+type inSubquery struct {
+	query string
+	args  []any
+}
+
+// conditionClauses renders conditions as equality comparisons in sorted key order,
+// numbering placeholders from offset+1. A nil value renders as IS NULL, and an inSubquery
+// as IN (...).
+func conditionClauses(conditions map[string]any, offset int) ([]string, []any) {
+	clauses := make([]string, 0, len(conditions))
+	values := make([]any, 0, len(conditions))
+
+	for _, col := range sortedKeys(conditions) {
+		if conditions[col] == nil {
+			clauses = append(clauses, fmt.Sprintf("%s IS NULL", col))
+			continue
+		}
+		if sub, ok := conditions[col].(inSubquery); ok {
+			placeholders := make([]any, len(sub.args))
+			for i, arg := range sub.args {
+				values = append(values, arg)
+				placeholders[i] = fmt.Sprintf("$%d", offset+len(values))
+			}
+			clauses = append(clauses, fmt.Sprintf("%s IN (%s)", col, fmt.Sprintf(sub.query, placeholders...)))
+			continue
+		}
+		values = append(values, conditions[col])
+		clauses = append(clauses, fmt.Sprintf("%s = $%d", col, offset+len(values)))
 	}
 
-	return query, values, nil
+	return clauses, values
 }
 
 var validTables = map[string]struct{}{
@@ -178,6 +384,9 @@ var validTables = map[string]struct{}{
 	"carriers":           {},
 	"users":              {},
 	"user_associations":  {},
+	"audit_log":          {},
+	"outbox":             {},
+	"idempotency_keys":   {},
 }
 
 func isValidTable(tableName string) bool {
@@ -0,0 +1,34 @@
+package data
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestQueryTimeout(t *testing.T) {
+	db := NewDB(nil)
+	if db.queryTimeout != DefaultQueryTimeout {
+		t.Fatalf("Expected default timeout %v, got %v", DefaultQueryTimeout, db.queryTimeout)
+	}
+
+	ctx, cancel := NewDB(nil, WithQueryTimeout(time.Second)).withTimeout(context.Background())
+	defer cancel()
+	if _, ok := ctx.Deadline(); !ok {
+		t.Errorf("Expected a deadline when a query timeout is set")
+	}
+
+	ctx, cancel = NewDB(nil, WithQueryTimeout(0)).withTimeout(context.Background())
+	defer cancel()
+	if _, ok := ctx.Deadline(); ok {
+		t.Errorf("Expected no deadline when the query timeout is disabled")
+	}
+
+	parent, parentCancel := context.WithCancel(context.Background())
+	ctx, cancel = NewDB(nil).withTimeout(parent)
+	defer cancel()
+	parentCancel()
+	if ctx.Err() == nil {
+		t.Errorf("Expected query context to be cancelled with its parent")
+	}
+}
@@ -0,0 +1,58 @@
+package data
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/lib/pq"
+)
+
+var (
+	// ErrNotFound is returned when a row does not exist or is not visible, such as a soft-deleted row.
+	ErrNotFound = errors.New("not found")
+
+	// ErrConflict is returned when a write violates a unique constraint.
+	ErrConflict = errors.New("conflict")
+
+	// ErrInvalidReference is returned when a write references a row that does not exist, or a
+	// delete would leave rows referencing it.
+	ErrInvalidReference = errors.New("invalid reference")
+)
+
+// ConstraintError is a constraint violation reported by the database. It matches ErrConflict
+// or ErrInvalidReference with errors.Is, and names the violated constraint.
+type ConstraintError struct {
+	Err        error
+	Table      string
+	Constraint string
+}
+
+func (e *ConstraintError) Error() string {
+	return fmt.Sprintf("%s: %s violates %s", e.Err, e.Table, e.Constraint)
+}
+
+func (e *ConstraintError) Unwrap() error {
+	return e.Err
+}
+
+// notFound returns ErrNotFound wrapped with the entity and the key it was looked up by.
+func notFound(entity string, key any) error {
+	return fmt.Errorf("%s %v %w", entity, key, ErrNotFound)
+}
+
+// translateError converts unique and foreign key violations into a ConstraintError.
+// Other errors are returned unchanged.
+func translateError(err error) error {
+	var pqErr *pq.Error
+	if !errors.As(err, &pqErr) {
+		return err
+	}
+
+	switch pqErr.Code {
+	case "23505": // unique_violation
+		return &ConstraintError{Err: ErrConflict, Table: pqErr.Table, Constraint: pqErr.Constraint}
+	case "23503": // foreign_key_violation
+		return &ConstraintError{Err: ErrInvalidReference, Table: pqErr.Table, Constraint: pqErr.Constraint}
+	}
+	return err
+}
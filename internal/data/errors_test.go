@@ -0,0 +1,60 @@
+package data
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/lib/pq"
+)
+
+func TestTranslateError(t *testing.T) {
+	testCases := []struct {
+		name       string
+		err        error
+		expected   error
+		constraint string
+	}{
+		{
+			name:       "Unique Violation",
+			err:        &pq.Error{Code: "23505", Table: "users", Constraint: "users_email_key"},
+			expected:   ErrConflict,
+			constraint: "users_email_key",
+		},
+		{
+			name:       "Foreign Key Violation",
+			err:        &pq.Error{Code: "23503", Table: "user_associations", Constraint: "fk_user"},
+			expected:   ErrInvalidReference,
+			constraint: "fk_user",
+		},
+		{
+			name:     "Other Error",
+			err:      &pq.Error{Code: "40001"},
+			expected: nil,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := translateError(tc.err)
+
+			if tc.expected == nil {
+				if err != tc.err {
+					t.Fatalf("Expected %v unchanged, got %v", tc.err, err)
+				}
+				return
+			}
+
+			var constraintErr *ConstraintError
+			if !errors.Is(err, tc.expected) || !errors.As(err, &constraintErr) {
+				t.Fatalf("Expected %v, got %v", tc.expected, err)
+			}
+			if constraintErr.Constraint != tc.constraint {
+				t.Errorf("Expected constraint %s, got %s", tc.constraint, constraintErr.Constraint)
+			}
+		})
+	}
+
+	if translateError(nil) != nil {
+		t.Errorf("Expected nil for nil error")
+	}
+}
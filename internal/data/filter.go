@@ -0,0 +1,283 @@
+package data
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// MaxFilterValues bounds the number of values accepted by an "in" filter.
+const MaxFilterValues = 100
+
+type Operator string
+
+const (
+	OpEq  Operator = "eq"
+	OpNe  Operator = "ne"
+	OpGt  Operator = "gt"
+	OpGte Operator = "gte"
+	OpLt  Operator = "lt"
+	OpLte Operator = "lte"
+	OpIn  Operator = "in"
+)
+
+var operatorSQL = map[Operator]string{
+	OpEq:  "=",
+	OpNe:  "<>",
+	OpGt:  ">",
+	OpGte: ">=",
+	OpLt:  "<",
+	OpLte: "<=",
+}
+
+type ColumnType int
+
+const (
+	ColumnText ColumnType = iota
+	ColumnTime
+	ColumnBool
+	ColumnInt
+	ColumnUUID
+)
+
+// Column describes how list endpoints may use a column.
+// Only NOT NULL columns may be Sortable, since NULLs would drop out of keyset comparisons.
+type Column struct {
+	Type     ColumnType
+	Sortable bool
+}
+
+func (c Column) allows(op Operator) bool {
+	switch op {
+	case OpEq, OpNe:
+		return true
+	case OpIn:
+		return c.Type != ColumnBool
+	default:
+		return c.Type == ColumnTime || c.Type == ColumnInt
+	}
+}
+
+func (c Column) parse(raw string) (any, error) {
+	switch c.Type {
+	case ColumnTime:
+		return time.Parse(time.RFC3339Nano, raw)
+	case ColumnBool:
+		return strconv.ParseBool(raw)
+	case ColumnInt:
+		return strconv.Atoi(raw)
+	case ColumnUUID:
+		return uuid.Parse(raw)
+	default:
+		return raw, nil
+	}
+}
+
+// listColumns is the allowlist of columns each table exposes to list endpoints.
+// A column missing from its table's map can be neither filtered nor sorted on.
+var listColumns = map[string]map[string]Column{
+	"organizations": {
+		"id":                {Type: ColumnUUID},
+		"created_at":        {Type: ColumnTime, Sortable: true},
+		"updated_at":        {Type: ColumnTime, Sortable: true},
+		"name":              {Type: ColumnText, Sortable: true},
+		"unique_url":        {Type: ColumnText},
+		"organization_type": {Type: ColumnText},
+	},
+	"users": {
+		"id":          {Type: ColumnUUID},
+		"created_at":  {Type: ColumnTime, Sortable: true},
+		"updated_at":  {Type: ColumnTime, Sortable: true},
+		"user_name":   {Type: ColumnText},
+		"email":       {Type: ColumnText},
+		"is_admin":    {Type: ColumnBool},
+		"is_verified": {Type: ColumnBool},
+	},
+	"uld_inventories": {
+		"id":                    {Type: ColumnUUID},
+		"created_at":            {Type: ColumnTime, Sortable: true},
+		"updated_at":            {Type: ColumnTime, Sortable: true},
+		"uld_number":            {Type: ColumnText},
+		"uld_type":              {Type: ColumnText},
+		"uld_status":            {Type: ColumnText},
+		"current_location_id":   {Type: ColumnUUID},
+		"current_location_type": {Type: ColumnText},
+		"organization_id":       {Type: ColumnUUID},
+	},
+	"delivery_manifests": {
+		"id":              {Type: ColumnUUID},
+		"created_at":      {Type: ColumnTime, Sortable: true},
+		"updated_at":      {Type: ColumnTime, Sortable: true},
+		"manifest_date":   {Type: ColumnTime, Sortable: true},
+		"warehouse_id":    {Type: ColumnUUID},
+		"airline_id":      {Type: ColumnUUID},
+		"carrier_id":      {Type: ColumnUUID},
+		"manifest_status": {Type: ColumnText},
+		"created_by":      {Type: ColumnUUID},
+		"organization_id": {Type: ColumnUUID},
+	},
+	"audit_log": {
+		"id":         {Type: ColumnUUID},
+		"created_at": {Type: ColumnTime, Sortable: true},
+		"actor_id":   {Type: ColumnUUID},
+		"request_id": {Type: ColumnText},
+		"table_name": {Type: ColumnText},
+		"row_id":     {Type: ColumnUUID},
+		"action":     {Type: ColumnText},
+	},
+}
+
+func lookupColumn(tableName, column string) (Column, bool) {
+	col, ok := listColumns[tableName][column]
+	return col, ok
+}
+
+// Filter is a single validated comparison against an allowlisted column.
+// Value holds a slice for OpIn.
+type Filter struct {
+	Column string
+	Op     Operator
+	Value  any
+}
+
+// Sort orders a list by an allowlisted column. Ties are broken by id.
+type Sort struct {
+	Column string
+	Desc   bool
+}
+
+// String returns s in the form accepted by ParseSort.
+func (s *Sort) String() string {
+	if s == nil {
+		return ""
+	}
+	if s.Desc {
+		return "-" + s.Column
+	}
+	return s.Column
+}
+
+var filterKeyPattern = regexp.MustCompile(`^([a-z_]+)(?:\[([a-z]+)\])?$`)
+
+// ParseFilter parses a query parameter such as "uld_status=in_transit",
+// "created_at[gte]=2025-01-01T00:00:00Z" or "uld_type[in]=AKE,PMC" into a Filter.
+// The column must be allowlisted for tableName and support the operator.
+func ParseFilter(tableName, key, raw string) (Filter, error) {
+	m := filterKeyPattern.FindStringSubmatch(key)
+	if m == nil {
+		return Filter{}, fmt.Errorf("invalid filter: %s", key)
+	}
+
+	column, op := m[1], Operator(m[2])
+	if op == "" {
+		op = OpEq
+	}
+
+	col, ok := lookupColumn(tableName, column)
+	if !ok {
+		return Filter{}, fmt.Errorf("unknown filter: %s", column)
+	}
+	if _, known := operatorSQL[op]; !known && op != OpIn {
+		return Filter{}, fmt.Errorf("unknown operator: %s", op)
+	}
+	if !col.allows(op) {
+		return Filter{}, fmt.Errorf("operator %s is not supported for %s", op, column)
+	}
+
+	if op != OpIn {
+		value, err := col.parse(raw)
+		if err != nil {
+			return Filter{}, fmt.Errorf("invalid value for %s: %s", column, raw)
+		}
+		return Filter{Column: column, Op: op, Value: value}, nil
+	}
+
+	parts := strings.Split(raw, ",")
+	if len(parts) > MaxFilterValues {
+		return Filter{}, fmt.Errorf("too many values for %s", column)
+	}
+	values := make([]any, 0, len(parts))
+	for _, part := range parts {
+		value, err := col.parse(part)
+		if err != nil {
+			return Filter{}, fmt.Errorf("invalid value for %s: %s", column, part)
+		}
+		values = append(values, value)
+	}
+	return Filter{Column: column, Op: op, Value: values}, nil
+}
+
+// ParseSort parses a sort parameter such as "name" or "-updated_at".
+// An empty string returns a nil Sort, which orders by id.
+func ParseSort(tableName, raw string) (*Sort, error) {
+	if raw == "" {
+		return nil, nil
+	}
+
+	s := &Sort{Column: raw}
+	if strings.HasPrefix(raw, "-") {
+		s.Column, s.Desc = raw[1:], true
+	}
+
+	col, ok := lookupColumn(tableName, s.Column)
+	if !ok || !col.Sortable {
+		return nil, fmt.Errorf("cannot sort by %s", s.Column)
+	}
+
+	return s, nil
+}
+
+// ParseFilters parses every query parameter other than the reserved ones into Filters.
+func ParseFilters(tableName string, params url.Values, reserved ...string) ([]Filter, error) {
+	keys := make([]string, 0, len(params))
+	for key := range params {
+		if !slices.Contains(reserved, key) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	filters := make([]Filter, 0, len(keys))
+	for _, key := range keys {
+		for _, raw := range params[key] {
+			f, err := ParseFilter(tableName, key, raw)
+			if err != nil {
+				return nil, err
+			}
+			filters = append(filters, f)
+		}
+	}
+
+	return filters, nil
+}
+
+// filterClauses renders filters as SQL comparisons, numbering placeholders from offset+1.
+func filterClauses(filters []Filter, offset int) ([]string, []any) {
+	clauses := make([]string, 0, len(filters))
+	values := make([]any, 0, len(filters))
+
+	for _, f := range filters {
+		if f.Op != OpIn {
+			values = append(values, f.Value)
+			clauses = append(clauses, fmt.Sprintf("%s %s $%d", f.Column, operatorSQL[f.Op], offset+len(values)))
+			continue
+		}
+
+		list := f.Value.([]any)
+		placeholders := make([]string, 0, len(list))
+		for _, v := range list {
+			values = append(values, v)
+			placeholders = append(placeholders, fmt.Sprintf("$%d", offset+len(values)))
+		}
+		clauses = append(clauses, fmt.Sprintf("%s IN (%s)", f.Column, strings.Join(placeholders, ", ")))
+	}
+
+	return clauses, values
+}
@@ -0,0 +1,185 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+// DefaultIdempotencyTTL is how long a stored response is replayed for its Idempotency-Key.
+const DefaultIdempotencyTTL = 24 * time.Hour
+
+// DefaultIdempotencyLease is how long a claimed key stays locked to the request that claimed
+// it. It outlasts any request the server lets run, so a key still locked after it belongs to
+// a request that crashed or was cut off by a deploy, and a retry may take it over.
+const DefaultIdempotencyLease = time.Minute
+
+// StoredHeader holds the response headers replayed with an idempotent response.
+type StoredHeader map[string][]string
+
+func (h StoredHeader) Value() (driver.Value, error) {
+	b, err := json.Marshal(h)
+	if err != nil {
+		return nil, err
+	}
+	return string(b), nil
+}
+
+func (h *StoredHeader) Scan(src any) error {
+	switch v := src.(type) {
+	case nil:
+		*h = nil
+		return nil
+	case []byte:
+		return json.Unmarshal(v, h)
+	case string:
+		return json.Unmarshal([]byte(v), h)
+	}
+	return fmt.Errorf("cannot scan %T into StoredHeader", src)
+}
+
+// IdempotencyKey records a request made with an Idempotency-Key header and, once it has
+// completed, its response. A Status of zero means the first request is still in progress,
+// until LockedUntil.
+type IdempotencyKey struct {
+	Owner       string       `json:"owner" db:"owner"`
+	Key         string       `json:"key" db:"key"`
+	RequestHash string       `json:"request_hash" db:"request_hash"`
+	Status      int          `json:"status" db:"status"`
+	Header      StoredHeader `json:"header" db:"header"`
+	Body        []byte       `json:"body" db:"body"`
+	CreatedAt   time.Time    `json:"created_at" db:"created_at"`
+	ExpiresAt   time.Time    `json:"expires_at" db:"expires_at"`
+	LockedUntil *time.Time   `json:"locked_until,omitempty" db:"locked_until"`
+}
+
+// Completed reports whether the key's response has been stored.
+func (k *IdempotencyKey) Completed() bool {
+	return k.Status != 0
+}
+
+// lapsed reports whether the key can be claimed afresh at now: it has expired, or its request
+// never completed and its lease ran out.
+func (k *IdempotencyKey) lapsed(now time.Time) bool {
+	if !k.ExpiresAt.After(now) {
+		return true
+	}
+	return !k.Completed() && (k.LockedUntil == nil || !k.LockedUntil.After(now))
+}
+
+// Claim records a new request for owner's key, locked to it for lease, and returns the record
+// and true. When the key is already in use it returns the existing record and false instead.
+// An expired key, or one whose request held its lease without completing, is claimed afresh.
+func (s *idempotencyStoreImpl) Claim(ctx context.Context, owner, key, requestHash string, lease, ttl time.Duration) (*IdempotencyKey, bool, error) {
+	columns, err := selectColumns("idempotency_keys")
+	if err != nil {
+		return nil, false, err
+	}
+
+	now := time.Now().UTC()
+	claim := fmt.Sprintf(`INSERT INTO idempotency_keys (owner, key, request_hash, status, created_at, expires_at, locked_until)
+VALUES ($1, $2, $3, 0, $4, $5, $6)
+ON CONFLICT (owner, key) DO UPDATE SET request_hash = EXCLUDED.request_hash, status = 0, header = NULL,
+body = NULL, created_at = EXCLUDED.created_at, expires_at = EXCLUDED.expires_at, locked_until = EXCLUDED.locked_until
+WHERE idempotency_keys.expires_at <= EXCLUDED.created_at
+OR (idempotency_keys.status = 0 AND COALESCE(idempotency_keys.locked_until, '-infinity') <= EXCLUDED.created_at)
+RETURNING %s`, columns)
+
+	record, err := queryOne(ctx, s.db, scanRow[IdempotencyKey], claim, owner, key, requestHash, now, now.Add(ttl), now.Add(lease))
+	if err == nil {
+		return record, true, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return nil, false, err
+	}
+
+	// Like every query on idempotency keys, this reads the primary rather than s.db.reader:
+	// a replica that has not caught up with the claim would report no key at all.
+	query, values, err := BuildSelectQuery("idempotency_keys", map[string]any{"owner": owner, "key": key})
+	if err != nil {
+		return nil, false, err
+	}
+	record, err = queryOne(ctx, s.db, scanRow[IdempotencyKey], query, values...)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, false, notFound("idempotency key", key)
+	}
+	return record, false, err
+}
+
+// Complete stores the response of the request that claimed owner's key and lifts its lock.
+// It fails with ErrNotFound when the key is no longer in progress, such as after a retry
+// took it over and completed first.
+func (s *idempotencyStoreImpl) Complete(ctx context.Context, owner, key string, status int, header StoredHeader, body []byte) error {
+	query, values, err := BuildUpdateQuery("idempotency_keys",
+		map[string]any{"status": status, "header": header, "body": body, "locked_until": nil},
+		map[string]any{"owner": owner, "key": key, "status": 0})
+	if err != nil {
+		return err
+	}
+
+	_, err = queryOne(ctx, s.db, scanRow[IdempotencyKey], query, values...)
+	if errors.Is(err, sql.ErrNoRows) {
+		return notFound("idempotency key", key)
+	}
+	return err
+}
+
+// Release forgets owner's key while its request is in progress, so that a retry runs the
+// request again. It is used when the request failed without a response worth replaying.
+func (s *idempotencyStoreImpl) Release(ctx context.Context, owner, key string) error {
+	_, err := queryAll(ctx, s.db, scanRow[IdempotencyKey],
+		"DELETE FROM idempotency_keys WHERE owner = $1 AND key = $2 AND status = 0 RETURNING owner",
+		owner, key)
+	return err
+}
+
+// PurgeExpired removes keys that expired before the cutoff and returns how many were removed.
+func (s *idempotencyStoreImpl) PurgeExpired(ctx context.Context, before time.Time) (int, error) {
+	keys, err := queryAll(ctx, s.db, scanRow[IdempotencyKey],
+		"DELETE FROM idempotency_keys WHERE expires_at < $1 RETURNING owner", before)
+	return len(keys), err
+}
+
+// PurgeIdempotencyKeysEvery removes expired idempotency keys on every interval until ctx is done.
+func (s *Store) PurgeIdempotencyKeysEvery(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			purged, err := s.Idempotency.PurgeExpired(ctx, time.Now())
+			if err != nil {
+				slog.Error("PurgeExpired", "error", err)
+				continue
+			}
+			if purged > 0 {
+				slog.Info("PurgeExpired", "purged", purged)
+			}
+		}
+	}
+}
+
+type idempotencyStoreImpl struct {
+	db *DB
+}
+
+var NewIdempotencyStore = func(db *DB) IdempotencyStore {
+	return &idempotencyStoreImpl{
+		db: db,
+	}
+}
+
+type IdempotencyStore interface {
+	Claim(ctx context.Context, owner, key, requestHash string, lease, ttl time.Duration) (*IdempotencyKey, bool, error)
+	Complete(ctx context.Context, owner, key string, status int, header StoredHeader, body []byte) error
+	Release(ctx context.Context, owner, key string) error
+	PurgeExpired(ctx context.Context, before time.Time) (int, error)
+}
@@ -0,0 +1,192 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func (s *deliveryManifestStoreImpl) CreateRequest(manifestDate time.Time, warehouseID, airlineID, carrierID uuid.UUID, signatureInfo string, createdBy, organizationID uuid.UUID) (*DeliveryManifest, error) {
+
+	manifestId, err := uuid.NewV7()
+	if err != nil {
+		return nil, err
+	}
+
+	return &DeliveryManifest{
+		ID:             manifestId,
+		CreatedAt:      time.Now().UTC(),
+		UpdatedAt:      time.Now().UTC(),
+		ManifestDate:   manifestDate,
+		WarehouseID:    warehouseID,
+		AirlineID:      airlineID,
+		CarrierID:      carrierID,
+		SignatureInfo:  signatureInfo,
+		ManifestStatus: ManifestDraft,
+		CreatedBy:      createdBy,
+		OrganizationID: organizationID,
+	}, nil
+}
+
+func (s *deliveryManifestStoreImpl) CreateDeliveryManifest(ctx context.Context, m *DeliveryManifest) (*DeliveryManifest, error) {
+
+	data := map[string]any{
+		"id":              m.ID,
+		"created_at":      m.CreatedAt,
+		"updated_at":      m.UpdatedAt,
+		"manifest_date":   m.ManifestDate,
+		"warehouse_id":    m.WarehouseID,
+		"airline_id":      m.AirlineID,
+		"carrier_id":      m.CarrierID,
+		"signature_info":  m.SignatureInfo,
+		"manifest_status": m.ManifestStatus,
+		"created_by":      m.CreatedBy,
+		"organization_id": m.OrganizationID,
+	}
+
+	manifest, err := insertRow[DeliveryManifest](ctx, s.db, "delivery_manifests", data)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("failed to create manifest")
+	}
+	return manifest, err
+
+}
+
+func (s *deliveryManifestStoreImpl) GetDeliveryManifestByID(ctx context.Context, ID uuid.UUID) (*DeliveryManifest, error) {
+
+	data := map[string]any{
+		"id": ID,
+	}
+
+	query, values, err := buildSelectQuery("delivery_manifests", data, includesDeleted(ctx))
+	if err != nil {
+		return nil, err
+	}
+
+	manifest, err := queryOne(ctx, s.db.reader(ctx), scanRow[DeliveryManifest], query, values...)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, notFound("manifest", ID)
+	}
+	return manifest, err
+
+}
+
+func (s *deliveryManifestStoreImpl) ListDeliveryManifests(ctx context.Context, page PageRequest) (*Page[DeliveryManifest], error) {
+	return queryPage(ctx, s.db, scanRow[DeliveryManifest], columnValue[DeliveryManifest], "delivery_manifests", nil, page)
+}
+
+func (s *deliveryManifestStoreImpl) DeleteDeliveryManifest(ctx context.Context, ID uuid.UUID) (*DeliveryManifest, error) {
+	return softDelete[DeliveryManifest](ctx, s.db, "delivery_manifests", "manifest", ID, nil)
+}
+
+func (s *deliveryManifestStoreImpl) RestoreDeliveryManifest(ctx context.Context, ID uuid.UUID) (*DeliveryManifest, error) {
+	return restore[DeliveryManifest](ctx, s.db, "delivery_manifests", "manifest", ID, nil)
+}
+
+// ErrInvalidTransition is returned when a status change is not allowed from the current status.
+var ErrInvalidTransition = errors.New("invalid status transition")
+
+// manifestTransitions lists the statuses a manifest may move to from each status.
+var manifestTransitions = map[ManifestStatus][]ManifestStatus{
+	ManifestDraft:     {ManifestSubmitted},
+	ManifestSubmitted: {ManifestAccepted, ManifestRejected},
+}
+
+var manifestEvents = map[ManifestStatus]string{
+	ManifestSubmitted: EventManifestSubmitted,
+	ManifestAccepted:  EventManifestAccepted,
+	ManifestRejected:  EventManifestRejected,
+}
+
+func checkManifestTransition(from, to ManifestStatus) error {
+	if !slices.Contains(manifestTransitions[from], to) {
+		return fmt.Errorf("%w: manifest cannot go from %s to %s", ErrInvalidTransition, from, to)
+	}
+	return nil
+}
+
+// UpdateManifestStatus moves a manifest to status, following manifestTransitions, and enqueues
+// the matching manifest event in the same transaction.
+func (s *deliveryManifestStoreImpl) UpdateManifestStatus(ctx context.Context, ID uuid.UUID, status ManifestStatus) (*DeliveryManifest, error) {
+	var manifest *DeliveryManifest
+	err := s.db.inTx(ctx, func(db *DB) error {
+		current, err := lockRow[DeliveryManifest](ctx, db, "delivery_manifests", ID)
+		if err != nil {
+			return err
+		}
+		if current == nil || current.DeletedAt != nil {
+			return notFound("manifest", ID)
+		}
+		if err := checkManifestTransition(current.ManifestStatus, status); err != nil {
+			return err
+		}
+
+		manifest, err = updateRow[DeliveryManifest](ctx, db, "delivery_manifests", AuditUpdate,
+			map[string]any{"manifest_status": status, "updated_at": time.Now().UTC()},
+			map[string]any{"id": ID})
+		if err != nil {
+			return err
+		}
+
+		_, err = enqueueEvent(ctx, db, "manifest", ID, manifestEvents[status], ManifestStatusChanged{
+			ManifestID:     ID,
+			OrganizationID: current.OrganizationID,
+			From:           current.ManifestStatus,
+			To:             status,
+		})
+		return err
+	})
+	return manifest, err
+}
+
+type deliveryManifestStoreImpl struct {
+	db *DB
+}
+
+var NewDeliveryManifestStore = func(db *DB) DeliveryManifestStore {
+	return &deliveryManifestStoreImpl{
+		db: db,
+	}
+}
+
+type DeliveryManifestStore interface {
+	GetDeliveryManifestByID(ctx context.Context, ID uuid.UUID) (*DeliveryManifest, error)
+	ListDeliveryManifests(ctx context.Context, page PageRequest) (*Page[DeliveryManifest], error)
+
+	CreateDeliveryManifest(ctx context.Context, m *DeliveryManifest) (*DeliveryManifest, error)
+	CreateRequest(manifestDate time.Time, warehouseID, airlineID, carrierID uuid.UUID, signatureInfo string, createdBy, organizationID uuid.UUID) (*DeliveryManifest, error)
+
+	DeleteDeliveryManifest(ctx context.Context, ID uuid.UUID) (*DeliveryManifest, error)
+	RestoreDeliveryManifest(ctx context.Context, ID uuid.UUID) (*DeliveryManifest, error)
+
+	UpdateManifestStatus(ctx context.Context, ID uuid.UUID, status ManifestStatus) (*DeliveryManifest, error)
+}
+
+type ManifestStatus string
+
+const (
+	ManifestDraft     ManifestStatus = "draft"
+	ManifestSubmitted ManifestStatus = "submitted"
+	ManifestAccepted  ManifestStatus = "accepted"
+	ManifestRejected  ManifestStatus = "rejected"
+)
+
+type DeliveryManifest struct {
+	ID             uuid.UUID      `json:"id" db:"id"`
+	CreatedAt      time.Time      `json:"created_at" db:"created_at"`
+	UpdatedAt      time.Time      `json:"updated_at" db:"updated_at"`
+	ManifestDate   time.Time      `json:"manifest_date" db:"manifest_date"`
+	WarehouseID    uuid.UUID      `json:"warehouse_id" db:"warehouse_id"`
+	AirlineID      uuid.UUID      `json:"airline_id" db:"airline_id"`
+	CarrierID      uuid.UUID      `json:"carrier_id" db:"carrier_id"`
+	SignatureInfo  string         `json:"signature_info" db:"signature_info"`
+	ManifestStatus ManifestStatus `json:"manifest_status" db:"manifest_status"`
+	CreatedBy      uuid.UUID      `json:"created_by" db:"created_by"`
+	OrganizationID uuid.UUID      `json:"organization_id" db:"organization_id"`
+	DeletedAt      *time.Time     `json:"deleted_at,omitempty" db:"deleted_at"`
+}
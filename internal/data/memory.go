@@ -0,0 +1,1074 @@
+package data
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// NewMemoryStore returns a Store that keeps every table in memory. It enforces the same
+// primary key, unique and foreign key constraints as the Postgres schema and returns the
+// same not-found errors, so handlers can be tested without a database.
+// Options that only apply to database connections, such as WithQueryTimeout, are ignored.
+func NewMemoryStore(opts ...Option) *Store {
+	m := &memoryDB{
+		mu:       new(sync.Mutex),
+		state:    newMemoryState(),
+		settings: NewDB(nil, opts...),
+	}
+	return newMemoryStore(m)
+}
+
+func newMemoryStore(m *memoryDB) *Store {
+	return &Store{
+		User:             &userMemoryStore{userStoreImpl{}, m},
+		Organization:     &organizationMemoryStore{organizationStoreImpl{}, m},
+		UserAssociation:  &userAssociationMemoryStore{userAssociationStoreImpl{}, m},
+		UldInventory:     &uldInventoryMemoryStore{uldInventoryStoreImpl{}, m},
+		DeliveryManifest: &deliveryManifestMemoryStore{deliveryManifestStoreImpl{}, m},
+		AuditLog:         &auditLogMemoryStore{m},
+		Outbox:           &outboxMemoryStore{m},
+		Idempotency:      &idempotencyMemoryStore{m},
+		memory:           m,
+	}
+}
+
+type memoryState struct {
+	users             map[uuid.UUID]User
+	organizations     map[uuid.UUID]Organization
+	userAssociations  map[uuid.UUID]UserAssociation
+	uldInventories    map[uuid.UUID]UldInventory
+	deliveryManifests map[uuid.UUID]DeliveryManifest
+	auditLog          map[uuid.UUID]AuditEntry
+	outbox            map[uuid.UUID]OutboxEvent
+	idempotencyKeys   map[idempotencyID]IdempotencyKey
+}
+
+type idempotencyID struct {
+	owner, key string
+}
+
+func newMemoryState() *memoryState {
+	return &memoryState{
+		users:             make(map[uuid.UUID]User),
+		organizations:     make(map[uuid.UUID]Organization),
+		userAssociations:  make(map[uuid.UUID]UserAssociation),
+		uldInventories:    make(map[uuid.UUID]UldInventory),
+		deliveryManifests: make(map[uuid.UUID]DeliveryManifest),
+		auditLog:          make(map[uuid.UUID]AuditEntry),
+		outbox:            make(map[uuid.UUID]OutboxEvent),
+		idempotencyKeys:   make(map[idempotencyID]IdempotencyKey),
+	}
+}
+
+func (s *memoryState) clone() *memoryState {
+	c := newMemoryState()
+	for k, v := range s.users {
+		c.users[k] = v
+	}
+	for k, v := range s.organizations {
+		c.organizations[k] = v
+	}
+	for k, v := range s.userAssociations {
+		v.Permissions = slices.Clone(v.Permissions)
+		c.userAssociations[k] = v
+	}
+	for k, v := range s.uldInventories {
+		c.uldInventories[k] = v
+	}
+	for k, v := range s.deliveryManifests {
+		c.deliveryManifests[k] = v
+	}
+	for k, v := range s.auditLog {
+		c.auditLog[k] = v
+	}
+	for k, v := range s.outbox {
+		c.outbox[k] = v
+	}
+	for k, v := range s.idempotencyKeys {
+		c.idempotencyKeys[k] = v
+	}
+	return c
+}
+
+// memoryDB guards the in-memory tables. A transaction holds mu for its whole duration
+// and works on a copy of the state, which replaces the original only on commit.
+type memoryDB struct {
+	mu       *sync.Mutex
+	state    *memoryState
+	settings *DB
+	inTx     bool
+}
+
+func (m *memoryDB) lock() func() {
+	if m.inTx {
+		return func() {}
+	}
+	m.mu.Lock()
+	return m.mu.Unlock
+}
+
+func (m *memoryDB) withTx(ctx context.Context, fn func(tx *Store) error) error {
+	if m.inTx {
+		return fn(newMemoryStore(m))
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	tx := &memoryDB{
+		mu:       m.mu,
+		state:    m.state.clone(),
+		settings: m.settings,
+		inTx:     true,
+	}
+	if err := fn(newMemoryStore(tx)); err != nil {
+		return err
+	}
+
+	m.state = tx.state
+	return nil
+}
+
+// memoryTime matches the microsecond precision of a Postgres timestamptz.
+func memoryTime(t time.Time) time.Time {
+	return t.Truncate(time.Microsecond)
+}
+
+// uniqueViolation returns the error the SQL store reports for a duplicate key.
+func uniqueViolation(table, constraint string) error {
+	return &ConstraintError{Err: ErrConflict, Table: table, Constraint: constraint}
+}
+
+// foreignKeyViolation returns the error the SQL store reports for a missing referenced row.
+func foreignKeyViolation(table, constraint string) error {
+	return &ConstraintError{Err: ErrInvalidReference, Table: table, Constraint: constraint}
+}
+
+// equalCitext compares two CITEXT values.
+func equalCitext(a, b string) bool {
+	return strings.EqualFold(a, b)
+}
+
+func memoryDeleted[T any](item *T) bool {
+	deletedAt, _ := columnValue(item, "deleted_at").(*time.Time)
+	return deletedAt != nil
+}
+
+// memoryVisible reports whether a read in ctx should see item.
+func memoryVisible[T any](ctx context.Context, item *T) bool {
+	return includesDeleted(ctx) || !memoryDeleted(item)
+}
+
+func memorySoftDelete[T any](ctx context.Context, state *memoryState, tableName string, table map[uuid.UUID]T, entity string, id uuid.UUID, extra map[string]any) (*T, error) {
+	item, ok := table[id]
+	if !ok || memoryDeleted(&item) {
+		return nil, notFound(entity, id)
+	}
+
+	now := memoryTime(time.Now().UTC())
+	setColumnValue(&item, "deleted_at", now)
+	setColumnValue(&item, "updated_at", now)
+	for col, v := range extra {
+		setColumnValue(&item, col, v)
+	}
+	if err := memoryWrite(ctx, state, tableName, table, item, AuditDelete); err != nil {
+		return nil, err
+	}
+
+	return &item, nil
+}
+
+func memoryRestore[T any](ctx context.Context, state *memoryState, tableName string, table map[uuid.UUID]T, entity string, id uuid.UUID, extra map[string]any) (*T, error) {
+	item, ok := table[id]
+	if !ok {
+		return nil, notFound(entity, id)
+	}
+
+	setColumnValue(&item, "deleted_at", nil)
+	setColumnValue(&item, "updated_at", memoryTime(time.Now().UTC()))
+	for col, v := range extra {
+		setColumnValue(&item, col, v)
+	}
+	if err := memoryWrite(ctx, state, tableName, table, item, AuditUpdate); err != nil {
+		return nil, err
+	}
+
+	return &item, nil
+}
+
+// memoryWrite stores item in table and records the change in the audit log, as the
+// SQL stores do in the same transaction.
+func memoryWrite[T any](ctx context.Context, state *memoryState, tableName string, table map[uuid.UUID]T, item T, action AuditAction) error {
+	id := rowID(&item)
+
+	var before *T
+	if existing, ok := table[id]; ok {
+		before = &existing
+	}
+	after := &item
+	if action == AuditPurge {
+		after = nil
+	}
+
+	entry, err := newAuditEntry(ctx, tableName, action, id, before, after)
+	if err != nil {
+		return err
+	}
+	entry.CreatedAt = memoryTime(entry.CreatedAt)
+	state.auditLog[entry.ID] = *entry
+
+	if action == AuditPurge {
+		delete(table, id)
+	} else {
+		table[id] = item
+	}
+	return nil
+}
+
+// purgeDeleted mirrors Store.PurgeDeleted, keeping rows a RESTRICT foreign key still references
+// and cascading to user associations.
+func (m *memoryDB) purgeDeleted(ctx context.Context, before time.Time) (int, error) {
+	defer m.lock()()
+
+	purged := 0
+
+	for _, manifest := range m.state.deliveryManifests {
+		if deletedBefore[DeliveryManifest](before)(&manifest) {
+			if err := memoryWrite(ctx, m.state, "delivery_manifests", m.state.deliveryManifests, manifest, AuditPurge); err != nil {
+				return purged, err
+			}
+			purged++
+		}
+	}
+	for _, uld := range m.state.uldInventories {
+		if deletedBefore[UldInventory](before)(&uld) {
+			if err := memoryWrite(ctx, m.state, "uld_inventories", m.state.uldInventories, uld, AuditPurge); err != nil {
+				return purged, err
+			}
+			purged++
+		}
+	}
+
+	for id, org := range m.state.organizations {
+		if !deletedBefore[Organization](before)(&org) || m.state.referencesOrganization(id) {
+			continue
+		}
+		if err := memoryWrite(ctx, m.state, "organizations", m.state.organizations, org, AuditPurge); err != nil {
+			return purged, err
+		}
+		for assocID, assoc := range m.state.userAssociations {
+			if assoc.OrganizationID == id {
+				delete(m.state.userAssociations, assocID)
+			}
+		}
+		purged++
+	}
+
+	for id, user := range m.state.users {
+		if !deletedBefore[User](before)(&user) || m.state.referencesUser(id) {
+			continue
+		}
+		if err := memoryWrite(ctx, m.state, "users", m.state.users, user, AuditPurge); err != nil {
+			return purged, err
+		}
+		for assocID, assoc := range m.state.userAssociations {
+			if assoc.UserID == id {
+				delete(m.state.userAssociations, assocID)
+			}
+		}
+		purged++
+	}
+
+	return purged, nil
+}
+
+func (s *memoryState) referencesOrganization(id uuid.UUID) bool {
+	for _, uld := range s.uldInventories {
+		if uld.OrganizationID == id {
+			return true
+		}
+	}
+	for _, manifest := range s.deliveryManifests {
+		if manifest.OrganizationID == id {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *memoryState) referencesUser(id uuid.UUID) bool {
+	for _, manifest := range s.deliveryManifests {
+		if manifest.CreatedBy == id {
+			return true
+		}
+	}
+	return false
+}
+
+type userMemoryStore struct {
+	userStoreImpl
+	m *memoryDB
+}
+
+func (s *userMemoryStore) CreateUser(ctx context.Context, u *User) (*User, error) {
+	defer s.m.lock()()
+
+	if _, ok := s.m.state.users[u.ID]; ok {
+		return nil, uniqueViolation("users", "users_pkey")
+	}
+	for _, existing := range s.m.state.users {
+		if equalCitext(existing.UserName, u.UserName) {
+			return nil, uniqueViolation("users", "users_user_name_key")
+		}
+		if equalCitext(existing.Email, u.Email) {
+			return nil, uniqueViolation("users", "users_email_key")
+		}
+	}
+
+	user := *u
+	user.CreatedAt = memoryTime(user.CreatedAt)
+	user.UpdatedAt = memoryTime(user.UpdatedAt)
+	user.LastRequest = memoryTime(user.LastRequest)
+	user.LastLogin = memoryTime(user.LastLogin)
+	if err := memoryWrite(ctx, s.m.state, "users", s.m.state.users, user, AuditInsert); err != nil {
+		return nil, err
+	}
+
+	return &user, nil
+}
+
+func (s *userMemoryStore) UpdateUser(ctx context.Context, u *User, expectedUpdatedAt time.Time) (*User, error) {
+	return s.PatchUser(ctx, u.ID, userUpdatePatch(u), expectedUpdatedAt)
+}
+
+func (s *userMemoryStore) PatchUser(ctx context.Context, ID uuid.UUID, patch Patch, expectedUpdatedAt time.Time) (*User, error) {
+	if err := patch.validate("users"); err != nil {
+		return nil, err
+	}
+
+	defer s.m.lock()()
+
+	user, ok := s.m.state.users[ID]
+	if !ok || memoryDeleted(&user) {
+		return nil, notFound("user", ID)
+	}
+	if !expectedUpdatedAt.IsZero() && !user.UpdatedAt.Equal(expectedUpdatedAt) {
+		return nil, ErrPreconditionFailed
+	}
+
+	for id, existing := range s.m.state.users {
+		if id == ID {
+			continue
+		}
+		if v, ok := patch["user_name"].(string); ok && equalCitext(existing.UserName, v) {
+			return nil, uniqueViolation("users", "users_user_name_key")
+		}
+		if v, ok := patch["email"].(string); ok && equalCitext(existing.Email, v) {
+			return nil, uniqueViolation("users", "users_email_key")
+		}
+	}
+
+	memoryApplyPatch(&user, patch)
+	if err := memoryWrite(ctx, s.m.state, "users", s.m.state.users, user, AuditUpdate); err != nil {
+		return nil, err
+	}
+
+	return &user, nil
+}
+
+func (s *userMemoryStore) GetUserByEmail(ctx context.Context, email string) (*User, error) {
+	defer s.m.lock()()
+
+	for _, user := range s.m.state.users {
+		if equalCitext(user.Email, email) && memoryVisible(ctx, &user) {
+			return &user, nil
+		}
+	}
+	return nil, notFound("user", email)
+}
+
+func (s *userMemoryStore) GetUserByID(ctx context.Context, ID uuid.UUID) (*User, error) {
+	defer s.m.lock()()
+
+	if user, ok := s.m.state.users[ID]; ok && memoryVisible(ctx, &user) {
+		return &user, nil
+	}
+	return nil, notFound("user", ID)
+}
+
+func (s *userMemoryStore) DeleteUser(ctx context.Context, ID uuid.UUID) (*User, error) {
+	defer s.m.lock()()
+
+	return memorySoftDelete(ctx, s.m.state, "users", s.m.state.users, "user", ID, map[string]any{"is_deleted": true})
+}
+
+func (s *userMemoryStore) RestoreUser(ctx context.Context, ID uuid.UUID) (*User, error) {
+	defer s.m.lock()()
+
+	return memoryRestore(ctx, s.m.state, "users", s.m.state.users, "user", ID, map[string]any{"is_deleted": false})
+}
+
+type organizationMemoryStore struct {
+	organizationStoreImpl
+	m *memoryDB
+}
+
+func (s *organizationMemoryStore) CreateOrganization(ctx context.Context, o *Organization) (*Organization, error) {
+	defer s.m.lock()()
+
+	if _, ok := s.m.state.organizations[o.ID]; ok {
+		return nil, uniqueViolation("organizations", "organizations_pkey")
+	}
+	for _, existing := range s.m.state.organizations {
+		if equalCitext(existing.UniqueURL, o.UniqueURL) {
+			return nil, uniqueViolation("organizations", "organizations_unique_url_key")
+		}
+	}
+
+	org := *o
+	org.CreatedAt = memoryTime(org.CreatedAt)
+	org.UpdatedAt = memoryTime(org.UpdatedAt)
+	if err := memoryWrite(ctx, s.m.state, "organizations", s.m.state.organizations, org, AuditInsert); err != nil {
+		return nil, err
+	}
+
+	return &org, nil
+}
+
+func (s *organizationMemoryStore) UpdateOrganization(ctx context.Context, o *Organization, expectedUpdatedAt time.Time) (*Organization, error) {
+	return s.PatchOrganization(ctx, o.ID, organizationUpdatePatch(o), expectedUpdatedAt)
+}
+
+func (s *organizationMemoryStore) PatchOrganization(ctx context.Context, ID uuid.UUID, patch Patch, expectedUpdatedAt time.Time) (*Organization, error) {
+	if err := patch.validate("organizations"); err != nil {
+		return nil, err
+	}
+
+	defer s.m.lock()()
+
+	org, ok := s.m.state.organizations[ID]
+	if !ok || memoryDeleted(&org) {
+		return nil, notFound("organization", ID)
+	}
+	if !expectedUpdatedAt.IsZero() && !org.UpdatedAt.Equal(expectedUpdatedAt) {
+		return nil, ErrPreconditionFailed
+	}
+
+	if v, ok := patch["unique_url"].(string); ok {
+		for id, existing := range s.m.state.organizations {
+			if id != ID && equalCitext(existing.UniqueURL, v) {
+				return nil, uniqueViolation("organizations", "organizations_unique_url_key")
+			}
+		}
+	}
+
+	memoryApplyPatch(&org, patch)
+	if err := memoryWrite(ctx, s.m.state, "organizations", s.m.state.organizations, org, AuditUpdate); err != nil {
+		return nil, err
+	}
+
+	return &org, nil
+}
+
+// memoryApplyPatch writes patch to item along with a new updated_at. Times are truncated
+// like a timestamptz would, and NULL reads back as the zero value.
+func memoryApplyPatch[T any](item *T, patch Patch) {
+	for column, value := range patch {
+		if t, ok := value.(time.Time); ok {
+			value = memoryTime(t)
+		}
+		setColumnValue(item, column, value)
+	}
+	setColumnValue(item, "updated_at", memoryTime(time.Now().UTC()))
+}
+
+func (s *organizationMemoryStore) GetOrganizationByID(ctx context.Context, ID uuid.UUID) (*Organization, error) {
+	defer s.m.lock()()
+
+	if org, ok := s.m.state.organizations[ID]; ok && memoryVisible(ctx, &org) {
+		return &org, nil
+	}
+	return nil, notFound("organization", ID)
+}
+
+func (s *organizationMemoryStore) GetOrganizationByName(ctx context.Context, name string) (*Organization, error) {
+	defer s.m.lock()()
+
+	for _, org := range s.m.state.organizations {
+		if org.Name == name && memoryVisible(ctx, &org) {
+			return &org, nil
+		}
+	}
+	return nil, notFound("organization", name)
+}
+
+func (s *organizationMemoryStore) GetOrganizationByUniqueURL(ctx context.Context, uniqueURL string) (*Organization, error) {
+	defer s.m.lock()()
+
+	for _, org := range s.m.state.organizations {
+		if equalCitext(org.UniqueURL, uniqueURL) && memoryVisible(ctx, &org) {
+			return &org, nil
+		}
+	}
+	return nil, notFound("organization", uniqueURL)
+}
+
+func (s *organizationMemoryStore) ListOrganizations(ctx context.Context, page PageRequest) (*Page[Organization], error) {
+	defer s.m.lock()()
+
+	return memoryPage(ctx, s.m, s.m.state.organizations, "organizations", page)
+}
+
+func (s *organizationMemoryStore) ListOrganizationsForUser(ctx context.Context, userID uuid.UUID, page PageRequest) (*Page[Organization], error) {
+	defer s.m.lock()()
+
+	member := make(map[uuid.UUID]Organization)
+	for _, a := range s.m.state.userAssociations {
+		if org, ok := s.m.state.organizations[a.OrganizationID]; ok && a.UserID == userID && a.Status == AssociationActive {
+			member[org.ID] = org
+		}
+	}
+	return memoryPage(ctx, s.m, member, "organizations", page)
+}
+
+func (s *organizationMemoryStore) DeleteOrganization(ctx context.Context, ID uuid.UUID) (*Organization, error) {
+	defer s.m.lock()()
+
+	return memorySoftDelete(ctx, s.m.state, "organizations", s.m.state.organizations, "organization", ID, nil)
+}
+
+func (s *organizationMemoryStore) RestoreOrganization(ctx context.Context, ID uuid.UUID) (*Organization, error) {
+	defer s.m.lock()()
+
+	return memoryRestore(ctx, s.m.state, "organizations", s.m.state.organizations, "organization", ID, nil)
+}
+
+type userAssociationMemoryStore struct {
+	userAssociationStoreImpl
+	m *memoryDB
+}
+
+func (s *userAssociationMemoryStore) CreateUserAssociation(ctx context.Context, a *UserAssociation) (*UserAssociation, error) {
+	defer s.m.lock()()
+
+	if _, ok := s.m.state.userAssociations[a.ID]; ok {
+		return nil, uniqueViolation("user_associations", "user_associations_pkey")
+	}
+	for _, existing := range s.m.state.userAssociations {
+		if existing.UserID == a.UserID && existing.OrganizationID == a.OrganizationID {
+			return nil, uniqueViolation("user_associations", "unique_user_org")
+		}
+	}
+	if _, ok := s.m.state.users[a.UserID]; !ok {
+		return nil, foreignKeyViolation("user_associations", "fk_user")
+	}
+	if _, ok := s.m.state.organizations[a.OrganizationID]; !ok {
+		return nil, foreignKeyViolation("user_associations", "fk_user_association_organization")
+	}
+
+	assoc := *a
+	assoc.CreatedAt = memoryTime(assoc.CreatedAt)
+	assoc.UpdatedAt = memoryTime(assoc.UpdatedAt)
+	assoc.Permissions = slices.Clone(assoc.Permissions)
+	if err := memoryWrite(ctx, s.m.state, "user_associations", s.m.state.userAssociations, assoc, AuditInsert); err != nil {
+		return nil, err
+	}
+
+	return &assoc, nil
+}
+
+func (s *userAssociationMemoryStore) GetUserAssociation(ctx context.Context, userID, organizationID uuid.UUID) (*UserAssociation, error) {
+	defer s.m.lock()()
+
+	for _, assoc := range s.m.state.userAssociations {
+		if assoc.UserID == userID && assoc.OrganizationID == organizationID {
+			assoc.Permissions = slices.Clone(assoc.Permissions)
+			return &assoc, nil
+		}
+	}
+	return nil, fmt.Errorf("user %s association with organization %s %w", userID, organizationID, ErrNotFound)
+}
+
+type uldInventoryMemoryStore struct {
+	uldInventoryStoreImpl
+	m *memoryDB
+}
+
+func (s *uldInventoryMemoryStore) CreateUldInventory(ctx context.Context, u *UldInventory) (*UldInventory, error) {
+	defer s.m.lock()()
+
+	if _, ok := s.m.state.uldInventories[u.ID]; ok {
+		return nil, uniqueViolation("uld_inventories", "uld_inventories_pkey")
+	}
+	for _, existing := range s.m.state.uldInventories {
+		if existing.UldNumber == u.UldNumber {
+			return nil, uniqueViolation("uld_inventories", "uld_inventories_uld_number_key")
+		}
+	}
+	if _, ok := s.m.state.organizations[u.OrganizationID]; !ok {
+		return nil, foreignKeyViolation("uld_inventories", "fk_uld_organization")
+	}
+
+	uld := *u
+	uld.CreatedAt = memoryTime(uld.CreatedAt)
+	uld.UpdatedAt = memoryTime(uld.UpdatedAt)
+	if err := memoryWrite(ctx, s.m.state, "uld_inventories", s.m.state.uldInventories, uld, AuditInsert); err != nil {
+		return nil, err
+	}
+
+	return &uld, nil
+}
+
+func (s *uldInventoryMemoryStore) GetUldInventoryByID(ctx context.Context, ID uuid.UUID) (*UldInventory, error) {
+	defer s.m.lock()()
+
+	if uld, ok := s.m.state.uldInventories[ID]; ok && memoryVisible(ctx, &uld) {
+		return &uld, nil
+	}
+	return nil, notFound("uld", ID)
+}
+
+func (s *uldInventoryMemoryStore) ListUldInventories(ctx context.Context, page PageRequest) (*Page[UldInventory], error) {
+	defer s.m.lock()()
+
+	return memoryPage(ctx, s.m, s.m.state.uldInventories, "uld_inventories", page)
+}
+
+func (s *uldInventoryMemoryStore) DeleteUldInventory(ctx context.Context, ID uuid.UUID) (*UldInventory, error) {
+	defer s.m.lock()()
+
+	return memorySoftDelete(ctx, s.m.state, "uld_inventories", s.m.state.uldInventories, "uld", ID, nil)
+}
+
+func (s *uldInventoryMemoryStore) RestoreUldInventory(ctx context.Context, ID uuid.UUID) (*UldInventory, error) {
+	defer s.m.lock()()
+
+	return memoryRestore(ctx, s.m.state, "uld_inventories", s.m.state.uldInventories, "uld", ID, nil)
+}
+
+func (s *uldInventoryMemoryStore) UpdateUldStatus(ctx context.Context, ID uuid.UUID, status UldStatus) (*UldInventory, error) {
+	defer s.m.lock()()
+
+	uld, ok := s.m.state.uldInventories[ID]
+	if !ok || memoryDeleted(&uld) {
+		return nil, notFound("uld", ID)
+	}
+	if uld.UldStatus == status {
+		return &uld, nil
+	}
+
+	from := uld.UldStatus
+	uld.UldStatus = status
+	uld.UpdatedAt = memoryTime(time.Now().UTC())
+	if err := memoryWrite(ctx, s.m.state, "uld_inventories", s.m.state.uldInventories, uld, AuditUpdate); err != nil {
+		return nil, err
+	}
+
+	_, err := s.m.state.enqueue("uld", ID, EventUldStatusChanged, UldStatusChanged{UldID: ID, From: from, To: status})
+	if err != nil {
+		return nil, err
+	}
+	return &uld, nil
+}
+
+// deliveryManifestMemoryStore checks the created_by and organization_id foreign keys.
+// Warehouses, airlines and carriers have no store yet, so those references are not checked.
+type deliveryManifestMemoryStore struct {
+	deliveryManifestStoreImpl
+	m *memoryDB
+}
+
+func (s *deliveryManifestMemoryStore) CreateDeliveryManifest(ctx context.Context, dm *DeliveryManifest) (*DeliveryManifest, error) {
+	defer s.m.lock()()
+
+	if _, ok := s.m.state.deliveryManifests[dm.ID]; ok {
+		return nil, uniqueViolation("delivery_manifests", "delivery_manifests_pkey")
+	}
+	if _, ok := s.m.state.users[dm.CreatedBy]; !ok {
+		return nil, foreignKeyViolation("delivery_manifests", "fk_created_by")
+	}
+	if _, ok := s.m.state.organizations[dm.OrganizationID]; !ok {
+		return nil, foreignKeyViolation("delivery_manifests", "fk_delivery_manifest_organization")
+	}
+
+	manifest := *dm
+	manifest.CreatedAt = memoryTime(manifest.CreatedAt)
+	manifest.UpdatedAt = memoryTime(manifest.UpdatedAt)
+	manifest.ManifestDate = memoryTime(manifest.ManifestDate)
+	if err := memoryWrite(ctx, s.m.state, "delivery_manifests", s.m.state.deliveryManifests, manifest, AuditInsert); err != nil {
+		return nil, err
+	}
+
+	return &manifest, nil
+}
+
+func (s *deliveryManifestMemoryStore) GetDeliveryManifestByID(ctx context.Context, ID uuid.UUID) (*DeliveryManifest, error) {
+	defer s.m.lock()()
+
+	if manifest, ok := s.m.state.deliveryManifests[ID]; ok && memoryVisible(ctx, &manifest) {
+		return &manifest, nil
+	}
+	return nil, notFound("manifest", ID)
+}
+
+func (s *deliveryManifestMemoryStore) ListDeliveryManifests(ctx context.Context, page PageRequest) (*Page[DeliveryManifest], error) {
+	defer s.m.lock()()
+
+	return memoryPage(ctx, s.m, s.m.state.deliveryManifests, "delivery_manifests", page)
+}
+
+func (s *deliveryManifestMemoryStore) DeleteDeliveryManifest(ctx context.Context, ID uuid.UUID) (*DeliveryManifest, error) {
+	defer s.m.lock()()
+
+	return memorySoftDelete(ctx, s.m.state, "delivery_manifests", s.m.state.deliveryManifests, "manifest", ID, nil)
+}
+
+func (s *deliveryManifestMemoryStore) RestoreDeliveryManifest(ctx context.Context, ID uuid.UUID) (*DeliveryManifest, error) {
+	defer s.m.lock()()
+
+	return memoryRestore(ctx, s.m.state, "delivery_manifests", s.m.state.deliveryManifests, "manifest", ID, nil)
+}
+
+type auditLogMemoryStore struct {
+	m *memoryDB
+}
+
+func (s *auditLogMemoryStore) ListAuditLog(ctx context.Context, page PageRequest) (*Page[AuditEntry], error) {
+	defer s.m.lock()()
+
+	return memoryPage(ctx, s.m, s.m.state.auditLog, "audit_log", page)
+}
+
+func (s *deliveryManifestMemoryStore) UpdateManifestStatus(ctx context.Context, ID uuid.UUID, status ManifestStatus) (*DeliveryManifest, error) {
+	defer s.m.lock()()
+
+	manifest, ok := s.m.state.deliveryManifests[ID]
+	if !ok || memoryDeleted(&manifest) {
+		return nil, notFound("manifest", ID)
+	}
+	if err := checkManifestTransition(manifest.ManifestStatus, status); err != nil {
+		return nil, err
+	}
+
+	from := manifest.ManifestStatus
+	manifest.ManifestStatus = status
+	manifest.UpdatedAt = memoryTime(time.Now().UTC())
+	if err := memoryWrite(ctx, s.m.state, "delivery_manifests", s.m.state.deliveryManifests, manifest, AuditUpdate); err != nil {
+		return nil, err
+	}
+
+	_, err := s.m.state.enqueue("manifest", ID, manifestEvents[status], ManifestStatusChanged{
+		ManifestID:     ID,
+		OrganizationID: manifest.OrganizationID,
+		From:           from,
+		To:             status,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &manifest, nil
+}
+
+func (s *memoryState) enqueue(aggregateType string, aggregateID uuid.UUID, eventType string, payload any) (*OutboxEvent, error) {
+	event, err := newOutboxEvent(aggregateType, aggregateID, eventType, payload)
+	if err != nil {
+		return nil, err
+	}
+	event.CreatedAt = memoryTime(event.CreatedAt)
+	event.NextAttemptAt = memoryTime(event.NextAttemptAt)
+	s.outbox[event.ID] = *event
+	return event, nil
+}
+
+type outboxMemoryStore struct {
+	m *memoryDB
+}
+
+func (s *outboxMemoryStore) Enqueue(ctx context.Context, aggregateType string, aggregateID uuid.UUID, eventType string, payload any) (*OutboxEvent, error) {
+	defer s.m.lock()()
+
+	return s.m.state.enqueue(aggregateType, aggregateID, eventType, payload)
+}
+
+func (s *outboxMemoryStore) ClaimPending(ctx context.Context, limit int, lease time.Duration) ([]*OutboxEvent, error) {
+	defer s.m.lock()()
+
+	heads := make(map[uuid.UUID]OutboxEvent)
+	for _, event := range s.m.state.outbox {
+		if event.PublishedAt != nil {
+			continue
+		}
+		head, ok := heads[event.AggregateID]
+		if !ok || compareEvents(&event, &head) < 0 {
+			heads[event.AggregateID] = event
+		}
+	}
+
+	now := time.Now().UTC()
+	events := []*OutboxEvent{}
+	for _, event := range heads {
+		if !event.NextAttemptAt.After(now) {
+			events = append(events, &event)
+		}
+	}
+	slices.SortFunc(events, compareEvents)
+	if len(events) > limit {
+		events = events[:limit]
+	}
+
+	for _, event := range events {
+		event.NextAttemptAt = memoryTime(now.Add(lease))
+		s.m.state.outbox[event.ID] = *event
+	}
+	return events, nil
+}
+
+func (s *outboxMemoryStore) MarkPublished(ctx context.Context, ID uuid.UUID) error {
+	defer s.m.lock()()
+
+	event, ok := s.m.state.outbox[ID]
+	if !ok {
+		return notFound("event", ID)
+	}
+	now := memoryTime(time.Now().UTC())
+	event.PublishedAt = &now
+	event.LastError = ""
+	s.m.state.outbox[ID] = event
+
+	return nil
+}
+
+func (s *outboxMemoryStore) MarkFailed(ctx context.Context, ID uuid.UUID, cause error, nextAttemptAt time.Time) error {
+	defer s.m.lock()()
+
+	event, ok := s.m.state.outbox[ID]
+	if !ok {
+		return notFound("event", ID)
+	}
+	event.Attempts++
+	event.LastError = cause.Error()
+	event.NextAttemptAt = memoryTime(nextAttemptAt)
+	s.m.state.outbox[ID] = event
+
+	return nil
+}
+
+type idempotencyMemoryStore struct {
+	m *memoryDB
+}
+
+func (s *idempotencyMemoryStore) Claim(ctx context.Context, owner, key, requestHash string, lease, ttl time.Duration) (*IdempotencyKey, bool, error) {
+	defer s.m.lock()()
+
+	id := idempotencyID{owner, key}
+	now := memoryTime(time.Now().UTC())
+	if record, ok := s.m.state.idempotencyKeys[id]; ok && !record.lapsed(now) {
+		return &record, false, nil
+	}
+
+	lockedUntil := memoryTime(now.Add(lease))
+	record := IdempotencyKey{
+		Owner:       owner,
+		Key:         key,
+		RequestHash: requestHash,
+		CreatedAt:   now,
+		ExpiresAt:   memoryTime(now.Add(ttl)),
+		LockedUntil: &lockedUntil,
+	}
+	s.m.state.idempotencyKeys[id] = record
+	return &record, true, nil
+}
+
+func (s *idempotencyMemoryStore) Complete(ctx context.Context, owner, key string, status int, header StoredHeader, body []byte) error {
+	defer s.m.lock()()
+
+	id := idempotencyID{owner, key}
+	record, ok := s.m.state.idempotencyKeys[id]
+	if !ok || record.Completed() {
+		return notFound("idempotency key", key)
+	}
+	record.Status = status
+	record.LockedUntil = nil
+	record.Header = header
+	record.Body = slices.Clone(body)
+	s.m.state.idempotencyKeys[id] = record
+
+	return nil
+}
+
+func (s *idempotencyMemoryStore) Release(ctx context.Context, owner, key string) error {
+	defer s.m.lock()()
+
+	id := idempotencyID{owner, key}
+	if record, ok := s.m.state.idempotencyKeys[id]; ok && !record.Completed() {
+		delete(s.m.state.idempotencyKeys, id)
+	}
+	return nil
+}
+
+func (s *idempotencyMemoryStore) PurgeExpired(ctx context.Context, before time.Time) (int, error) {
+	defer s.m.lock()()
+
+	purged := 0
+	for id, record := range s.m.state.idempotencyKeys {
+		if record.ExpiresAt.Before(before) {
+			delete(s.m.state.idempotencyKeys, id)
+			purged++
+		}
+	}
+	return purged, nil
+}
+
+// memoryPage applies a PageRequest to an in-memory table the same way BuildPageQuery does in SQL.
+func memoryPage[T any](ctx context.Context, m *memoryDB, table map[uuid.UUID]T, tableName string, req PageRequest) (*Page[T], error) {
+	req.Limit = m.settings.pageLimit(req.Limit)
+	req.IncludeDeleted = includesDeleted(ctx)
+
+	// Validate the request exactly as the SQL store would.
+	if _, _, err := BuildPageQuery(tableName, nil, req); err != nil {
+		return nil, err
+	}
+
+	sortColumn, desc := "id", false
+	if req.Sort != nil {
+		sortColumn, desc = req.Sort.Column, req.Sort.Desc
+	}
+	backward := req.Cursor != nil && req.Cursor.Backward
+	if backward {
+		desc = !desc
+	}
+
+	compareItems := func(a, b *T) int {
+		c := compareValues(columnValue(a, sortColumn), columnValue(b, sortColumn))
+		if c == 0 {
+			c = compareValues(columnValue(a, "id"), columnValue(b, "id"))
+		}
+		if desc {
+			return -c
+		}
+		return c
+	}
+
+	var cursorKey any
+	if req.Cursor != nil && req.Sort != nil {
+		col, _ := lookupColumn(tableName, sortColumn)
+		key, err := col.parse(fmt.Sprint(req.Cursor.Key))
+		if err != nil {
+			return nil, fmt.Errorf("invalid cursor")
+		}
+		cursorKey = key
+	}
+
+	items := make([]*T, 0, len(table))
+	for _, row := range table {
+		item := row
+		if !matchesFilters(&item, req.Filters) || (!req.IncludeDeleted && memoryDeleted(&item)) {
+			continue
+		}
+		if req.Cursor != nil {
+			c := compareValues(columnValue(&item, "id"), req.Cursor.ID)
+			if req.Sort != nil {
+				if k := compareValues(columnValue(&item, sortColumn), cursorKey); k != 0 {
+					c = k
+				}
+			}
+			if (desc && c >= 0) || (!desc && c <= 0) {
+				continue
+			}
+		}
+		items = append(items, &item)
+	}
+	slices.SortFunc(items, compareItems)
+
+	if len(items) > req.Limit+1 {
+		items = items[:req.Limit+1]
+	}
+
+	return buildPage(items, columnValue[T], req), nil
+}
+
+func matchesFilters[T any](item *T, filters []Filter) bool {
+	for _, f := range filters {
+		value := columnValue(item, f.Column)
+		if v := reflect.ValueOf(value); v.Kind() == reflect.Pointer {
+			// Comparisons with NULL are never true in SQL.
+			if v.IsNil() {
+				return false
+			}
+			value = v.Elem().Interface()
+		}
+		switch f.Op {
+		case OpIn:
+			if !slices.ContainsFunc(f.Value.([]any), func(v any) bool { return compareValues(value, v) == 0 }) {
+				return false
+			}
+		case OpEq:
+			if compareValues(value, f.Value) != 0 {
+				return false
+			}
+		case OpNe:
+			if compareValues(value, f.Value) == 0 {
+				return false
+			}
+		case OpGt:
+			if compareValues(value, f.Value) <= 0 {
+				return false
+			}
+		case OpGte:
+			if compareValues(value, f.Value) < 0 {
+				return false
+			}
+		case OpLt:
+			if compareValues(value, f.Value) >= 0 {
+				return false
+			}
+		case OpLte:
+			if compareValues(value, f.Value) > 0 {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// compareValues orders two column values of the same kind.
+func compareValues(a, b any) int {
+	switch a := a.(type) {
+	case time.Time:
+		return a.Compare(b.(time.Time))
+	case uuid.UUID:
+		b := b.(uuid.UUID)
+		return strings.Compare(string(a[:]), string(b[:]))
+	case bool:
+		b := b.(bool)
+		switch {
+		case a == b:
+			return 0
+		case !a:
+			return -1
+		default:
+			return 1
+		}
+	}
+
+	av, bv := reflect.ValueOf(a), reflect.ValueOf(b)
+	switch av.Kind() {
+	case reflect.String:
+		return strings.Compare(av.String(), bv.String())
+	case reflect.Int, reflect.Int64, reflect.Int32:
+		return cmp.Compare(av.Int(), bv.Int())
+	}
+	return 0
+}
@@ -1,7 +1,9 @@
 package data
 
 import (
+	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"time"
 
@@ -29,7 +31,7 @@ func (s *organizationStoreImpl) CreateRequest(name, address, contactInfo string,
 	}, nil
 }
 
-func (s *organizationStoreImpl) CreateOrganization(o *Organization) (*Organization, error) {
+func (s *organizationStoreImpl) CreateOrganization(ctx context.Context, o *Organization) (*Organization, error) {
 
 	data := map[string]any{
 		"id":                o.ID,
@@ -42,21 +44,11 @@ func (s *organizationStoreImpl) CreateOrganization(o *Organization) (*Organizati
 		"organization_type": o.OrganizationType,
 	}
 
-	query, values, err := BuildInsertQuery("organizations", data)
-	if err != nil {
-		return nil, err
+	org, err := insertRow[Organization](ctx, s.db, "organizations", data)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("failed to create organization")
 	}
-
-	rows, err := s.db.Query(query, values...)
-	if err != nil {
-		return nil, err
-	}
-
-	if rows.Next() {
-		return scanIntoOrganization(rows)
-	}
-
-	return nil, fmt.Errorf("failed to create organization")
+	return org, err
 
 }
 
@@ -83,176 +75,168 @@ func (s *organizationStoreImpl) UpdateRequest(name, uniqueURL, address, contactI
 	return o, nil
 }
 
-func (s *organizationStoreImpl) UpdateOrganization(o *Organization) (*Organization, error) {
+// UpdateOrganization writes the non-zero fields of o. Use PatchOrganization to write zero
+// values. When expectedUpdatedAt is non-zero the update only applies if the row's updated_at
+// still matches it, and ErrPreconditionFailed is returned otherwise.
+func (s *organizationStoreImpl) UpdateOrganization(ctx context.Context, o *Organization, expectedUpdatedAt time.Time) (*Organization, error) {
+	return s.PatchOrganization(ctx, o.ID, organizationUpdatePatch(o), expectedUpdatedAt)
+}
+
+// PatchOrganization writes every column in patch, including zero values and NULLs, with the
+// same precondition as UpdateOrganization.
+func (s *organizationStoreImpl) PatchOrganization(ctx context.Context, ID uuid.UUID, patch Patch, expectedUpdatedAt time.Time) (*Organization, error) {
+	org, err := patchRow[Organization](ctx, s.db, "organizations", ID, patch, expectedUpdatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, notFound("organization", ID)
+	}
+	return org, err
+}
 
-	updateData := make(map[string]any)
-	updateData["updated_at"] = time.Now().UTC()
+// organizationUpdatePatch returns a Patch of the non-zero fields of o.
+func organizationUpdatePatch(o *Organization) Patch {
+	patch := Patch{}
 
 	if o.Name != "" {
-		updateData["name"] = o.Name
+		patch["name"] = o.Name
 	}
 	if o.UniqueURL != "" {
-		updateData["unique_url"] = o.UniqueURL
+		patch["unique_url"] = o.UniqueURL
 	}
 	if o.Address != "" {
-		updateData["address"] = o.Address
+		patch["address"] = o.Address
 	}
 	if o.ContactInfo != "" {
-		updateData["contact_info"] = o.ContactInfo
+		patch["contact_info"] = o.ContactInfo
 	}
 	if o.OrganizationType != "" {
-		updateData["organization_type"] = o.OrganizationType
+		patch["organization_type"] = o.OrganizationType
 	}
 
-	conditions := map[string]any{
-		"id": o.ID,
-	}
-
-	query, values, err := BuildUpdateQuery("organizations", updateData, conditions)
-	if err != nil {
-		return nil, err
-	}
-
-	rows, err := s.db.Query(query, values...)
-	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
-
-	if rows.Next() {
-		return scanIntoOrganization(rows)
-	}
-
-	return nil, fmt.Errorf("failed to update organization")
-
+	return patch
 }
 
-func (s *organizationStoreImpl) GetOrganizationByID(ID uuid.UUID) (*Organization, error) {
+func (s *organizationStoreImpl) GetOrganizationByID(ctx context.Context, ID uuid.UUID) (*Organization, error) {
 
 	data := map[string]any{
 		"ID": ID,
 	}
 
-	query, values, err := BuildSelectQuery("organizations", data)
+	query, values, err := buildSelectQuery("organizations", data, includesDeleted(ctx))
 	if err != nil {
 		return nil, err
 	}
 
-	rows, err := s.db.Query(query, values...)
-	if err != nil {
-		return nil, err
-	}
-
-	if rows.Next() {
-		return scanIntoOrganization(rows)
+	org, err := queryOne(ctx, s.db.reader(ctx), scanRow[Organization], query, values...)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, notFound("organization", ID)
 	}
-
-	return nil, fmt.Errorf("organization %s not found", ID)
+	return org, err
 
 }
 
-func (s *organizationStoreImpl) GetOrganizationByName(name string) (*Organization, error) {
+func (s *organizationStoreImpl) GetOrganizationByName(ctx context.Context, name string) (*Organization, error) {
 
 	data := map[string]any{
 		"name": name,
 	}
 
-	query, values, err := BuildSelectQuery("organizations", data)
+	query, values, err := buildSelectQuery("organizations", data, includesDeleted(ctx))
 	if err != nil {
 		return nil, err
 	}
 
-	rows, err := s.db.Query(query, values...)
-	if err != nil {
-		return nil, err
+	org, err := queryOne(ctx, s.db.reader(ctx), scanRow[Organization], query, values...)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, notFound("organization", name)
 	}
-
-	if rows.Next() {
-		return scanIntoOrganization(rows)
-	}
-
-	return nil, fmt.Errorf("organization %s not found", name)
+	return org, err
 
 }
 
-func (s *organizationStoreImpl) GetOrganizationByUniqueURL(uniqueURL string) (*Organization, error) {
+func (s *organizationStoreImpl) GetOrganizationByUniqueURL(ctx context.Context, uniqueURL string) (*Organization, error) {
 
 	data := map[string]any{
 		"unique_url": uniqueURL,
 	}
 
-	query, values, err := BuildSelectQuery("organizations", data)
+	query, values, err := buildSelectQuery("organizations", data, includesDeleted(ctx))
 	if err != nil {
 		return nil, err
 	}
 
-	rows, err := s.db.Query(query, values...)
-	if err != nil {
-		return nil, err
+	org, err := queryOne(ctx, s.db.reader(ctx), scanRow[Organization], query, values...)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, notFound("organization", uniqueURL)
 	}
+	return org, err
 
-	if rows.Next() {
-		return scanIntoOrganization(rows)
-	}
+}
+
+func (s *organizationStoreImpl) ListOrganizations(ctx context.Context, page PageRequest) (*Page[Organization], error) {
+	return queryPage(ctx, s.db, scanRow[Organization], columnValue[Organization], "organizations", nil, page)
+}
 
-	return nil, fmt.Errorf("organization %s not found", uniqueURL)
+// ListOrganizationsForUser lists the organizations userID is an active member of.
+func (s *organizationStoreImpl) ListOrganizationsForUser(ctx context.Context, userID uuid.UUID, page PageRequest) (*Page[Organization], error) {
+	conditions := map[string]any{"id": inSubquery{
+		query: "SELECT organization_id FROM user_associations WHERE user_id = %s AND status = %s",
+		args:  []any{userID, AssociationActive},
+	}}
+	return queryPage(ctx, s.db, scanRow[Organization], columnValue[Organization], "organizations", conditions, page)
+}
+
+func (s *organizationStoreImpl) DeleteOrganization(ctx context.Context, ID uuid.UUID) (*Organization, error) {
+	return softDelete[Organization](ctx, s.db, "organizations", "organization", ID, nil)
+}
 
+func (s *organizationStoreImpl) RestoreOrganization(ctx context.Context, ID uuid.UUID) (*Organization, error) {
+	return restore[Organization](ctx, s.db, "organizations", "organization", ID, nil)
 }
 
 type organizationStoreImpl struct {
-	db *sql.DB
+	db *DB
 }
 
-var NewOrganizationStore = func(db *sql.DB) OrganizationStore {
+var NewOrganizationStore = func(db *DB) OrganizationStore {
 	return &organizationStoreImpl{
 		db: db,
 	}
 }
 
 type OrganizationStore interface {
-	GetOrganizationByID(ID uuid.UUID) (*Organization, error)
-	GetOrganizationByName(name string) (*Organization, error)
-	GetOrganizationByUniqueURL(uniqueURL string) (*Organization, error)
+	GetOrganizationByID(ctx context.Context, ID uuid.UUID) (*Organization, error)
+	GetOrganizationByName(ctx context.Context, name string) (*Organization, error)
+	GetOrganizationByUniqueURL(ctx context.Context, uniqueURL string) (*Organization, error)
+	ListOrganizations(ctx context.Context, page PageRequest) (*Page[Organization], error)
+	ListOrganizationsForUser(ctx context.Context, userID uuid.UUID, page PageRequest) (*Page[Organization], error)
 
-	CreateOrganization(o *Organization) (*Organization, error)
+	CreateOrganization(ctx context.Context, o *Organization) (*Organization, error)
 	CreateRequest(name, address, contactInfo string, organizationType OrganizationType) (*Organization, error)
 
-	UpdateOrganization(o *Organization) (*Organization, error)
+	UpdateOrganization(ctx context.Context, o *Organization, expectedUpdatedAt time.Time) (*Organization, error)
+	PatchOrganization(ctx context.Context, ID uuid.UUID, patch Patch, expectedUpdatedAt time.Time) (*Organization, error)
 	UpdateRequest(name, uniqueURL, address, contactInfo string, organizationType OrganizationType) (*Organization, error)
+
+	DeleteOrganization(ctx context.Context, ID uuid.UUID) (*Organization, error)
+	RestoreOrganization(ctx context.Context, ID uuid.UUID) (*Organization, error)
 }
 
 type OrganizationType string
 
 const (
-	Airline   OrganizationType = "Airline"
-	Carrier   OrganizationType = "Carrier"
-	Warehouse OrganizationType = "Warehouse"
+	Airline   OrganizationType = "airline"
+	Carrier   OrganizationType = "carrier"
+	Warehouse OrganizationType = "warehouse"
 )
 
 type Organization struct {
-	ID               uuid.UUID        `json:"id"`
-	CreatedAt        time.Time        `json:"created_at"`
-	UpdatedAt        time.Time        `json:"updated_at"`
-	Name             string           `json:"name"`
-	UniqueURL        string           `json:"unique_url"`
-	Address          string           `json:"address"`
-	ContactInfo      string           `json:"contact_info"`
-	OrganizationType OrganizationType `json:"organization_type"`
-}
-
-func scanIntoOrganization(rows *sql.Rows) (*Organization, error) {
-	var o Organization
-	err := rows.Scan(
-		&o.ID,
-		&o.CreatedAt,
-		&o.UpdatedAt,
-		&o.Name,
-		&o.Address,
-		&o.ContactInfo,
-		&o.OrganizationType,
-	)
-	if err != nil {
-		return nil, err
-	}
-	return &o, nil
+	ID               uuid.UUID        `json:"id" db:"id"`
+	CreatedAt        time.Time        `json:"created_at" db:"created_at"`
+	UpdatedAt        time.Time        `json:"updated_at" db:"updated_at"`
+	Name             string           `json:"name" db:"name"`
+	UniqueURL        string           `json:"unique_url" db:"unique_url"`
+	Address          string           `json:"address" db:"address"`
+	ContactInfo      string           `json:"contact_info" db:"contact_info"`
+	OrganizationType OrganizationType `json:"organization_type" db:"organization_type"`
+	DeletedAt        *time.Time       `json:"deleted_at,omitempty" db:"deleted_at"`
 }
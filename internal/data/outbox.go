@@ -0,0 +1,209 @@
+package data
+
+import (
+	"bytes"
+	"context"
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	EventUldStatusChanged  = "uld.status_changed"
+	EventManifestSubmitted = "manifest.submitted"
+	EventManifestAccepted  = "manifest.accepted"
+	EventManifestRejected  = "manifest.rejected"
+)
+
+// UldStatusChanged is the payload of EventUldStatusChanged.
+type UldStatusChanged struct {
+	UldID uuid.UUID `json:"uld_id"`
+	From  UldStatus `json:"from"`
+	To    UldStatus `json:"to"`
+}
+
+// ManifestStatusChanged is the payload of the manifest.* status events.
+type ManifestStatusChanged struct {
+	ManifestID     uuid.UUID      `json:"manifest_id"`
+	OrganizationID uuid.UUID      `json:"organization_id"`
+	From           ManifestStatus `json:"from"`
+	To             ManifestStatus `json:"to"`
+}
+
+// EventPayload is the JSON body of an outbox event.
+type EventPayload []byte
+
+func (p EventPayload) MarshalJSON() ([]byte, error) {
+	if p == nil {
+		return []byte("null"), nil
+	}
+	return p, nil
+}
+
+func (p *EventPayload) UnmarshalJSON(b []byte) error {
+	*p = append((*p)[:0], b...)
+	return nil
+}
+
+// Value sends the payload as text, since lib/pq encodes []byte as bytea.
+func (p EventPayload) Value() (driver.Value, error) {
+	return string(p), nil
+}
+
+func (p *EventPayload) Scan(src any) error {
+	switch v := src.(type) {
+	case []byte:
+		*p = append(EventPayload(nil), v...)
+	case string:
+		*p = EventPayload(v)
+	default:
+		return fmt.Errorf("cannot scan %T into EventPayload", src)
+	}
+	return nil
+}
+
+// OutboxEvent is a domain event written in the same transaction as the change it describes.
+// Events are delivered at least once, in order for each aggregate.
+type OutboxEvent struct {
+	ID            uuid.UUID    `json:"id" db:"id"`
+	CreatedAt     time.Time    `json:"created_at" db:"created_at"`
+	AggregateType string       `json:"aggregate_type" db:"aggregate_type"`
+	AggregateID   uuid.UUID    `json:"aggregate_id" db:"aggregate_id"`
+	EventType     string       `json:"event_type" db:"event_type"`
+	Payload       EventPayload `json:"payload" db:"payload"`
+	Attempts      int          `json:"attempts" db:"attempts"`
+	NextAttemptAt time.Time    `json:"next_attempt_at" db:"next_attempt_at"`
+	PublishedAt   *time.Time   `json:"published_at" db:"published_at"`
+	LastError     string       `json:"last_error" db:"last_error"`
+}
+
+func newOutboxEvent(aggregateType string, aggregateID uuid.UUID, eventType string, payload any) (*OutboxEvent, error) {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	id, err := uuid.NewV7()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	return &OutboxEvent{
+		ID:            id,
+		CreatedAt:     now,
+		AggregateType: aggregateType,
+		AggregateID:   aggregateID,
+		EventType:     eventType,
+		Payload:       body,
+		NextAttemptAt: now,
+	}, nil
+}
+
+// enqueueEvent inserts an event into the outbox on db, which should be the transaction of the
+// change the event describes.
+func enqueueEvent(ctx context.Context, db *DB, aggregateType string, aggregateID uuid.UUID, eventType string, payload any) (*OutboxEvent, error) {
+	event, err := newOutboxEvent(aggregateType, aggregateID, eventType, payload)
+	if err != nil {
+		return nil, err
+	}
+
+	data := map[string]any{
+		"id":              event.ID,
+		"created_at":      event.CreatedAt,
+		"aggregate_type":  event.AggregateType,
+		"aggregate_id":    event.AggregateID,
+		"event_type":      event.EventType,
+		"payload":         event.Payload,
+		"next_attempt_at": event.NextAttemptAt,
+	}
+
+	query, values, err := BuildInsertQuery("outbox", data)
+	if err != nil {
+		return nil, err
+	}
+
+	return queryOne(ctx, db, scanRow[OutboxEvent], query, values...)
+}
+
+func (s *outboxStoreImpl) Enqueue(ctx context.Context, aggregateType string, aggregateID uuid.UUID, eventType string, payload any) (*OutboxEvent, error) {
+	return enqueueEvent(ctx, s.db, aggregateType, aggregateID, eventType, payload)
+}
+
+// ClaimPending leases up to limit due events for the given duration and returns them in order.
+// Only the oldest unpublished event of each aggregate is eligible, so an aggregate's events are
+// delivered one at a time and in order. A leased event that is neither published nor failed
+// before the lease runs out becomes due again, which gives at-least-once delivery.
+func (s *outboxStoreImpl) ClaimPending(ctx context.Context, limit int, lease time.Duration) ([]*OutboxEvent, error) {
+	columns, err := selectColumns("outbox")
+	if err != nil {
+		return nil, err
+	}
+
+	query := fmt.Sprintf(`UPDATE outbox SET next_attempt_at = $1 WHERE id IN (
+SELECT o.id FROM outbox o WHERE o.published_at IS NULL AND o.next_attempt_at <= $2
+AND NOT EXISTS (SELECT 1 FROM outbox p WHERE p.aggregate_id = o.aggregate_id AND p.published_at IS NULL AND p.id < o.id)
+ORDER BY o.id LIMIT $3 FOR UPDATE SKIP LOCKED) RETURNING %s`, columns)
+
+	now := time.Now().UTC()
+	events, err := queryAll(ctx, s.db, scanRow[OutboxEvent], query, now.Add(lease), now, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	slices.SortFunc(events, compareEvents)
+	return events, nil
+}
+
+func compareEvents(a, b *OutboxEvent) int {
+	return bytes.Compare(a.ID[:], b.ID[:])
+}
+
+func (s *outboxStoreImpl) MarkPublished(ctx context.Context, ID uuid.UUID) error {
+	query, values, err := BuildUpdateQuery("outbox",
+		map[string]any{"published_at": time.Now().UTC(), "last_error": nil},
+		map[string]any{"id": ID})
+	if err != nil {
+		return err
+	}
+
+	_, err = queryOne(ctx, s.db, scanRow[OutboxEvent], query, values...)
+	return err
+}
+
+// MarkFailed records a failed delivery attempt and schedules the next one.
+func (s *outboxStoreImpl) MarkFailed(ctx context.Context, ID uuid.UUID, cause error, nextAttemptAt time.Time) error {
+	columns, err := selectColumns("outbox")
+	if err != nil {
+		return err
+	}
+
+	query := fmt.Sprintf(
+		"UPDATE outbox SET attempts = attempts + 1, last_error = $1, next_attempt_at = $2 WHERE id = $3 RETURNING %s",
+		columns,
+	)
+
+	_, err = queryOne(ctx, s.db, scanRow[OutboxEvent], query, cause.Error(), nextAttemptAt, ID)
+	return err
+}
+
+type outboxStoreImpl struct {
+	db *DB
+}
+
+var NewOutboxStore = func(db *DB) OutboxStore {
+	return &outboxStoreImpl{
+		db: db,
+	}
+}
+
+type OutboxStore interface {
+	Enqueue(ctx context.Context, aggregateType string, aggregateID uuid.UUID, eventType string, payload any) (*OutboxEvent, error)
+	ClaimPending(ctx context.Context, limit int, lease time.Duration) ([]*OutboxEvent, error)
+	MarkPublished(ctx context.Context, ID uuid.UUID) error
+	MarkFailed(ctx context.Context, ID uuid.UUID, cause error, nextAttemptAt time.Time) error
+}
@@ -0,0 +1,287 @@
+package data
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// DefaultPageSize is used when a PageRequest does not specify a limit.
+	DefaultPageSize = 25
+	// DefaultMaxPageSize caps the limit of a PageRequest unless overridden with WithMaxPageSize.
+	DefaultMaxPageSize = 100
+)
+
+// WithMaxPageSize caps the number of rows a single page may return.
+func WithMaxPageSize(n int) Option {
+	return func(db *DB) {
+		if n > 0 {
+			db.maxPageSize = n
+		}
+	}
+}
+
+// Cursor marks a position in a keyset-paginated list. Pages are ordered by the
+// UUIDv7 primary key, which sorts by creation time, unless a Sort is requested,
+// in which case Key holds the sort column's value at the cursor and the id breaks ties.
+type Cursor struct {
+	ID       uuid.UUID `json:"id"`
+	Backward bool      `json:"b,omitempty"`
+	Sort     string    `json:"s,omitempty"`
+	Key      any       `json:"k,omitempty"`
+}
+
+// EncodeCursor returns the opaque string form of c handed out to clients.
+func EncodeCursor(c Cursor) string {
+	b, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// DecodeCursor parses a cursor produced by EncodeCursor.
+func DecodeCursor(s string) (Cursor, error) {
+	var c Cursor
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return c, fmt.Errorf("invalid cursor")
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(b))
+	decoder.UseNumber()
+	if err := decoder.Decode(&c); err != nil || c.ID == uuid.Nil {
+		return c, fmt.Errorf("invalid cursor")
+	}
+	if c.Sort != "" && c.Key == nil {
+		return c, fmt.Errorf("invalid cursor")
+	}
+	return c, nil
+}
+
+// PageRequest describes which page of a list to return and how the list is filtered and sorted.
+// A nil Cursor requests the first page. Soft-deleted rows are only listed when IncludeDeleted
+// is set, which the stores do for contexts marked with WithDeleted.
+type PageRequest struct {
+	Limit          int
+	Cursor         *Cursor
+	Filters        []Filter
+	Sort           *Sort
+	IncludeDeleted bool
+}
+
+// ParsePageRequest builds a PageRequest for tableName from query parameters.
+// "limit", "cursor", "sort" and "include_deleted" are reserved; every other parameter is
+// parsed as a Filter.
+// A cursor is only valid with the sort it was issued for.
+//
+// Example usage:
+//
+//	params, _ := url.ParseQuery("uld_type[in]=AKE,PMC&sort=-updated_at&limit=50")
+//	page, err := ParsePageRequest("uld_inventories", params)
+func ParsePageRequest(tableName string, params url.Values) (PageRequest, error) {
+	var page PageRequest
+
+	if limitStr := params.Get("limit"); limitStr != "" {
+		limit, err := strconv.Atoi(limitStr)
+		if err != nil || limit <= 0 {
+			return page, fmt.Errorf("invalid limit: %s", limitStr)
+		}
+		page.Limit = limit
+	}
+
+	sort, err := ParseSort(tableName, params.Get("sort"))
+	if err != nil {
+		return page, err
+	}
+	page.Sort = sort
+
+	if cursorStr := params.Get("cursor"); cursorStr != "" {
+		cursor, err := DecodeCursor(cursorStr)
+		if err != nil {
+			return page, err
+		}
+		if cursor.Sort != sort.String() {
+			return page, fmt.Errorf("cursor does not match sort")
+		}
+		page.Cursor = &cursor
+	}
+
+	filters, err := ParseFilters(tableName, params, "limit", "cursor", "sort", "include_deleted")
+	if err != nil {
+		return page, err
+	}
+	page.Filters = filters
+
+	return page, nil
+}
+
+// Page is a single page of a list along with the cursors of its neighbours.
+// Next and Prev are empty when there is no page in that direction.
+type Page[T any] struct {
+	Items []*T   `json:"items"`
+	Next  string `json:"next,omitempty"`
+	Prev  string `json:"prev,omitempty"`
+}
+
+func (db *DB) pageLimit(limit int) int {
+	if limit <= 0 {
+		limit = DefaultPageSize
+	}
+	return min(limit, db.maxPageSize)
+}
+
+// BuildPageQuery builds a keyset-paginated SELECT query for the given table.
+// Conditions are applied as in BuildSelectQuery, followed by the page's filters.
+// Rows are ordered by the page's sort column and then id, starting after the cursor
+// (or before it when the cursor is Backward), and page.Limit+1 rows are requested so
+// the caller can tell whether another page follows. Filter and sort columns must be
+// allowlisted for the table.
+//
+// Example usage:
+//
+//	page := PageRequest{
+//	     Limit:   25,
+//	     Cursor:  &Cursor{ID: lastID, Sort: "-updated_at", Key: lastUpdatedAt},
+//	     Filters: []Filter{{Column: "organization_type", Op: OpEq, Value: "Carrier"}},
+//	     Sort:    &Sort{Column: "updated_at", Desc: true},
+//	}
+//	query, args := BuildPageQuery("organizations", nil, page)
+//	// query => "SELECT id, ... FROM organizations WHERE organization_type = $1 AND (updated_at, id) < ($2, $3)
+//	//           ORDER BY updated_at DESC, id DESC LIMIT 26"
+//	// args  => []any{"Carrier", lastUpdatedAt, lastID}
+func BuildPageQuery(tableName string, conditions map[string]any, page PageRequest) (string, []any, error) {
+	if page.Limit <= 0 {
+		return "", nil, fmt.Errorf("limit must be positive")
+	}
+
+	query, whereClauses, values, err := selectQuery(tableName, conditions, page.IncludeDeleted)
+	if err != nil {
+		return "", nil, err
+	}
+
+	for _, f := range page.Filters {
+		col, ok := lookupColumn(tableName, f.Column)
+		if !ok || !col.allows(f.Op) {
+			return "", nil, fmt.Errorf("invalid filter: %s[%s]", f.Column, f.Op)
+		}
+	}
+	if page.Sort != nil {
+		col, ok := lookupColumn(tableName, page.Sort.Column)
+		if !ok || !col.Sortable {
+			return "", nil, fmt.Errorf("cannot sort by %s", page.Sort.Column)
+		}
+	}
+
+	filterWhere, filterValues := filterClauses(page.Filters, len(values))
+	whereClauses = append(whereClauses, filterWhere...)
+	values = append(values, filterValues...)
+
+	desc := page.Sort != nil && page.Sort.Desc
+	if page.Cursor != nil && page.Cursor.Backward {
+		desc = !desc
+	}
+	op, order := ">", "ASC"
+	if desc {
+		op, order = "<", "DESC"
+	}
+
+	if page.Cursor != nil {
+		if page.Cursor.Sort != page.Sort.String() {
+			return "", nil, fmt.Errorf("cursor does not match sort")
+		}
+
+		if page.Sort == nil {
+			values = append(values, page.Cursor.ID)
+			whereClauses = append(whereClauses, fmt.Sprintf("id %s $%d", op, len(values)))
+		} else {
+			values = append(values, page.Cursor.Key, page.Cursor.ID)
+			whereClauses = append(whereClauses, fmt.Sprintf("(%s, id) %s ($%d, $%d)", page.Sort.Column, op, len(values)-1, len(values)))
+		}
+	}
+
+	if len(whereClauses) > 0 {
+		query = fmt.Sprintf("%s WHERE %s", query, strings.Join(whereClauses, " AND "))
+	}
+
+	orderBy := fmt.Sprintf("id %s", order)
+	if page.Sort != nil {
+		orderBy = fmt.Sprintf("%s %s, id %s", page.Sort.Column, order, order)
+	}
+
+	query = fmt.Sprintf("%s ORDER BY %s LIMIT %d", query, orderBy, page.Limit+1)
+
+	return query, values, nil
+}
+
+// queryPage runs a keyset-paginated query and assembles the resulting Page.
+// columnValue returns the value of the named column for an item, and is used
+// to read the id and sort key at either end of the page.
+func queryPage[T any](ctx context.Context, db *DB, scan func(*sql.Rows) (*T, error), columnValue func(*T, string) any, tableName string, conditions map[string]any, req PageRequest) (*Page[T], error) {
+	req.Limit = db.pageLimit(req.Limit)
+	req.IncludeDeleted = includesDeleted(ctx)
+
+	query, values, err := BuildPageQuery(tableName, conditions, req)
+	if err != nil {
+		return nil, err
+	}
+
+	items, err := queryAll(ctx, db.reader(ctx), scan, query, values...)
+	if err != nil {
+		return nil, err
+	}
+
+	return buildPage(items, columnValue, req), nil
+}
+
+// buildPage trims items, which hold up to req.Limit+1 rows in scan order, to a Page
+// and sets the cursors of its neighbours.
+func buildPage[T any](items []*T, columnValue func(*T, string) any, req PageRequest) *Page[T] {
+	hasMore := len(items) > req.Limit
+	if hasMore {
+		items = items[:req.Limit]
+	}
+
+	backward := req.Cursor != nil && req.Cursor.Backward
+	if backward {
+		slices.Reverse(items)
+	}
+
+	page := &Page[T]{Items: items}
+	if len(items) == 0 {
+		return page
+	}
+
+	cursorAt := func(item *T, backward bool) string {
+		c := Cursor{ID: columnValue(item, "id").(uuid.UUID), Backward: backward}
+		if req.Sort != nil {
+			c.Sort = req.Sort.String()
+			c.Key = columnValue(item, req.Sort.Column)
+		}
+		return EncodeCursor(c)
+	}
+
+	first, last := items[0], items[len(items)-1]
+	if backward {
+		page.Next = cursorAt(last, false)
+		if hasMore {
+			page.Prev = cursorAt(first, true)
+		}
+	} else {
+		if hasMore {
+			page.Next = cursorAt(last, false)
+		}
+		if req.Cursor != nil {
+			page.Prev = cursorAt(first, true)
+		}
+	}
+
+	return page
+}
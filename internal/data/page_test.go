@@ -0,0 +1,187 @@
+package data
+
+import (
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestBuildPageQuery(t *testing.T) {
+	id := uuid.Must(uuid.NewV7())
+	updatedAt := time.Date(2025, 3, 17, 1, 7, 42, 0, time.UTC)
+	sortDesc := &Sort{Column: "updated_at", Desc: true}
+	selectUsers := "SELECT " + strings.Join(columnsOf[User](), ", ") + " FROM users"
+
+	testCases := []struct {
+		name          string
+		conditions    map[string]any
+		page          PageRequest
+		expectedQuery string
+		expectedArgs  []any
+	}{
+		{
+			name:          "First Page",
+			page:          PageRequest{Limit: 10},
+			expectedQuery: selectUsers + " WHERE deleted_at IS NULL ORDER BY id ASC LIMIT 11",
+			expectedArgs:  []any{},
+		},
+		{
+			name:          "Next Page",
+			page:          PageRequest{Limit: 10, Cursor: &Cursor{ID: id}},
+			expectedQuery: selectUsers + " WHERE deleted_at IS NULL AND id > $1 ORDER BY id ASC LIMIT 11",
+			expectedArgs:  []any{id},
+		},
+		{
+			name:          "Previous Page With Conditions",
+			conditions:    map[string]any{"is_admin": true},
+			page:          PageRequest{Limit: 10, Cursor: &Cursor{ID: id, Backward: true}},
+			expectedQuery: selectUsers + " WHERE deleted_at IS NULL AND is_admin = $1 AND id < $2 ORDER BY id DESC LIMIT 11",
+			expectedArgs:  []any{true, id},
+		},
+		{
+			name: "Subquery Condition",
+			conditions: map[string]any{"id": inSubquery{
+				query: "SELECT user_id FROM user_associations WHERE organization_id = %s AND status = %s",
+				args:  []any{id, AssociationActive},
+			}},
+			page:          PageRequest{Limit: 10, Cursor: &Cursor{ID: id}},
+			expectedQuery: selectUsers + " WHERE deleted_at IS NULL AND id IN (SELECT user_id FROM user_associations WHERE organization_id = $1 AND status = $2) AND id > $3 ORDER BY id ASC LIMIT 11",
+			expectedArgs:  []any{id, AssociationActive, id},
+		},
+		{
+			name: "Filters",
+			page: PageRequest{Limit: 10, Filters: []Filter{
+				{Column: "is_verified", Op: OpEq, Value: true},
+				{Column: "created_at", Op: OpGte, Value: updatedAt},
+				{Column: "email", Op: OpIn, Value: []any{"a@b.c", "d@e.f"}},
+			}},
+			expectedQuery: selectUsers + " WHERE deleted_at IS NULL AND is_verified = $1 AND created_at >= $2 AND email IN ($3, $4) ORDER BY id ASC LIMIT 11",
+			expectedArgs:  []any{true, updatedAt, "a@b.c", "d@e.f"},
+		},
+		{
+			name:          "Sorted Next Page",
+			page:          PageRequest{Limit: 10, Sort: sortDesc, Cursor: &Cursor{ID: id, Sort: "-updated_at", Key: updatedAt}},
+			expectedQuery: selectUsers + " WHERE deleted_at IS NULL AND (updated_at, id) < ($1, $2) ORDER BY updated_at DESC, id DESC LIMIT 11",
+			expectedArgs:  []any{updatedAt, id},
+		},
+		{
+			name:          "Sorted Previous Page",
+			page:          PageRequest{Limit: 10, Sort: sortDesc, Cursor: &Cursor{ID: id, Backward: true, Sort: "-updated_at", Key: updatedAt}},
+			expectedQuery: selectUsers + " WHERE deleted_at IS NULL AND (updated_at, id) > ($1, $2) ORDER BY updated_at ASC, id ASC LIMIT 11",
+			expectedArgs:  []any{updatedAt, id},
+		},
+		{
+			name:          "Include Deleted",
+			page:          PageRequest{Limit: 10, IncludeDeleted: true},
+			expectedQuery: selectUsers + " ORDER BY id ASC LIMIT 11",
+			expectedArgs:  []any{},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			query, args, err := BuildPageQuery("users", tc.conditions, tc.page)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if query != tc.expectedQuery {
+				t.Errorf("Expected query %q, got %q", tc.expectedQuery, query)
+			}
+			if !reflect.DeepEqual(args, tc.expectedArgs) {
+				t.Errorf("Expected args %v, got %v", tc.expectedArgs, args)
+			}
+		})
+	}
+}
+
+func TestBuildPageQueryRejectsUnlistedColumns(t *testing.T) {
+	testCases := map[string]PageRequest{
+		"Hidden Column":    {Limit: 10, Filters: []Filter{{Column: "hashed_password", Op: OpEq, Value: "x"}}},
+		"Injection":        {Limit: 10, Filters: []Filter{{Column: "1=1; DROP TABLE users; --", Op: OpEq, Value: "x"}}},
+		"Unsortable":       {Limit: 10, Sort: &Sort{Column: "email"}},
+		"Invalid Operator": {Limit: 10, Filters: []Filter{{Column: "is_admin", Op: OpGt, Value: true}}},
+		"Mismatched Sort":  {Limit: 10, Cursor: &Cursor{ID: uuid.Must(uuid.NewV7())}, Sort: &Sort{Column: "created_at"}},
+	}
+
+	for name, page := range testCases {
+		t.Run(name, func(t *testing.T) {
+			if _, _, err := BuildPageQuery("users", nil, page); err == nil {
+				t.Errorf("Expected error")
+			}
+		})
+	}
+}
+
+func TestParsePageRequest(t *testing.T) {
+	params, err := url.ParseQuery("uld_status=in_transit&created_at[gte]=2025-03-17T00:00:00Z&uld_type[in]=AKE,PMC&sort=-updated_at&limit=5")
+	if err != nil {
+		t.Fatalf("Failed to parse query: %v", err)
+	}
+
+	page, err := ParsePageRequest("uld_inventories", params)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	expected := PageRequest{
+		Limit: 5,
+		Sort:  &Sort{Column: "updated_at", Desc: true},
+		Filters: []Filter{
+			{Column: "created_at", Op: OpGte, Value: time.Date(2025, 3, 17, 0, 0, 0, 0, time.UTC)},
+			{Column: "uld_status", Op: OpEq, Value: "in_transit"},
+			{Column: "uld_type", Op: OpIn, Value: []any{"AKE", "PMC"}},
+		},
+	}
+	if !reflect.DeepEqual(page, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, page)
+	}
+
+	invalid := []string{
+		"limit=0",
+		"hashed_password=x",
+		"uld_status[gt]=in_transit",
+		"created_at[gte]=yesterday",
+		"uld_type[like]=A%25",
+		"sort=uld_number",
+		"cursor=" + EncodeCursor(Cursor{ID: uuid.Must(uuid.NewV7())}) + "&sort=created_at",
+	}
+	for _, raw := range invalid {
+		params, _ := url.ParseQuery(raw)
+		if _, err := ParsePageRequest("uld_inventories", params); err == nil {
+			t.Errorf("Expected error parsing %q", raw)
+		}
+	}
+}
+
+func TestCursor(t *testing.T) {
+	c := Cursor{ID: uuid.Must(uuid.NewV7()), Backward: true}
+
+	decoded, err := DecodeCursor(EncodeCursor(c))
+	if err != nil {
+		t.Fatalf("Failed to decode cursor: %v", err)
+	}
+	if decoded != c {
+		t.Errorf("Expected cursor %v, got %v", c, decoded)
+	}
+
+	for _, invalid := range []string{"", "not-base64!", EncodeCursor(Cursor{})} {
+		if _, err := DecodeCursor(invalid); err == nil {
+			t.Errorf("Expected error decoding cursor %q", invalid)
+		}
+	}
+}
+
+func TestPageLimit(t *testing.T) {
+	db := NewDB(nil, WithMaxPageSize(50))
+
+	testCases := map[int]int{0: DefaultPageSize, -1: DefaultPageSize, 10: 10, 50: 50, 500: 50}
+	for requested, expected := range testCases {
+		if got := db.pageLimit(requested); got != expected {
+			t.Errorf("Expected limit %d for %d, got %d", expected, requested, got)
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Patch holds the columns an update writes. Unlike the Update methods, which skip zero
+// values, every entry of a Patch is written, so it can reset a column to false, 0 or "".
+// A nil value sets the column to NULL.
+type Patch map[string]any
+
+// patchColumns lists the columns a Patch may write for each table. Columns with their own
+// store methods, such as deleted_at, are left out.
+var patchColumns = map[string][]string{
+	"users": {
+		"user_name",
+		"email",
+		"hashed_password",
+		"is_admin",
+		"is_verified",
+		"last_request",
+		"last_login",
+		"failed_login_attempts",
+	},
+	"organizations": {
+		"name",
+		"unique_url",
+		"address",
+		"contact_info",
+		"organization_type",
+	},
+}
+
+func (p Patch) validate(tableName string) error {
+	for _, column := range sortedKeys(p) {
+		if !slices.Contains(patchColumns[tableName], column) {
+			return fmt.Errorf("column %s of %s cannot be patched", column, tableName)
+		}
+	}
+	return nil
+}
+
+// patchRow writes patch to the live row with id, along with a new updated_at. When
+// expectedUpdatedAt is non-zero the write only applies if the row's updated_at still matches
+// it, and ErrPreconditionFailed is returned otherwise. It returns sql.ErrNoRows when there is
+// no live row with id.
+func patchRow[T any](ctx context.Context, db *DB, tableName string, id uuid.UUID, patch Patch, expectedUpdatedAt time.Time) (*T, error) {
+	if err := patch.validate(tableName); err != nil {
+		return nil, err
+	}
+
+	updateData := map[string]any{"updated_at": time.Now().UTC()}
+	maps.Copy(updateData, patch)
+
+	conditions := map[string]any{
+		"id":         id,
+		"deleted_at": nil,
+	}
+	if !expectedUpdatedAt.IsZero() {
+		conditions["updated_at"] = expectedUpdatedAt
+	}
+
+	item, err := updateRow[T](ctx, db, tableName, AuditUpdate, updateData, conditions)
+	if errors.Is(err, sql.ErrNoRows) && !expectedUpdatedAt.IsZero() {
+		query, values, qErr := BuildSelectQuery(tableName, map[string]any{"id": id})
+		if qErr != nil {
+			return nil, qErr
+		}
+		if _, qErr := queryOne(ctx, db, scanRow[T], query, values...); qErr == nil {
+			return nil, ErrPreconditionFailed
+		}
+	}
+	return item, err
+}
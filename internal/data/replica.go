@@ -0,0 +1,165 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"log/slog"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultReplicaCheckTimeout bounds each replica health check.
+const DefaultReplicaCheckTimeout = 2 * time.Second
+
+// replicaConn is a read replica connection. *sql.DB satisfies it.
+type replicaConn interface {
+	DBTX
+	PingContext(ctx context.Context) error
+}
+
+type replica struct {
+	conn    replicaConn
+	healthy atomic.Bool
+}
+
+// replicaSet balances reads across the replicas that passed their last health check.
+type replicaSet struct {
+	replicas []*replica
+	next     atomic.Uint64
+}
+
+// WithReplicas sends read-only queries outside transactions to the given read replicas.
+// Replicas start out healthy; CheckReplicasEvery takes failing replicas out of rotation
+// until they recover. Reads fall back to the primary when no replica is healthy.
+func WithReplicas(replicas ...*sql.DB) Option {
+	conns := make([]replicaConn, len(replicas))
+	for i, r := range replicas {
+		conns[i] = r
+	}
+	return withReplicaConns(conns...)
+}
+
+func withReplicaConns(conns ...replicaConn) Option {
+	return func(db *DB) {
+		if len(conns) == 0 {
+			db.replicas = nil
+			return
+		}
+
+		set := &replicaSet{}
+		for _, conn := range conns {
+			r := &replica{conn: conn}
+			r.healthy.Store(true)
+			set.replicas = append(set.replicas, r)
+		}
+		db.replicas = set
+	}
+}
+
+// pick returns the next healthy replica in round-robin order, or nil if there is none.
+func (set *replicaSet) pick() DBTX {
+	n := uint64(len(set.replicas))
+	start := set.next.Add(1)
+	for i := range n {
+		r := set.replicas[(start+i)%n]
+		if r.healthy.Load() {
+			return r.conn
+		}
+	}
+	return nil
+}
+
+func (set *replicaSet) check(ctx context.Context) {
+	for i, r := range set.replicas {
+		pingCtx, cancel := context.WithTimeout(ctx, DefaultReplicaCheckTimeout)
+		err := r.conn.PingContext(pingCtx)
+		cancel()
+
+		healthy := err == nil
+		if r.healthy.Swap(healthy) != healthy {
+			if healthy {
+				slog.Info("Replica recovered", "replica", i)
+			} else {
+				slog.Warn("Replica unhealthy", "replica", i, "error", err)
+			}
+		}
+	}
+}
+
+// CheckReplicasEvery pings every replica immediately and then on every interval until ctx is
+// done, taking replicas that fail out of rotation and returning those that recover.
+func (s *Store) CheckReplicasEvery(ctx context.Context, interval time.Duration) {
+	if s.db == nil || s.db.replicas == nil {
+		return
+	}
+
+	s.db.replicas.check(ctx)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.db.replicas.check(ctx)
+		}
+	}
+}
+
+const contextKeyWrites ContextKey = "contextKeyWrites"
+
+// writeMarker records that a write was made in the request that owns it.
+type writeMarker struct {
+	wrote atomic.Bool
+}
+
+// WithReadYourWrites starts a read-your-writes scope on ctx. Once any write is made with
+// ctx, or a context derived from it, later reads in the scope go to the primary rather
+// than a replica that may not have caught up.
+func WithReadYourWrites(ctx context.Context) context.Context {
+	return context.WithValue(ctx, contextKeyWrites, &writeMarker{})
+}
+
+func markWrite(ctx context.Context) {
+	if m, ok := ctx.Value(contextKeyWrites).(*writeMarker); ok {
+		m.wrote.Store(true)
+	}
+}
+
+func hasWritten(ctx context.Context) bool {
+	m, ok := ctx.Value(contextKeyWrites).(*writeMarker)
+	return ok && m.wrote.Load()
+}
+
+const contextKeyPrimary ContextKey = "contextKeyPrimary"
+
+// WithPrimary sends every read made with ctx to the primary. Use it for reads that decide
+// whether to allow something, such as the failed login count a lockout is based on, where
+// a replica that has not caught up would let a request through.
+func WithPrimary(ctx context.Context) context.Context {
+	return context.WithValue(ctx, contextKeyPrimary, true)
+}
+
+func usesPrimary(ctx context.Context) bool {
+	primary, _ := ctx.Value(contextKeyPrimary).(bool)
+	return primary
+}
+
+// reader returns the DB a read-only query should use: a healthy replica, unless db is bound
+// to a transaction, ctx has already written or asks for the primary, or no replica is available.
+func (db *DB) reader(ctx context.Context) *DB {
+	if db.replicas == nil || hasWritten(ctx) || usesPrimary(ctx) {
+		return db
+	}
+	if _, ok := db.conn.(txBeginner); !ok {
+		return db
+	}
+
+	conn := db.replicas.pick()
+	if conn == nil {
+		return db
+	}
+	return db.withConn(conn)
+}
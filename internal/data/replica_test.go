@@ -0,0 +1,210 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"testing"
+	"time"
+)
+
+type fakeConn struct {
+	name    string
+	pingErr error
+}
+
+func (c *fakeConn) QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (c *fakeConn) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (c *fakeConn) PingContext(ctx context.Context) error {
+	return c.pingErr
+}
+
+// fakePrimary can begin transactions, which is how the data layer tells a pool from a transaction.
+type fakePrimary struct {
+	fakeConn
+}
+
+func (c *fakePrimary) BeginTx(ctx context.Context, opts *sql.TxOptions) (*sql.Tx, error) {
+	return nil, errors.New("not implemented")
+}
+
+func TestReplicaRouting(t *testing.T) {
+	primary := &fakePrimary{fakeConn{name: "primary"}}
+	replicaA := &fakeConn{name: "a"}
+	replicaB := &fakeConn{name: "b"}
+
+	readerName := func(db *DB, ctx context.Context) string {
+		switch conn := db.reader(ctx).conn.(type) {
+		case *fakePrimary:
+			return conn.name
+		case *fakeConn:
+			return conn.name
+		}
+		return "unknown"
+	}
+
+	t.Run("No Replicas", func(t *testing.T) {
+		db := NewDB(primary)
+		if got := readerName(db, context.Background()); got != "primary" {
+			t.Errorf("Expected primary, got %s", got)
+		}
+	})
+
+	t.Run("Round Robin", func(t *testing.T) {
+		db := NewDB(primary, withReplicaConns(replicaA, replicaB))
+
+		seen := map[string]int{}
+		for range 4 {
+			seen[readerName(db, context.Background())]++
+		}
+		if seen["a"] != 2 || seen["b"] != 2 {
+			t.Errorf("Expected reads split across replicas, got %v", seen)
+		}
+	})
+
+	t.Run("Transaction", func(t *testing.T) {
+		db := NewDB(primary, withReplicaConns(replicaA)).withConn(&fakeConn{name: "tx"})
+		if got := readerName(db, context.Background()); got != "tx" {
+			t.Errorf("Expected reads in a transaction to use it, got %s", got)
+		}
+	})
+
+	t.Run("Read Your Writes", func(t *testing.T) {
+		db := NewDB(primary, withReplicaConns(replicaA))
+		ctx := WithReadYourWrites(context.Background())
+
+		if got := readerName(db, ctx); got != "a" {
+			t.Errorf("Expected replica before a write, got %s", got)
+		}
+		markWrite(context.WithValue(ctx, ContextKey("child"), true))
+		if got := readerName(db, ctx); got != "primary" {
+			t.Errorf("Expected primary after a write, got %s", got)
+		}
+		if got := readerName(db, context.Background()); got != "a" {
+			t.Errorf("Expected other requests to keep using the replica, got %s", got)
+		}
+	})
+
+	t.Run("Primary Requested", func(t *testing.T) {
+		db := NewDB(primary, withReplicaConns(replicaA))
+		if got := readerName(db, WithPrimary(context.Background())); got != "primary" {
+			t.Errorf("Expected primary, got %s", got)
+		}
+	})
+
+	t.Run("Health Check", func(t *testing.T) {
+		down := &fakeConn{name: "down", pingErr: errors.New("connection refused")}
+		db := NewDB(primary, withReplicaConns(down, replicaA))
+		store := newStore(db)
+
+		db.replicas.check(context.Background())
+		for range 3 {
+			if got := readerName(db, context.Background()); got != "a" {
+				t.Errorf("Expected the healthy replica, got %s", got)
+			}
+		}
+
+		replicaA.pingErr = errors.New("connection refused")
+		defer func() { replicaA.pingErr = nil }()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		store.CheckReplicasEvery(ctx, time.Hour)
+		if got := readerName(db, context.Background()); got != "primary" {
+			t.Errorf("Expected fallback to primary, got %s", got)
+		}
+
+		down.pingErr = nil
+		db.replicas.check(context.Background())
+		if got := readerName(db, context.Background()); got != "down" {
+			t.Errorf("Expected recovered replica, got %s", got)
+		}
+	})
+}
+
+// rowDriver is a driver whose queries all return one row of fixed columns, standing in for a
+// database that holds one version of a row.
+type rowDriver struct {
+	columns []string
+	values  []driver.Value
+}
+
+func (d *rowDriver) Open(name string) (driver.Conn, error) { return d, nil }
+func (d *rowDriver) Prepare(query string) (driver.Stmt, error) {
+	return rowStmt{d}, nil
+}
+func (d *rowDriver) Close() error              { return nil }
+func (d *rowDriver) Begin() (driver.Tx, error) { return countingTx{}, nil }
+
+type rowStmt struct{ d *rowDriver }
+
+func (s rowStmt) Close() error  { return nil }
+func (s rowStmt) NumInput() int { return -1 }
+func (s rowStmt) Exec(args []driver.Value) (driver.Result, error) {
+	return driver.RowsAffected(1), nil
+}
+func (s rowStmt) Query(args []driver.Value) (driver.Rows, error) { return &oneRow{d: s.d}, nil }
+
+type oneRow struct {
+	d    *rowDriver
+	done bool
+}
+
+func (r *oneRow) Columns() []string { return r.d.columns }
+func (r *oneRow) Close() error      { return nil }
+func (r *oneRow) Next(dest []driver.Value) error {
+	if r.done {
+		return io.EOF
+	}
+	r.done = true
+	copy(dest, r.d.values)
+	return nil
+}
+
+func openRowDriver(t *testing.T, columns []string, values ...driver.Value) *sql.DB {
+	t.Helper()
+	d := &rowDriver{columns: columns, values: values}
+	name := fmt.Sprintf("row-%p", d)
+	sql.Register(name, d)
+
+	pool, err := sql.Open(name, "")
+	if err != nil {
+		t.Fatalf("Failed to open pool: %v", err)
+	}
+	t.Cleanup(func() { pool.Close() })
+	return pool
+}
+
+func TestLaggingReplica(t *testing.T) {
+	// The replica has not yet seen the failed logins that locked the account on the primary.
+	columns := []string{"email", "failed_login_attempts"}
+	primary := openRowDriver(t, columns, "kevin@example.com", int64(10))
+	replica := openRowDriver(t, columns, "kevin@example.com", int64(0))
+	store := NewStore(primary, WithReplicas(replica))
+
+	ctx := context.Background()
+	stale, err := store.User.GetUserByEmail(ctx, "kevin@example.com")
+	if err != nil {
+		t.Fatalf("Failed to get user: %v", err)
+	}
+	if stale.FailedLoginAttempts != 0 {
+		t.Fatalf("Expected an ordinary read to use the lagging replica, got %d attempts", stale.FailedLoginAttempts)
+	}
+
+	user, err := store.User.GetUserByEmail(WithPrimary(ctx), "kevin@example.com")
+	if err != nil {
+		t.Fatalf("Failed to get user: %v", err)
+	}
+	if user.FailedLoginAttempts != 10 {
+		t.Errorf("Expected the primary's failed login count, got %d", user.FailedLoginAttempts)
+	}
+}
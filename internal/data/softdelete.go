@@ -0,0 +1,173 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// DefaultDeletedRetention is how long soft-deleted rows are kept before PurgeDeleted removes them.
+const DefaultDeletedRetention = 30 * 24 * time.Hour
+
+const contextKeyIncludeDeleted ContextKey = "contextKeyIncludeDeleted"
+
+// WithDeleted marks ctx so that store reads return soft-deleted rows as well.
+func WithDeleted(ctx context.Context) context.Context {
+	return context.WithValue(ctx, contextKeyIncludeDeleted, true)
+}
+
+func includesDeleted(ctx context.Context) bool {
+	include, _ := ctx.Value(contextKeyIncludeDeleted).(bool)
+	return include
+}
+
+// isSoftDeletable reports whether the table's model has a deleted_at column.
+func isSoftDeletable(tableName string) bool {
+	return slices.Contains(tableColumns[tableName], "deleted_at")
+}
+
+// softDelete sets deleted_at on a live row, along with any extra columns, and returns the row.
+func softDelete[T any](ctx context.Context, db *DB, tableName, entity string, id uuid.UUID, extra map[string]any) (*T, error) {
+	now := time.Now().UTC()
+	updateData := map[string]any{
+		"deleted_at": now,
+		"updated_at": now,
+	}
+	for col, v := range extra {
+		updateData[col] = v
+	}
+
+	conditions := map[string]any{
+		"id":         id,
+		"deleted_at": nil,
+	}
+
+	item, err := updateRow[T](ctx, db, tableName, AuditDelete, updateData, conditions)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, notFound(entity, id)
+	}
+	return item, err
+}
+
+// restore clears deleted_at on a row, along with any extra columns, and returns the row.
+func restore[T any](ctx context.Context, db *DB, tableName, entity string, id uuid.UUID, extra map[string]any) (*T, error) {
+	updateData := map[string]any{
+		"deleted_at": nil,
+		"updated_at": time.Now().UTC(),
+	}
+	for col, v := range extra {
+		updateData[col] = v
+	}
+
+	conditions := map[string]any{
+		"id": id,
+	}
+
+	item, err := updateRow[T](ctx, db, tableName, AuditUpdate, updateData, conditions)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, notFound(entity, id)
+	}
+	return item, err
+}
+
+// purgeOrder lists the soft-deletable tables with referencing tables before the tables they reference.
+var purgeOrder = []string{
+	"delivery_manifests",
+	"uld_inventories",
+	"organizations",
+	"users",
+}
+
+// PurgeDeleted permanently removes rows that were soft-deleted before the cutoff and returns
+// how many were removed. Rows still referenced by a RESTRICT foreign key are kept until the
+// referencing rows are purged.
+func (s *Store) PurgeDeleted(ctx context.Context, before time.Time) (int, error) {
+	if s.memory != nil {
+		return s.memory.purgeDeleted(ctx, before)
+	}
+
+	purged := 0
+	for _, tableName := range purgeOrder {
+		ids, err := queryAll(ctx, s.db, scanUUID,
+			fmt.Sprintf("SELECT id FROM %s WHERE deleted_at < $1", tableName), before)
+		if err != nil {
+			return purged, err
+		}
+
+		for _, id := range ids {
+			err := purgeRow(ctx, s.db, tableName, *id, before)
+
+			if errors.Is(err, ErrInvalidReference) {
+				continue
+			}
+			if errors.Is(err, sql.ErrNoRows) {
+				continue
+			}
+			if err != nil {
+				return purged, err
+			}
+			purged++
+		}
+	}
+
+	return purged, nil
+}
+
+// PurgeDeletedEvery runs PurgeDeleted with a cutoff of now minus retention on every interval
+// until ctx is done.
+func (s *Store) PurgeDeletedEvery(ctx context.Context, retention, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			purged, err := s.PurgeDeleted(ctx, time.Now().Add(-retention))
+			if err != nil {
+				slog.Error("PurgeDeleted", "error", err)
+				continue
+			}
+			if purged > 0 {
+				slog.Info("PurgeDeleted", "purged", purged)
+			}
+		}
+	}
+}
+
+// purgeRow deletes a single row of tableName that was soft-deleted before the cutoff.
+func purgeRow(ctx context.Context, db *DB, tableName string, id uuid.UUID, before time.Time) error {
+	switch tableName {
+	case "users":
+		return deleteRow(ctx, db, tableName, id, deletedBefore[User](before))
+	case "organizations":
+		return deleteRow(ctx, db, tableName, id, deletedBefore[Organization](before))
+	case "uld_inventories":
+		return deleteRow(ctx, db, tableName, id, deletedBefore[UldInventory](before))
+	case "delivery_manifests":
+		return deleteRow(ctx, db, tableName, id, deletedBefore[DeliveryManifest](before))
+	}
+	return fmt.Errorf("cannot purge table: %s", tableName)
+}
+
+func deletedBefore[T any](before time.Time) func(*T) bool {
+	return func(item *T) bool {
+		deletedAt, _ := columnValue(item, "deleted_at").(*time.Time)
+		return deletedAt != nil && deletedAt.Before(before)
+	}
+}
+
+func scanUUID(rows *sql.Rows) (*uuid.UUID, error) {
+	var id uuid.UUID
+	if err := rows.Scan(&id); err != nil {
+		return nil, err
+	}
+	return &id, nil
+}
@@ -0,0 +1,197 @@
+package data
+
+import (
+	"container/list"
+	"context"
+	"database/sql"
+	"sync"
+)
+
+// DefaultStatementCacheSize is the number of prepared statements WithStatementCache keeps
+// per connection pool when given a size of zero.
+const DefaultStatementCacheSize = 256
+
+// WithStatementCache prepares each generated query once per connection pool and reuses the
+// statement while it stays among the size most recently used. Pools behind a transaction-mode
+// pooler such as PgBouncer must not enable it, as prepared statements do not survive there.
+func WithStatementCache(size int) Option {
+	return func(db *DB) {
+		if size == 0 {
+			size = DefaultStatementCacheSize
+		}
+		db.stmtCacheSize = size
+	}
+}
+
+// stmtCache is a bounded LRU of statements prepared on one pool, keyed by SQL text.
+// Evicted statements are closed once no query is still using them.
+type stmtCache struct {
+	pool *sql.DB
+	size int
+
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	order   *list.List // of *stmtEntry, most recently used first
+}
+
+type stmtEntry struct {
+	query   string
+	stmt    *sql.Stmt
+	refs    int
+	evicted bool
+}
+
+func newStmtCache(pool *sql.DB, size int) *stmtCache {
+	return &stmtCache{
+		pool:    pool,
+		size:    size,
+		entries: make(map[string]*list.Element),
+		order:   list.New(),
+	}
+}
+
+// acquire returns the statement for query, preparing it on a miss. The caller must call
+// release once it no longer issues queries on the statement.
+func (c *stmtCache) acquire(ctx context.Context, query string) (*stmtEntry, error) {
+	c.mu.Lock()
+	if el, ok := c.entries[query]; ok {
+		c.order.MoveToFront(el)
+		entry := el.Value.(*stmtEntry)
+		entry.refs++
+		c.mu.Unlock()
+		return entry, nil
+	}
+	c.mu.Unlock()
+
+	stmt, err := c.pool.PrepareContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// Another caller may have prepared the same query meanwhile.
+	if el, ok := c.entries[query]; ok {
+		stmt.Close()
+		c.order.MoveToFront(el)
+		entry := el.Value.(*stmtEntry)
+		entry.refs++
+		return entry, nil
+	}
+
+	entry := &stmtEntry{query: query, stmt: stmt, refs: 1}
+	c.entries[query] = c.order.PushFront(entry)
+
+	for c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		evicted := oldest.Value.(*stmtEntry)
+		delete(c.entries, evicted.query)
+		evicted.evicted = true
+		if evicted.refs == 0 {
+			evicted.stmt.Close()
+		}
+	}
+
+	return entry, nil
+}
+
+func (c *stmtCache) release(entry *stmtEntry) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry.refs--
+	if entry.evicted && entry.refs == 0 {
+		entry.stmt.Close()
+	}
+}
+
+func (c *stmtCache) len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.order.Len()
+}
+
+// cachedConn issues queries on a pool through its statement cache. It embeds the pool, so
+// it can still begin transactions and be pinged.
+type cachedConn struct {
+	*sql.DB
+	stmts *stmtCache
+}
+
+func (c *cachedConn) QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	entry, err := c.stmts.acquire(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer c.stmts.release(entry)
+
+	// The rows keep the statement open until they are closed, even if it is evicted.
+	return entry.stmt.QueryContext(ctx, args...)
+}
+
+func (c *cachedConn) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	entry, err := c.stmts.acquire(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer c.stmts.release(entry)
+
+	return entry.stmt.ExecContext(ctx, args...)
+}
+
+// cachedTx issues queries on a transaction begun from a cachedConn, reusing the pool's
+// statements on the transaction's connection.
+type cachedTx struct {
+	*sql.Tx
+	stmts *stmtCache
+}
+
+func (c *cachedTx) QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	entry, err := c.stmts.acquire(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer c.stmts.release(entry)
+
+	return c.Tx.StmtContext(ctx, entry.stmt).QueryContext(ctx, args...)
+}
+
+func (c *cachedTx) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	entry, err := c.stmts.acquire(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer c.stmts.release(entry)
+
+	return c.Tx.StmtContext(ctx, entry.stmt).ExecContext(ctx, args...)
+}
+
+// withStatementCache wraps every pool db issues queries on, including its replicas, in a
+// cachedConn with its own statement cache.
+func (db *DB) withStatementCache() {
+	if db.stmtCacheSize <= 0 {
+		return
+	}
+
+	if pool, ok := db.conn.(*sql.DB); ok {
+		db.conn = &cachedConn{DB: pool, stmts: newStmtCache(pool, db.stmtCacheSize)}
+	}
+	if db.replicas != nil {
+		for _, r := range db.replicas.replicas {
+			if pool, ok := r.conn.(*sql.DB); ok {
+				r.conn = &cachedConn{DB: pool, stmts: newStmtCache(pool, db.stmtCacheSize)}
+			}
+		}
+	}
+}
+
+// withTx returns a copy of db that issues its queries on tx, through the statement cache of
+// the pool tx was begun on if it has one.
+func (db *DB) withTx(tx *sql.Tx) *DB {
+	if c, ok := db.conn.(*cachedConn); ok {
+		return db.withConn(&cachedTx{Tx: tx, stmts: c.stmts})
+	}
+	return db.withConn(tx)
+}
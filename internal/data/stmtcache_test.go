@@ -0,0 +1,205 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"io"
+	"os"
+	"sync/atomic"
+	"testing"
+
+	"github.com/kevin-griley/api/internal/db"
+)
+
+// countingDriver is a driver whose statements return no rows and count prepares and closes.
+type countingDriver struct {
+	prepared atomic.Int64
+	closed   atomic.Int64
+}
+
+func (d *countingDriver) Open(name string) (driver.Conn, error) { return &countingConn{d}, nil }
+
+type countingConn struct{ d *countingDriver }
+
+func (c *countingConn) Prepare(query string) (driver.Stmt, error) {
+	c.d.prepared.Add(1)
+	return &countingStmt{c.d}, nil
+}
+func (c *countingConn) Close() error              { return nil }
+func (c *countingConn) Begin() (driver.Tx, error) { return countingTx{}, nil }
+
+type countingTx struct{}
+
+func (countingTx) Commit() error   { return nil }
+func (countingTx) Rollback() error { return nil }
+
+type countingStmt struct{ d *countingDriver }
+
+func (s *countingStmt) Close() error  { s.d.closed.Add(1); return nil }
+func (s *countingStmt) NumInput() int { return -1 }
+func (s *countingStmt) Exec(args []driver.Value) (driver.Result, error) {
+	return driver.RowsAffected(0), nil
+}
+func (s *countingStmt) Query(args []driver.Value) (driver.Rows, error) { return emptyRows{}, nil }
+
+type emptyRows struct{}
+
+func (emptyRows) Columns() []string              { return nil }
+func (emptyRows) Close() error                   { return nil }
+func (emptyRows) Next(dest []driver.Value) error { return io.EOF }
+
+func TestStatementCache(t *testing.T) {
+	ctx := context.Background()
+
+	d := &countingDriver{}
+	name := fmt.Sprintf("counting-%p", d)
+	sql.Register(name, d)
+
+	pool, err := sql.Open(name, "")
+	if err != nil {
+		t.Fatalf("Failed to open pool: %v", err)
+	}
+	defer pool.Close()
+
+	cached := NewDB(pool, WithStatementCache(2))
+	conn := cached.conn.(*cachedConn)
+
+	query := func(q string) {
+		t.Helper()
+		rows, err := conn.QueryContext(ctx, q)
+		if err != nil {
+			t.Fatalf("Query %q failed: %v", q, err)
+		}
+		rows.Close()
+	}
+
+	query("SELECT 1")
+	query("SELECT 1")
+	if got := d.prepared.Load(); got != 1 {
+		t.Errorf("Expected 1 prepare for a repeated query, got %d", got)
+	}
+
+	query("SELECT 2")
+	query("SELECT 3")
+	if got := conn.stmts.len(); got != 2 {
+		t.Errorf("Expected the cache to hold 2 statements, got %d", got)
+	}
+	if got := d.closed.Load(); got != 1 {
+		t.Errorf("Expected the evicted statement to be closed, got %d closes", got)
+	}
+
+	query("SELECT 1")
+	if got := d.prepared.Load(); got != 4 {
+		t.Errorf("Expected an evicted query to be prepared again, got %d prepares", got)
+	}
+
+	// A statement evicted while rows are open stays usable until they are closed.
+	rows, err := conn.QueryContext(ctx, "SELECT 4")
+	if err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+	query("SELECT 5")
+	query("SELECT 6")
+	if rows.Next() {
+		t.Errorf("Expected no rows")
+	}
+	if err := rows.Err(); err != nil {
+		t.Errorf("Expected evicted statement to stay usable, got %v", err)
+	}
+	rows.Close()
+
+	tx, err := pool.BeginTx(ctx, nil)
+	if err != nil {
+		t.Fatalf("Failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+	if _, err := cached.withTx(tx).conn.ExecContext(ctx, "SELECT 7"); err != nil {
+		t.Fatalf("Exec in transaction failed: %v", err)
+	}
+	if _, ok := conn.stmts.entries["SELECT 7"]; !ok {
+		t.Errorf("Expected a query in a transaction to be cached on the pool")
+	}
+}
+
+// benchmarkStore returns a store on DATABASE_URL, with or without the statement cache.
+func benchmarkStore(b *testing.B, opts ...Option) *Store {
+	if os.Getenv("DATABASE_URL") == "" {
+		b.Skip("DATABASE_URL not set")
+	}
+
+	dbConn, _, err := db.Init(os.Getenv("DATABASE_URL"))
+	if err != nil {
+		b.Fatalf("Failed to connect to database: %v", err)
+	}
+	b.Cleanup(func() { db.Close(dbConn) })
+
+	return NewStore(dbConn, opts...)
+}
+
+func benchmarkUser(b *testing.B, store *Store) *User {
+	ctx := context.Background()
+
+	u, err := store.User.CreateRequest(fmt.Sprintf("bench-%d@example.com", os.Getpid()), "password")
+	if err != nil {
+		b.Fatalf("Failed to build user: %v", err)
+	}
+	if existing, err := store.User.GetUserByEmail(ctx, u.Email); err == nil {
+		return existing
+	}
+	user, err := store.User.CreateUser(ctx, u)
+	if err != nil {
+		b.Fatalf("Failed to create user: %v", err)
+	}
+	return user
+}
+
+var statementCacheModes = []struct {
+	name string
+	opts []Option
+}{
+	{name: "Uncached"},
+	{name: "Cached", opts: []Option{WithStatementCache(0)}},
+}
+
+// BenchmarkLogin measures the queries behind POST /login: a lookup by email followed by
+// resetting the failed login count.
+func BenchmarkLogin(b *testing.B) {
+	for _, mode := range statementCacheModes {
+		b.Run(mode.name, func(b *testing.B) {
+			store := benchmarkStore(b, mode.opts...)
+			user := benchmarkUser(b, store)
+			ctx := context.Background()
+
+			b.ResetTimer()
+			for range b.N {
+				u, err := store.User.GetUserByEmail(ctx, user.Email)
+				if err != nil {
+					b.Fatal(err)
+				}
+				if _, err := store.User.PatchUser(ctx, u.ID, Patch{"failed_login_attempts": 0}, u.UpdatedAt); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkGetUserMe measures the lookup behind GET /user/me.
+func BenchmarkGetUserMe(b *testing.B) {
+	for _, mode := range statementCacheModes {
+		b.Run(mode.name, func(b *testing.B) {
+			store := benchmarkStore(b, mode.opts...)
+			user := benchmarkUser(b, store)
+			ctx := context.Background()
+
+			b.ResetTimer()
+			for range b.N {
+				if _, err := store.User.GetUserByID(ctx, user.ID); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
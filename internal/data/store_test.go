@@ -0,0 +1,724 @@
+package data
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/kevin-griley/api/internal/db"
+)
+
+func TestMemoryStore(t *testing.T) {
+	testStoreConformance(t, NewMemoryStore())
+}
+
+func TestPostgresStore(t *testing.T) {
+	if os.Getenv("DATABASE_URL") == "" {
+		t.Skip("DATABASE_URL not set")
+	}
+
+	dbConn, replicas, err := db.Init(os.Getenv("DATABASE_URL"))
+	if err != nil {
+		t.Fatalf("Failed to connect to database: %v", err)
+	}
+	t.Cleanup(func() { db.Close(append(replicas, dbConn)...) })
+
+	testStoreConformance(t, NewStore(dbConn, WithReplicas(replicas...)))
+}
+
+// testStoreConformance checks the behaviour every Store implementation must share.
+// It only creates rows with random names so it can run against a shared database.
+func testStoreConformance(t *testing.T, store *Store) {
+	ctx := context.Background()
+	suffix := fmt.Sprint(rand.Int())
+
+	newUser := func(t *testing.T, email string) *User {
+		t.Helper()
+		u, err := store.User.CreateRequest(email, "password")
+		if err != nil {
+			t.Fatalf("Failed to build user: %v", err)
+		}
+		return u
+	}
+
+	newOrganization := func(t *testing.T, name string) *Organization {
+		t.Helper()
+		o, err := store.Organization.CreateRequest(name, "1 Cargo Way", "ops@example.com", Carrier)
+		if err != nil {
+			t.Fatalf("Failed to build organization: %v", err)
+		}
+		return o
+	}
+
+	user, err := store.User.CreateUser(ctx, newUser(t, "user-"+suffix+"@example.com"))
+	if err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+
+	t.Run("User Lookup", func(t *testing.T) {
+		byID, err := store.User.GetUserByID(ctx, user.ID)
+		if err != nil {
+			t.Fatalf("Failed to get user by id: %v", err)
+		}
+		if !byID.CreatedAt.Equal(user.CreatedAt) || byID.Email != user.Email {
+			t.Errorf("Expected %+v, got %+v", user, byID)
+		}
+
+		byEmail, err := store.User.GetUserByEmail(ctx, strings.ToUpper(user.Email))
+		if err != nil {
+			t.Fatalf("Expected case-insensitive email lookup, got %v", err)
+		}
+		if byEmail.ID != user.ID {
+			t.Errorf("Expected user %s, got %s", user.ID, byEmail.ID)
+		}
+
+		if _, err := store.User.GetUserByID(ctx, uuid.Must(uuid.NewV7())); !errors.Is(err, ErrNotFound) {
+			t.Errorf("Expected ErrNotFound for missing user, got %v", err)
+		}
+		if _, err := store.User.GetUserByEmail(ctx, "missing-"+suffix); !errors.Is(err, ErrNotFound) {
+			t.Errorf("Expected ErrNotFound for missing email, got %v", err)
+		}
+	})
+
+	t.Run("User Unique Email", func(t *testing.T) {
+		dup := newUser(t, "dup-"+suffix)
+		dup.Email = strings.ToUpper(user.Email)
+
+		_, err := store.User.CreateUser(ctx, dup)
+		var constraintErr *ConstraintError
+		if !errors.Is(err, ErrConflict) || !errors.As(err, &constraintErr) {
+			t.Fatalf("Expected ErrConflict for duplicate email, got %v", err)
+		}
+		if constraintErr.Constraint != "users_email_key" {
+			t.Errorf("Expected users_email_key, got %s", constraintErr.Constraint)
+		}
+	})
+
+	t.Run("User Update", func(t *testing.T) {
+		update, err := store.User.UpdateRequest("renamed-"+suffix, "")
+		if err != nil {
+			t.Fatalf("Failed to build update: %v", err)
+		}
+		update.ID = user.ID
+		update.FailedLoginAttempts = 3
+
+		updated, err := store.User.UpdateUser(ctx, update, time.Time{})
+		if err != nil {
+			t.Fatalf("Failed to update user: %v", err)
+		}
+		if updated.UserName != "renamed-"+suffix || updated.FailedLoginAttempts != 3 || updated.Email != user.Email {
+			t.Errorf("Unexpected updated user: %+v", updated)
+		}
+
+		if _, err := store.User.UpdateUser(ctx, update, user.UpdatedAt); !errors.Is(err, ErrPreconditionFailed) {
+			t.Errorf("Expected ErrPreconditionFailed for stale version, got %v", err)
+		}
+		if _, err := store.User.UpdateUser(ctx, update, updated.UpdatedAt); err != nil {
+			t.Errorf("Expected update with current version to succeed, got %v", err)
+		}
+
+		update.ID = uuid.Must(uuid.NewV7())
+		if _, err := store.User.UpdateUser(ctx, update, time.Time{}); err == nil || errors.Is(err, ErrPreconditionFailed) {
+			t.Errorf("Expected not found error updating missing user, got %v", err)
+		}
+	})
+
+	org, err := store.Organization.CreateOrganization(ctx, newOrganization(t, "org-"+suffix))
+	if err != nil {
+		t.Fatalf("Failed to create organization: %v", err)
+	}
+
+	t.Run("Organization Lookup", func(t *testing.T) {
+		byID, err := store.Organization.GetOrganizationByID(ctx, org.ID)
+		if err != nil {
+			t.Fatalf("Failed to get organization: %v", err)
+		}
+		if byID.UniqueURL != org.UniqueURL || byID.Name != org.Name {
+			t.Errorf("Expected %+v, got %+v", org, byID)
+		}
+
+		byURL, err := store.Organization.GetOrganizationByUniqueURL(ctx, strings.ToLower(org.UniqueURL))
+		if err != nil || byURL.ID != org.ID {
+			t.Errorf("Expected case-insensitive unique url lookup, got %v", err)
+		}
+
+		if _, err := store.Organization.GetOrganizationByName(ctx, strings.ToUpper(org.Name)); err == nil {
+			t.Errorf("Expected case-sensitive name lookup")
+		}
+		if _, err := store.Organization.GetOrganizationByID(ctx, uuid.Must(uuid.NewV7())); err == nil {
+			t.Errorf("Expected error for missing organization")
+		}
+	})
+
+	t.Run("Organization Unique URL", func(t *testing.T) {
+		dup := newOrganization(t, "dup-"+suffix)
+		dup.UniqueURL = org.UniqueURL
+		if _, err := store.Organization.CreateOrganization(ctx, dup); !errors.Is(err, ErrConflict) {
+			t.Errorf("Expected ErrConflict for duplicate unique_url, got %v", err)
+		}
+	})
+
+	t.Run("Organization Update", func(t *testing.T) {
+		update, err := store.Organization.UpdateRequest("", "", "2 Cargo Way", "", "")
+		if err != nil {
+			t.Fatalf("Failed to build update: %v", err)
+		}
+		update.ID = org.ID
+
+		updated, err := store.Organization.UpdateOrganization(ctx, update, org.UpdatedAt)
+		if err != nil {
+			t.Fatalf("Failed to update organization: %v", err)
+		}
+		if updated.Address != "2 Cargo Way" || updated.Name != org.Name {
+			t.Errorf("Unexpected updated organization: %+v", updated)
+		}
+
+		if _, err := store.Organization.UpdateOrganization(ctx, update, org.UpdatedAt); !errors.Is(err, ErrPreconditionFailed) {
+			t.Errorf("Expected ErrPreconditionFailed for stale version, got %v", err)
+		}
+	})
+
+	t.Run("Patch Zero Values", func(t *testing.T) {
+		patched, err := store.Organization.CreateOrganization(ctx, newOrganization(t, "patched-"+suffix))
+		if err != nil {
+			t.Fatalf("Failed to create organization: %v", err)
+		}
+
+		cleared, err := store.Organization.PatchOrganization(ctx, patched.ID, Patch{"contact_info": "", "unique_url": nil}, patched.UpdatedAt)
+		if err != nil {
+			t.Fatalf("Failed to patch organization: %v", err)
+		}
+		if cleared.ContactInfo != "" || cleared.UniqueURL != "" || cleared.Address != patched.Address {
+			t.Errorf("Expected contact_info and unique_url cleared and address kept, got %+v", cleared)
+		}
+		if _, err := store.Organization.PatchOrganization(ctx, patched.ID, Patch{"name": "stale"}, patched.UpdatedAt); !errors.Is(err, ErrPreconditionFailed) {
+			t.Errorf("Expected ErrPreconditionFailed for stale version, got %v", err)
+		}
+		if _, err := store.Organization.PatchOrganization(ctx, patched.ID, Patch{"deleted_at": nil}, time.Time{}); err == nil {
+			t.Errorf("Expected patching deleted_at to be rejected")
+		}
+
+		if _, err := store.User.PatchUser(ctx, user.ID, Patch{"is_admin": true, "failed_login_attempts": 3}, time.Time{}); err != nil {
+			t.Fatalf("Failed to patch user: %v", err)
+		}
+		reset, err := store.User.PatchUser(ctx, user.ID, Patch{"is_admin": false, "failed_login_attempts": 0}, time.Time{})
+		if err != nil {
+			t.Fatalf("Failed to patch user: %v", err)
+		}
+		if reset.IsAdmin || reset.FailedLoginAttempts != 0 {
+			t.Errorf("Expected is_admin and failed_login_attempts reset, got %v and %d", reset.IsAdmin, reset.FailedLoginAttempts)
+		}
+	})
+
+	t.Run("Organization List", func(t *testing.T) {
+		names := make([]string, 0, 5)
+		for i := range 5 {
+			o, err := store.Organization.CreateOrganization(ctx, newOrganization(t, fmt.Sprintf("list-%s-%d", suffix, i)))
+			if err != nil {
+				t.Fatalf("Failed to create organization: %v", err)
+			}
+			names = append(names, o.Name)
+		}
+
+		params := url.Values{"name[in]": {strings.Join(names, ",")}, "sort": {"-name"}, "limit": {"2"}}
+		req, err := ParsePageRequest("organizations", params)
+		if err != nil {
+			t.Fatalf("Failed to parse page request: %v", err)
+		}
+
+		var seen []string
+		var prev string
+		for {
+			page, err := store.Organization.ListOrganizations(ctx, req)
+			if err != nil {
+				t.Fatalf("Failed to list organizations: %v", err)
+			}
+			for _, o := range page.Items {
+				seen = append(seen, o.Name)
+			}
+			prev = page.Prev
+			if page.Next == "" {
+				break
+			}
+			cursor, err := DecodeCursor(page.Next)
+			if err != nil {
+				t.Fatalf("Failed to decode cursor: %v", err)
+			}
+			req.Cursor = &cursor
+		}
+
+		expected := []string{names[4], names[3], names[2], names[1], names[0]}
+		if strings.Join(seen, ",") != strings.Join(expected, ",") {
+			t.Fatalf("Expected %v, got %v", expected, seen)
+		}
+
+		cursor, err := DecodeCursor(prev)
+		if err != nil {
+			t.Fatalf("Failed to decode prev cursor: %v", err)
+		}
+		req.Cursor = &cursor
+		page, err := store.Organization.ListOrganizations(ctx, req)
+		if err != nil {
+			t.Fatalf("Failed to list previous page: %v", err)
+		}
+		if len(page.Items) != 2 || page.Items[0].Name != names[2] || page.Items[1].Name != names[1] {
+			t.Errorf("Unexpected previous page: %+v", page.Items)
+		}
+	})
+
+	t.Run("Organization List For User", func(t *testing.T) {
+		member, err := store.User.CreateUser(ctx, newUser(t, "member-"+suffix+"@example.com"))
+		if err != nil {
+			t.Fatalf("Failed to create user: %v", err)
+		}
+
+		statuses := []AssociationStatus{AssociationActive, AssociationPending, ""}
+		orgs := make([]*Organization, len(statuses))
+		for i, status := range statuses {
+			orgs[i], err = store.Organization.CreateOrganization(ctx, newOrganization(t, fmt.Sprintf("member-%s-%d", suffix, i)))
+			if err != nil {
+				t.Fatalf("Failed to create organization: %v", err)
+			}
+			if status == "" {
+				continue
+			}
+			a, _ := store.UserAssociation.CreateRequest(member.ID, orgs[i].ID, status, AllPermissions)
+			if _, err := store.UserAssociation.CreateUserAssociation(ctx, a); err != nil {
+				t.Fatalf("Failed to create association: %v", err)
+			}
+		}
+
+		page, err := store.Organization.ListOrganizationsForUser(ctx, member.ID, PageRequest{Limit: 10})
+		if err != nil {
+			t.Fatalf("Failed to list organizations: %v", err)
+		}
+		if len(page.Items) != 1 || page.Items[0].ID != orgs[0].ID {
+			t.Errorf("Expected only the active membership, got %+v", page.Items)
+		}
+	})
+
+	t.Run("User Association Constraints", func(t *testing.T) {
+		assoc, err := store.UserAssociation.CreateRequest(user.ID, org.ID, AssociationActive, AllPermissions)
+		if err != nil {
+			t.Fatalf("Failed to build association: %v", err)
+		}
+		created, err := store.UserAssociation.CreateUserAssociation(ctx, assoc)
+		if err != nil {
+			t.Fatalf("Failed to create association: %v", err)
+		}
+		if len(created.Permissions) != len(AllPermissions) {
+			t.Errorf("Expected %d permissions, got %v", len(AllPermissions), created.Permissions)
+		}
+
+		dup, _ := store.UserAssociation.CreateRequest(user.ID, org.ID, AssociationActive, nil)
+		if _, err := store.UserAssociation.CreateUserAssociation(ctx, dup); !errors.Is(err, ErrConflict) {
+			t.Errorf("Expected ErrConflict for duplicate association, got %v", err)
+		}
+
+		orphan, _ := store.UserAssociation.CreateRequest(uuid.Must(uuid.NewV7()), org.ID, AssociationActive, nil)
+		if _, err := store.UserAssociation.CreateUserAssociation(ctx, orphan); !errors.Is(err, ErrInvalidReference) {
+			t.Errorf("Expected ErrInvalidReference for missing user, got %v", err)
+		}
+
+		found, err := store.UserAssociation.GetUserAssociation(ctx, user.ID, org.ID)
+		if err != nil || found.ID != created.ID {
+			t.Errorf("Expected association %s, got %v", created.ID, err)
+		}
+	})
+
+	t.Run("Soft Delete", func(t *testing.T) {
+		deleted, err := store.Organization.CreateOrganization(ctx, newOrganization(t, "deleted-"+suffix))
+		if err != nil {
+			t.Fatalf("Failed to create organization: %v", err)
+		}
+
+		if _, err := store.Organization.DeleteOrganization(ctx, deleted.ID); err != nil {
+			t.Fatalf("Failed to delete organization: %v", err)
+		}
+		if _, err := store.Organization.DeleteOrganization(ctx, deleted.ID); err == nil {
+			t.Errorf("Expected deleting twice to fail")
+		}
+		if _, err := store.Organization.GetOrganizationByID(ctx, deleted.ID); err == nil {
+			t.Errorf("Expected deleted organization to be hidden")
+		}
+		if _, err := store.Organization.UpdateOrganization(ctx, &Organization{ID: deleted.ID, Address: "2 Cargo Way"}, time.Time{}); err == nil {
+			t.Errorf("Expected update of deleted organization to fail")
+		}
+
+		req, err := ParsePageRequest("organizations", url.Values{"name": {deleted.Name}})
+		if err != nil {
+			t.Fatalf("Failed to parse page request: %v", err)
+		}
+		if page, err := store.Organization.ListOrganizations(ctx, req); err != nil || len(page.Items) != 0 {
+			t.Errorf("Expected deleted organization to be excluded from list, got %v, %v", page, err)
+		}
+		if page, err := store.Organization.ListOrganizations(WithDeleted(ctx), req); err != nil || len(page.Items) != 1 {
+			t.Errorf("Expected deleted organization with include deleted, got %v, %v", page, err)
+		}
+
+		found, err := store.Organization.GetOrganizationByID(WithDeleted(ctx), deleted.ID)
+		if err != nil || found.DeletedAt == nil {
+			t.Errorf("Expected deleted organization with deleted_at set, got %v, %v", found, err)
+		}
+
+		restored, err := store.Organization.RestoreOrganization(ctx, deleted.ID)
+		if err != nil || restored.DeletedAt != nil {
+			t.Fatalf("Failed to restore organization: %v, %v", restored, err)
+		}
+		if _, err := store.Organization.GetOrganizationByID(ctx, deleted.ID); err != nil {
+			t.Errorf("Expected restored organization to be visible: %v", err)
+		}
+	})
+
+	t.Run("User Soft Delete", func(t *testing.T) {
+		deleted, err := store.User.CreateUser(ctx, newUser(t, "deleted-"+suffix+"@example.com"))
+		if err != nil {
+			t.Fatalf("Failed to create user: %v", err)
+		}
+
+		gone, err := store.User.DeleteUser(ctx, deleted.ID)
+		if err != nil || !gone.IsDeleted || gone.DeletedAt == nil {
+			t.Fatalf("Failed to delete user: %v, %v", gone, err)
+		}
+		if _, err := store.User.GetUserByEmail(ctx, deleted.Email); err == nil {
+			t.Errorf("Expected deleted user to be hidden")
+		}
+		if _, err := store.User.CreateUser(ctx, newUser(t, deleted.Email)); err == nil {
+			t.Errorf("Expected deleted user's email to stay reserved")
+		}
+
+		restored, err := store.User.RestoreUser(ctx, deleted.ID)
+		if err != nil || restored.IsDeleted || restored.DeletedAt != nil {
+			t.Errorf("Failed to restore user: %v, %v", restored, err)
+		}
+	})
+
+	t.Run("Uld Soft Delete", func(t *testing.T) {
+		u, err := store.UldInventory.CreateRequest(fmt.Sprintf("AKE%.12s", suffix), UldTypeAKE, UldInWarehouse, org.ID, Warehouse, org.ID)
+		if err != nil {
+			t.Fatalf("Failed to build uld: %v", err)
+		}
+		uld, err := store.UldInventory.CreateUldInventory(ctx, u)
+		if err != nil {
+			t.Fatalf("Failed to create uld: %v", err)
+		}
+
+		orphan, _ := store.UldInventory.CreateRequest(fmt.Sprintf("PMC%.12s", suffix), UldTypePMC, UldInWarehouse, org.ID, Warehouse, uuid.Must(uuid.NewV7()))
+		if _, err := store.UldInventory.CreateUldInventory(ctx, orphan); err == nil {
+			t.Errorf("Expected foreign key violation for missing organization")
+		}
+
+		if _, err := store.UldInventory.DeleteUldInventory(ctx, uld.ID); err != nil {
+			t.Fatalf("Failed to delete uld: %v", err)
+		}
+		if _, err := store.UldInventory.GetUldInventoryByID(ctx, uld.ID); err == nil {
+			t.Errorf("Expected deleted uld to be hidden")
+		}
+		if _, err := store.UldInventory.RestoreUldInventory(ctx, uld.ID); err != nil {
+			t.Fatalf("Failed to restore uld: %v", err)
+		}
+		if _, err := store.UldInventory.GetUldInventoryByID(ctx, uld.ID); err != nil {
+			t.Errorf("Expected restored uld to be visible: %v", err)
+		}
+	})
+
+	t.Run("Audit Log", func(t *testing.T) {
+		actorCtx := WithRequestID(WithActor(ctx, user.ID), "req-"+suffix)
+
+		audited, err := store.Organization.CreateOrganization(actorCtx, newOrganization(t, "audited-"+suffix))
+		if err != nil {
+			t.Fatalf("Failed to create organization: %v", err)
+		}
+		update := &Organization{ID: audited.ID, Address: "2 Cargo Way"}
+		if _, err := store.Organization.UpdateOrganization(actorCtx, update, time.Time{}); err != nil {
+			t.Fatalf("Failed to update organization: %v", err)
+		}
+
+		params := url.Values{"table_name": {"organizations"}, "row_id": {audited.ID.String()}, "actor_id": {user.ID.String()}}
+		req, err := ParsePageRequest("audit_log", params)
+		if err != nil {
+			t.Fatalf("Failed to parse page request: %v", err)
+		}
+		page, err := store.AuditLog.ListAuditLog(ctx, req)
+		if err != nil {
+			t.Fatalf("Failed to list audit log: %v", err)
+		}
+		if len(page.Items) != 2 {
+			t.Fatalf("Expected 2 audit entries, got %d", len(page.Items))
+		}
+
+		inserted, updated := page.Items[0], page.Items[1]
+		if inserted.Action != AuditInsert || updated.Action != AuditUpdate {
+			t.Errorf("Expected insert then update, got %s then %s", inserted.Action, updated.Action)
+		}
+		if updated.RequestID != "req-"+suffix || updated.ActorID == nil || *updated.ActorID != user.ID {
+			t.Errorf("Unexpected actor or request id: %v, %q", updated.ActorID, updated.RequestID)
+		}
+		if len(updated.Diff) != 2 {
+			t.Errorf("Expected address and updated_at in the diff, got %v", updated.Diff)
+		}
+		address := updated.Diff["address"]
+		if string(address.Before) != `"1 Cargo Way"` || string(address.After) != `"2 Cargo Way"` {
+			t.Errorf("Unexpected address change: %s -> %s", address.Before, address.After)
+		}
+
+		params = url.Values{"table_name": {"users"}, "row_id": {user.ID.String()}}
+		req, _ = ParsePageRequest("audit_log", params)
+		page, err = store.AuditLog.ListAuditLog(ctx, req)
+		if err != nil || len(page.Items) == 0 {
+			t.Fatalf("Expected the user insert to be audited, got %v", err)
+		}
+		if hashed := page.Items[0].Diff["hashed_password"]; string(hashed.After) != `"[redacted]"` {
+			t.Errorf("Expected hashed_password to be redacted, got %s", hashed.After)
+		}
+	})
+
+	t.Run("Outbox", func(t *testing.T) {
+		m, err := store.DeliveryManifest.CreateRequest(time.Now().UTC(), org.ID, org.ID, org.ID, "", user.ID, org.ID)
+		if err != nil {
+			t.Fatalf("Failed to build manifest: %v", err)
+		}
+		manifest, err := store.DeliveryManifest.CreateDeliveryManifest(ctx, m)
+		if err != nil {
+			t.Fatalf("Failed to create manifest: %v", err)
+		}
+
+		if _, err := store.DeliveryManifest.UpdateManifestStatus(ctx, manifest.ID, ManifestAccepted); !errors.Is(err, ErrInvalidTransition) {
+			t.Errorf("Expected ErrInvalidTransition accepting a draft, got %v", err)
+		}
+		for _, status := range []ManifestStatus{ManifestSubmitted, ManifestAccepted} {
+			if _, err := store.DeliveryManifest.UpdateManifestStatus(ctx, manifest.ID, status); err != nil {
+				t.Fatalf("Failed to move manifest to %s: %v", status, err)
+			}
+		}
+
+		claim := func() []*OutboxEvent {
+			t.Helper()
+			events, err := store.Outbox.ClaimPending(ctx, 1000, time.Minute)
+			if err != nil {
+				t.Fatalf("Failed to claim events: %v", err)
+			}
+			var ours []*OutboxEvent
+			for _, e := range events {
+				if e.AggregateID == manifest.ID {
+					ours = append(ours, e)
+				}
+			}
+			return ours
+		}
+
+		events := claim()
+		if len(events) != 1 || events[0].EventType != EventManifestSubmitted {
+			t.Fatalf("Expected only the submitted event to be claimable, got %v", events)
+		}
+		if again := claim(); len(again) != 0 {
+			t.Errorf("Expected a leased event not to be claimed twice, got %v", again)
+		}
+		if err := store.Outbox.MarkPublished(ctx, events[0].ID); err != nil {
+			t.Fatalf("Failed to mark published: %v", err)
+		}
+
+		events = claim()
+		if len(events) != 1 || events[0].EventType != EventManifestAccepted {
+			t.Fatalf("Expected the accepted event next, got %v", events)
+		}
+		var payload ManifestStatusChanged
+		if err := json.Unmarshal(events[0].Payload, &payload); err != nil || payload.From != ManifestSubmitted || payload.To != ManifestAccepted {
+			t.Errorf("Unexpected payload %s: %v", events[0].Payload, err)
+		}
+		if err := store.Outbox.MarkFailed(ctx, events[0].ID, errors.New("sink down"), time.Now().Add(-time.Second)); err != nil {
+			t.Fatalf("Failed to mark failed: %v", err)
+		}
+		if retried := claim(); len(retried) != 1 || retried[0].Attempts != 1 || retried[0].LastError != "sink down" {
+			t.Errorf("Expected the failed event to be retried, got %v", retried)
+		}
+	})
+
+	t.Run("Idempotency", func(t *testing.T) {
+		owner, key := user.ID.String(), "key-"+suffix
+
+		claimed, ok, err := store.Idempotency.Claim(ctx, owner, key, "hash", time.Minute, time.Hour)
+		if err != nil || !ok {
+			t.Fatalf("Expected to claim a new key, got %v, %v", ok, err)
+		}
+		if claimed.Completed() {
+			t.Errorf("Expected a claimed key to be in progress")
+		}
+
+		existing, ok, err := store.Idempotency.Claim(ctx, owner, key, "other", time.Minute, time.Hour)
+		if err != nil || ok {
+			t.Fatalf("Expected the key to be taken, got %v, %v", ok, err)
+		}
+		if existing.RequestHash != "hash" {
+			t.Errorf("Expected the first request's hash, got %q", existing.RequestHash)
+		}
+		if _, ok, err := store.Idempotency.Claim(ctx, "other-"+suffix, key, "hash", time.Minute, time.Hour); err != nil || !ok {
+			t.Errorf("Expected keys to be scoped to their owner, got %v, %v", ok, err)
+		}
+
+		header := StoredHeader{"Content-Type": {"application/json"}}
+		if err := store.Idempotency.Complete(ctx, owner, key, 201, header, []byte(`{"id":1}`)); err != nil {
+			t.Fatalf("Failed to complete key: %v", err)
+		}
+		if err := store.Idempotency.Release(ctx, owner, key); err != nil {
+			t.Fatalf("Failed to release key: %v", err)
+		}
+		stored, ok, err := store.Idempotency.Claim(ctx, owner, key, "hash", time.Minute, time.Hour)
+		if err != nil || ok {
+			t.Fatalf("Expected a completed key not to be released, got %v, %v", ok, err)
+		}
+		if stored.Status != 201 || string(stored.Body) != `{"id":1}` || stored.Header["Content-Type"][0] != "application/json" {
+			t.Errorf("Unexpected stored response %d %v %s", stored.Status, stored.Header, stored.Body)
+		}
+
+		released := "released-" + suffix
+		store.Idempotency.Claim(ctx, owner, released, "hash", time.Minute, time.Hour)
+		if err := store.Idempotency.Release(ctx, owner, released); err != nil {
+			t.Fatalf("Failed to release key: %v", err)
+		}
+		if _, ok, err := store.Idempotency.Claim(ctx, owner, released, "other", time.Minute, time.Hour); err != nil || !ok {
+			t.Errorf("Expected a released key to be claimable, got %v, %v", ok, err)
+		}
+
+		// A request that crashed before completing holds its key only until its lease ends.
+		abandoned := "abandoned-" + suffix
+		store.Idempotency.Claim(ctx, owner, abandoned, "hash", -time.Minute, time.Hour)
+		retried, ok, err := store.Idempotency.Claim(ctx, owner, abandoned, "hash", time.Minute, time.Hour)
+		if err != nil || !ok {
+			t.Fatalf("Expected a key with a lapsed lease to be taken over, got %v, %v", ok, err)
+		}
+		if retried.LockedUntil == nil || !retried.LockedUntil.After(time.Now()) {
+			t.Errorf("Expected the retry to hold a fresh lease, got %v", retried.LockedUntil)
+		}
+		if _, ok, _ := store.Idempotency.Claim(ctx, owner, abandoned, "hash", time.Minute, time.Hour); ok {
+			t.Errorf("Expected the retry's lease to lock the key")
+		}
+		if err := store.Idempotency.Complete(ctx, owner, abandoned, 200, nil, nil); err != nil {
+			t.Fatalf("Failed to complete key: %v", err)
+		}
+		if err := store.Idempotency.Complete(ctx, owner, abandoned, 201, nil, nil); !errors.Is(err, ErrNotFound) {
+			t.Errorf("Expected a completed key not to be completed again, got %v", err)
+		}
+
+		expired := "expired-" + suffix
+		store.Idempotency.Claim(ctx, owner, expired, "hash", time.Minute, -time.Minute)
+		if _, ok, err := store.Idempotency.Claim(ctx, owner, expired, "other", time.Minute, -time.Minute); err != nil || !ok {
+			t.Errorf("Expected an expired key to be claimed afresh, got %v, %v", ok, err)
+		}
+		purged, err := store.Idempotency.PurgeExpired(ctx, time.Now())
+		if err != nil {
+			t.Fatalf("Failed to purge keys: %v", err)
+		}
+		if purged < 1 {
+			t.Errorf("Expected the expired key to be purged, got %d", purged)
+		}
+		if _, ok, _ := store.Idempotency.Claim(ctx, owner, key, "hash", time.Minute, time.Hour); ok {
+			t.Errorf("Expected an unexpired key to survive the purge")
+		}
+	})
+
+	t.Run("Transaction Rollback", func(t *testing.T) {
+		rolledBack := newOrganization(t, "rollback-"+suffix)
+		errAbort := errors.New("abort")
+
+		err := store.WithTx(ctx, func(tx *Store) error {
+			if _, err := tx.Organization.CreateOrganization(ctx, rolledBack); err != nil {
+				return err
+			}
+			if _, err := tx.Organization.GetOrganizationByID(ctx, rolledBack.ID); err != nil {
+				t.Errorf("Expected organization to be visible inside the transaction: %v", err)
+			}
+			return errAbort
+		})
+		if !errors.Is(err, errAbort) {
+			t.Fatalf("Expected abort error, got %v", err)
+		}
+
+		if _, err := store.Organization.GetOrganizationByID(ctx, rolledBack.ID); err == nil {
+			t.Errorf("Expected organization to be rolled back")
+		}
+
+		req, _ := ParsePageRequest("audit_log", url.Values{"row_id": {rolledBack.ID.String()}})
+		if page, err := store.AuditLog.ListAuditLog(ctx, req); err != nil || len(page.Items) != 0 {
+			t.Errorf("Expected the audit entry to be rolled back, got %v, %v", page, err)
+		}
+	})
+
+	t.Run("Transaction Commit", func(t *testing.T) {
+		committed := newOrganization(t, "commit-"+suffix)
+
+		err := store.WithTx(ctx, func(tx *Store) error {
+			_, err := tx.Organization.CreateOrganization(ctx, committed)
+			return err
+		})
+		if err != nil {
+			t.Fatalf("Failed to commit: %v", err)
+		}
+
+		if _, err := store.Organization.GetOrganizationByID(ctx, committed.ID); err != nil {
+			t.Errorf("Expected organization to be committed: %v", err)
+		}
+	})
+}
+
+func TestMemoryPurgeDeleted(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+
+	u, _ := store.User.CreateRequest("purge@example.com", "password")
+	user, err := store.User.CreateUser(ctx, u)
+	if err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+	o, _ := store.Organization.CreateRequest("purge", "1 Cargo Way", "ops@example.com", Warehouse)
+	org, err := store.Organization.CreateOrganization(ctx, o)
+	if err != nil {
+		t.Fatalf("Failed to create organization: %v", err)
+	}
+	a, _ := store.UserAssociation.CreateRequest(user.ID, org.ID, AssociationActive, nil)
+	if _, err := store.UserAssociation.CreateUserAssociation(ctx, a); err != nil {
+		t.Fatalf("Failed to create association: %v", err)
+	}
+	m, _ := store.DeliveryManifest.CreateRequest(time.Now().UTC(), org.ID, org.ID, org.ID, "", user.ID, org.ID)
+	manifest, err := store.DeliveryManifest.CreateDeliveryManifest(ctx, m)
+	if err != nil {
+		t.Fatalf("Failed to create manifest: %v", err)
+	}
+
+	if _, err := store.User.DeleteUser(ctx, user.ID); err != nil {
+		t.Fatalf("Failed to delete user: %v", err)
+	}
+	if _, err := store.Organization.DeleteOrganization(ctx, org.ID); err != nil {
+		t.Fatalf("Failed to delete organization: %v", err)
+	}
+
+	// The live manifest still references both rows, so nothing can be purged yet.
+	if n, err := store.PurgeDeleted(ctx, time.Now().Add(time.Minute)); err != nil || n != 0 {
+		t.Fatalf("Expected nothing purged, got %d, %v", n, err)
+	}
+
+	if _, err := store.DeliveryManifest.DeleteDeliveryManifest(ctx, manifest.ID); err != nil {
+		t.Fatalf("Failed to delete manifest: %v", err)
+	}
+	if n, err := store.PurgeDeleted(ctx, time.Now().Add(-time.Minute)); err != nil || n != 0 {
+		t.Fatalf("Expected rows inside the retention window to be kept, got %d, %v", n, err)
+	}
+	if n, err := store.PurgeDeleted(ctx, time.Now().Add(time.Minute)); err != nil || n != 3 {
+		t.Fatalf("Expected 3 rows purged, got %d, %v", n, err)
+	}
+
+	if _, err := store.User.GetUserByID(WithDeleted(ctx), user.ID); err == nil {
+		t.Errorf("Expected user to be purged")
+	}
+	if _, err := store.UserAssociation.GetUserAssociation(ctx, user.ID, org.ID); err == nil {
+		t.Errorf("Expected association to be purged with its user and organization")
+	}
+}
@@ -0,0 +1,69 @@
+package data
+
+import (
+	"context"
+	"strings"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const tracerName = "github.com/kevin-griley/api/internal/data"
+
+// startQuerySpan starts a span for query named after its operation and table, such as
+// "SELECT users". The caller must End it.
+func startQuerySpan(ctx context.Context, query string) (context.Context, trace.Span) {
+	operation, table := describeQuery(query)
+
+	name := operation
+	if table != "" {
+		name += " " + table
+	}
+	return otel.Tracer(tracerName).Start(ctx, name,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			semconv.DBSystemPostgreSQL,
+			semconv.DBOperationName(operation),
+			semconv.DBCollectionName(table),
+			semconv.DBQueryText(query),
+		),
+	)
+}
+
+// setSpanError records err on span and marks it failed. A nil err does nothing.
+func setSpanError(span trace.Span, err error) {
+	if err == nil {
+		return
+	}
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+}
+
+// describeQuery returns the operation and table of a query built by this package: the
+// table follows FROM in a SELECT or DELETE, INTO in an INSERT and UPDATE in an UPDATE.
+func describeQuery(query string) (operation, table string) {
+	fields := strings.Fields(query)
+	if len(fields) == 0 {
+		return "", ""
+	}
+	operation = strings.ToUpper(fields[0])
+
+	keyword := map[string]string{
+		"SELECT": "FROM",
+		"DELETE": "FROM",
+		"INSERT": "INTO",
+		"UPDATE": "UPDATE",
+	}[operation]
+	if keyword == "" {
+		return operation, ""
+	}
+
+	for i, f := range fields[:len(fields)-1] {
+		if strings.EqualFold(f, keyword) {
+			return operation, strings.Trim(fields[i+1], `"(),;`)
+		}
+	}
+	return operation, ""
+}
@@ -0,0 +1,35 @@
+package data
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestDescribeQuery(t *testing.T) {
+	id := uuid.New()
+	selectQuery, _, _ := BuildSelectQuery("users", map[string]any{"id": id})
+	insertQuery, _, _ := BuildInsertQuery("uld_inventories", map[string]any{"uld_number": "AKE12345"})
+	updateQuery, _, _ := BuildUpdateQuery("delivery_manifests", map[string]any{"status": "closed"}, map[string]any{"id": id})
+	deleteQuery := fmt.Sprintf("DELETE FROM %s WHERE id = $1", "organizations")
+
+	testCases := []struct {
+		query            string
+		operation, table string
+	}{
+		{selectQuery, "SELECT", "users"},
+		{insertQuery, "INSERT", "uld_inventories"},
+		{updateQuery, "UPDATE", "delivery_manifests"},
+		{deleteQuery, "DELETE", "organizations"},
+		{"SELECT pg_advisory_lock($1)", "SELECT", ""},
+		{"", "", ""},
+	}
+
+	for _, tc := range testCases {
+		operation, table := describeQuery(tc.query)
+		if operation != tc.operation || table != tc.table {
+			t.Errorf("describeQuery(%q) = %q, %q; expected %q, %q", tc.query, operation, table, tc.operation, tc.table)
+		}
+	}
+}
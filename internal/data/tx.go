@@ -0,0 +1,140 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// MaxTxAttempts is the number of times WithTx runs a transaction that keeps
+// failing with a serialization failure or deadlock before giving up.
+const MaxTxAttempts = 3
+
+type txBeginner interface {
+	BeginTx(ctx context.Context, opts *sql.TxOptions) (*sql.Tx, error)
+}
+
+// WithTx runs fn inside a database transaction using the default isolation level.
+// See WithTxOptions.
+func (s *Store) WithTx(ctx context.Context, fn func(tx *Store) error) error {
+	return s.WithTxOptions(ctx, nil, fn)
+}
+
+// WithTxOptions runs fn with a Store whose every store is bound to a single transaction.
+// The transaction is committed when fn returns nil and rolled back when it returns an
+// error or panics. Serialization failures and deadlocks are retried up to MaxTxAttempts
+// times, so fn must be safe to run more than once.
+//
+// Calling WithTx on a Store that is already bound to a transaction runs fn in that
+// transaction rather than starting a new one.
+//
+// Example usage:
+//
+//	err := store.WithTx(ctx, func(tx *data.Store) error {
+//	     org, err := tx.Organization.CreateOrganization(ctx, org)
+//	     if err != nil {
+//	          return err
+//	     }
+//	     _, err = tx.UserAssociation.CreateUserAssociation(ctx, assoc)
+//	     return err
+//	})
+func (s *Store) WithTxOptions(ctx context.Context, opts *sql.TxOptions, fn func(tx *Store) error) error {
+	if s.memory != nil {
+		return s.memory.withTx(ctx, fn)
+	}
+
+	markWrite(ctx)
+
+	beginner, ok := s.db.conn.(txBeginner)
+	if !ok {
+		return fn(s)
+	}
+
+	var err error
+	for attempt := 1; attempt <= MaxTxAttempts; attempt++ {
+		err = s.runTx(ctx, beginner, opts, fn)
+		if err == nil || !isRetryableTxError(err) {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(time.Duration(attempt) * 10 * time.Millisecond):
+		}
+	}
+
+	return fmt.Errorf("transaction failed after %d attempts: %w", MaxTxAttempts, err)
+}
+
+func (s *Store) runTx(ctx context.Context, beginner txBeginner, opts *sql.TxOptions, fn func(tx *Store) error) (err error) {
+	tx, err := beginner.BeginTx(ctx, opts)
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if p := recover(); p != nil {
+			tx.Rollback()
+			panic(p)
+		}
+	}()
+
+	if err := fn(newStore(s.db.withTx(tx))); err != nil {
+		if rbErr := tx.Rollback(); rbErr != nil {
+			return errors.Join(err, rbErr)
+		}
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// inTx runs fn on a transaction so that several statements commit together. When db is
+// already bound to a transaction, fn runs in it.
+func (db *DB) inTx(ctx context.Context, fn func(db *DB) error) (err error) {
+	markWrite(ctx)
+
+	beginner, ok := db.conn.(txBeginner)
+	if !ok {
+		return fn(db)
+	}
+
+	tx, err := beginner.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if p := recover(); p != nil {
+			tx.Rollback()
+			panic(p)
+		}
+	}()
+
+	if err := fn(db.withTx(tx)); err != nil {
+		if rbErr := tx.Rollback(); rbErr != nil {
+			return errors.Join(err, rbErr)
+		}
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func isRetryableTxError(err error) bool {
+	var pqErr *pq.Error
+	if !errors.As(err, &pqErr) {
+		return false
+	}
+	switch pqErr.Code {
+	case "40001", // serialization_failure
+		"40P01": // deadlock_detected
+		return true
+	}
+	return false
+}
@@ -0,0 +1,31 @@
+package data
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/lib/pq"
+)
+
+func TestIsRetryableTxError(t *testing.T) {
+	testCases := []struct {
+		name     string
+		err      error
+		expected bool
+	}{
+		{name: "Serialization Failure", err: &pq.Error{Code: "40001"}, expected: true},
+		{name: "Deadlock", err: &pq.Error{Code: "40P01"}, expected: true},
+		{name: "Wrapped Serialization Failure", err: fmt.Errorf("update: %w", &pq.Error{Code: "40001"}), expected: true},
+		{name: "Unique Violation", err: &pq.Error{Code: "23505"}, expected: false},
+		{name: "Plain Error", err: errors.New("boom"), expected: false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := isRetryableTxError(tc.err); got != tc.expected {
+				t.Errorf("Expected %v, got %v", tc.expected, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,171 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func (s *uldInventoryStoreImpl) CreateRequest(uldNumber string, uldType UldType, uldStatus UldStatus, locationID uuid.UUID, locationType OrganizationType, organizationID uuid.UUID) (*UldInventory, error) {
+
+	uldId, err := uuid.NewV7()
+	if err != nil {
+		return nil, err
+	}
+
+	return &UldInventory{
+		ID:                  uldId,
+		CreatedAt:           time.Now().UTC(),
+		UpdatedAt:           time.Now().UTC(),
+		UldNumber:           uldNumber,
+		UldType:             uldType,
+		UldStatus:           uldStatus,
+		CurrentLocationID:   locationID,
+		CurrentLocationType: locationType,
+		OrganizationID:      organizationID,
+	}, nil
+}
+
+func (s *uldInventoryStoreImpl) CreateUldInventory(ctx context.Context, u *UldInventory) (*UldInventory, error) {
+
+	data := map[string]any{
+		"id":                    u.ID,
+		"created_at":            u.CreatedAt,
+		"updated_at":            u.UpdatedAt,
+		"uld_number":            u.UldNumber,
+		"uld_type":              u.UldType,
+		"uld_status":            u.UldStatus,
+		"current_location_id":   u.CurrentLocationID,
+		"current_location_type": u.CurrentLocationType,
+		"organization_id":       u.OrganizationID,
+	}
+
+	uld, err := insertRow[UldInventory](ctx, s.db, "uld_inventories", data)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("failed to create uld")
+	}
+	return uld, err
+
+}
+
+func (s *uldInventoryStoreImpl) GetUldInventoryByID(ctx context.Context, ID uuid.UUID) (*UldInventory, error) {
+
+	data := map[string]any{
+		"id": ID,
+	}
+
+	query, values, err := buildSelectQuery("uld_inventories", data, includesDeleted(ctx))
+	if err != nil {
+		return nil, err
+	}
+
+	uld, err := queryOne(ctx, s.db.reader(ctx), scanRow[UldInventory], query, values...)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, notFound("uld", ID)
+	}
+	return uld, err
+
+}
+
+func (s *uldInventoryStoreImpl) ListUldInventories(ctx context.Context, page PageRequest) (*Page[UldInventory], error) {
+	return queryPage(ctx, s.db, scanRow[UldInventory], columnValue[UldInventory], "uld_inventories", nil, page)
+}
+
+func (s *uldInventoryStoreImpl) DeleteUldInventory(ctx context.Context, ID uuid.UUID) (*UldInventory, error) {
+	return softDelete[UldInventory](ctx, s.db, "uld_inventories", "uld", ID, nil)
+}
+
+func (s *uldInventoryStoreImpl) RestoreUldInventory(ctx context.Context, ID uuid.UUID) (*UldInventory, error) {
+	return restore[UldInventory](ctx, s.db, "uld_inventories", "uld", ID, nil)
+}
+
+// UpdateUldStatus moves a ULD to status and enqueues an EventUldStatusChanged in the same
+// transaction. Setting the status the ULD already has changes nothing.
+func (s *uldInventoryStoreImpl) UpdateUldStatus(ctx context.Context, ID uuid.UUID, status UldStatus) (*UldInventory, error) {
+	var uld *UldInventory
+	err := s.db.inTx(ctx, func(db *DB) error {
+		current, err := lockRow[UldInventory](ctx, db, "uld_inventories", ID)
+		if err != nil {
+			return err
+		}
+		if current == nil || current.DeletedAt != nil {
+			return notFound("uld", ID)
+		}
+		if current.UldStatus == status {
+			uld = current
+			return nil
+		}
+
+		uld, err = updateRow[UldInventory](ctx, db, "uld_inventories", AuditUpdate,
+			map[string]any{"uld_status": status, "updated_at": time.Now().UTC()},
+			map[string]any{"id": ID})
+		if err != nil {
+			return err
+		}
+
+		_, err = enqueueEvent(ctx, db, "uld", ID, EventUldStatusChanged, UldStatusChanged{
+			UldID: ID,
+			From:  current.UldStatus,
+			To:    status,
+		})
+		return err
+	})
+	return uld, err
+}
+
+type uldInventoryStoreImpl struct {
+	db *DB
+}
+
+var NewUldInventoryStore = func(db *DB) UldInventoryStore {
+	return &uldInventoryStoreImpl{
+		db: db,
+	}
+}
+
+type UldInventoryStore interface {
+	GetUldInventoryByID(ctx context.Context, ID uuid.UUID) (*UldInventory, error)
+	ListUldInventories(ctx context.Context, page PageRequest) (*Page[UldInventory], error)
+
+	CreateUldInventory(ctx context.Context, u *UldInventory) (*UldInventory, error)
+	CreateRequest(uldNumber string, uldType UldType, uldStatus UldStatus, locationID uuid.UUID, locationType OrganizationType, organizationID uuid.UUID) (*UldInventory, error)
+
+	DeleteUldInventory(ctx context.Context, ID uuid.UUID) (*UldInventory, error)
+	RestoreUldInventory(ctx context.Context, ID uuid.UUID) (*UldInventory, error)
+
+	UpdateUldStatus(ctx context.Context, ID uuid.UUID, status UldStatus) (*UldInventory, error)
+}
+
+type UldType string
+
+const (
+	UldTypePMC UldType = "PMC"
+	UldTypeAKE UldType = "AKE"
+	UldTypeLD3 UldType = "LD3"
+	UldTypeLD7 UldType = "LD7"
+)
+
+type UldStatus string
+
+const (
+	UldDelivered   UldStatus = "delivered"
+	UldInTransit   UldStatus = "in_transit"
+	UldInWarehouse UldStatus = "in_warehouse"
+)
+
+type UldInventory struct {
+	ID                  uuid.UUID        `json:"id" db:"id"`
+	CreatedAt           time.Time        `json:"created_at" db:"created_at"`
+	UpdatedAt           time.Time        `json:"updated_at" db:"updated_at"`
+	UldNumber           string           `json:"uld_number" db:"uld_number"`
+	UldType             UldType          `json:"uld_type" db:"uld_type"`
+	UldStatus           UldStatus        `json:"uld_status" db:"uld_status"`
+	CurrentLocationID   uuid.UUID        `json:"current_location_id" db:"current_location_id"`
+	CurrentLocationType OrganizationType `json:"current_location_type" db:"current_location_type"`
+	OrganizationID      uuid.UUID        `json:"organization_id" db:"organization_id"`
+	DeletedAt           *time.Time       `json:"deleted_at,omitempty" db:"deleted_at"`
+}
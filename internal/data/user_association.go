@@ -0,0 +1,160 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+func (s *userAssociationStoreImpl) CreateRequest(userID, organizationID uuid.UUID, status AssociationStatus, permissions Permissions) (*UserAssociation, error) {
+
+	associationId, err := uuid.NewV7()
+	if err != nil {
+		return nil, err
+	}
+
+	return &UserAssociation{
+		ID:             associationId,
+		CreatedAt:      time.Now().UTC(),
+		UpdatedAt:      time.Now().UTC(),
+		Status:         status,
+		Permissions:    permissions,
+		UserID:         userID,
+		OrganizationID: organizationID,
+	}, nil
+}
+
+func (s *userAssociationStoreImpl) CreateUserAssociation(ctx context.Context, a *UserAssociation) (*UserAssociation, error) {
+
+	data := map[string]any{
+		"id":              a.ID,
+		"created_at":      a.CreatedAt,
+		"updated_at":      a.UpdatedAt,
+		"status":          a.Status,
+		"permissions":     a.Permissions,
+		"user_id":         a.UserID,
+		"organization_id": a.OrganizationID,
+	}
+
+	assoc, err := insertRow[UserAssociation](ctx, s.db, "user_associations", data)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("failed to create user association")
+	}
+	return assoc, err
+
+}
+
+func (s *userAssociationStoreImpl) GetUserAssociation(ctx context.Context, userID, organizationID uuid.UUID) (*UserAssociation, error) {
+
+	data := map[string]any{
+		"user_id":         userID,
+		"organization_id": organizationID,
+	}
+
+	query, values, err := BuildSelectQuery("user_associations", data)
+	if err != nil {
+		return nil, err
+	}
+
+	assoc, err := queryOne(ctx, s.db.reader(ctx), scanRow[UserAssociation], query, values...)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("user %s association with organization %s %w", userID, organizationID, ErrNotFound)
+	}
+	return assoc, err
+
+}
+
+type userAssociationStoreImpl struct {
+	db *DB
+}
+
+var NewUserAssociationStore = func(db *DB) UserAssociationStore {
+	return &userAssociationStoreImpl{
+		db: db,
+	}
+}
+
+type UserAssociationStore interface {
+	GetUserAssociation(ctx context.Context, userID, organizationID uuid.UUID) (*UserAssociation, error)
+
+	CreateUserAssociation(ctx context.Context, a *UserAssociation) (*UserAssociation, error)
+	CreateRequest(userID, organizationID uuid.UUID, status AssociationStatus, permissions Permissions) (*UserAssociation, error)
+}
+
+type AssociationStatus string
+
+const (
+	AssociationPending  AssociationStatus = "pending"
+	AssociationActive   AssociationStatus = "active"
+	AssociationInactive AssociationStatus = "inactive"
+)
+
+type Permission string
+
+const (
+	PermissionUldRead           Permission = "uld.read"
+	PermissionUldWrite          Permission = "uld.write"
+	PermissionManifestRead      Permission = "manifest.read"
+	PermissionManifestWrite     Permission = "manifest.write"
+	PermissionUserRead          Permission = "user.read"
+	PermissionUserWrite         Permission = "user.write"
+	PermissionOrganizationRead  Permission = "organization.read"
+	PermissionOrganizationWrite Permission = "organization.write"
+)
+
+// Permissions maps to a permissions_enum[] column.
+type Permissions []Permission
+
+func (p Permissions) Value() (driver.Value, error) {
+	s := make(pq.StringArray, len(p))
+	for i, permission := range p {
+		s[i] = string(permission)
+	}
+	return s.Value()
+}
+
+func (p *Permissions) Scan(src any) error {
+	var s pq.StringArray
+	if err := s.Scan(src); err != nil {
+		return err
+	}
+	*p = make(Permissions, len(s))
+	for i, permission := range s {
+		(*p)[i] = Permission(permission)
+	}
+	return nil
+}
+
+// Has reports whether permission is among p.
+func (p Permissions) Has(permission Permission) bool {
+	return slices.Contains(p, permission)
+}
+
+// AllPermissions lists every permission, in the order of the permissions_enum type.
+var AllPermissions = Permissions{
+	PermissionUldRead,
+	PermissionUldWrite,
+	PermissionManifestRead,
+	PermissionManifestWrite,
+	PermissionUserRead,
+	PermissionUserWrite,
+	PermissionOrganizationRead,
+	PermissionOrganizationWrite,
+}
+
+type UserAssociation struct {
+	ID             uuid.UUID         `json:"id" db:"id"`
+	CreatedAt      time.Time         `json:"created_at" db:"created_at"`
+	UpdatedAt      time.Time         `json:"updated_at" db:"updated_at"`
+	Status         AssociationStatus `json:"status" db:"status"`
+	Permissions    Permissions       `json:"permissions" db:"permissions"`
+	UserID         uuid.UUID         `json:"user_id" db:"user_id"`
+	OrganizationID uuid.UUID         `json:"organization_id" db:"organization_id"`
+}
@@ -1,7 +1,9 @@
 package data
 
 import (
+	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"time"
 
@@ -9,8 +11,17 @@ import (
 	"golang.org/x/crypto/bcrypt"
 )
 
+// HashPassword returns the bcrypt hash stored in hashed_password.
+func HashPassword(password string) (string, error) {
+	encpwd, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+	return string(encpwd), nil
+}
+
 func (s *userStoreImpl) CreateRequest(Email, Password string) (*User, error) {
-	encpwd, err := bcrypt.GenerateFromPassword([]byte(Password), bcrypt.DefaultCost)
+	encpwd, err := HashPassword(Password)
 	if err != nil {
 		return nil, err
 	}
@@ -26,13 +37,13 @@ func (s *userStoreImpl) CreateRequest(Email, Password string) (*User, error) {
 		UpdatedAt:      time.Now().UTC(),
 		UserName:       Email,
 		Email:          Email,
-		HashedPassword: string(encpwd),
+		HashedPassword: encpwd,
 		LastRequest:    time.Now().UTC(),
 		LastLogin:      time.Now().UTC(),
 	}, nil
 }
 
-func (s *userStoreImpl) CreateUser(u *User) (*User, error) {
+func (s *userStoreImpl) CreateUser(ctx context.Context, u *User) (*User, error) {
 
 	data := map[string]any{
 		"id":              u.ID,
@@ -45,21 +56,11 @@ func (s *userStoreImpl) CreateUser(u *User) (*User, error) {
 		"last_login":      u.LastLogin,
 	}
 
-	query, values, err := BuildInsertQuery("users", data)
-	if err != nil {
-		return nil, err
+	user, err := insertRow[User](ctx, s.db, "users", data)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("failed to create user")
 	}
-
-	rows, err := s.db.Query(query, values...)
-	if err != nil {
-		return nil, err
-	}
-
-	if rows.Next() {
-		return scanIntoUser(rows)
-	}
-
-	return nil, fmt.Errorf("failed to create user")
+	return user, err
 
 }
 
@@ -68,11 +69,11 @@ func (s *userStoreImpl) UpdateRequest(UserName, Password string) (*User, error)
 	user := new(User)
 
 	if Password != "" {
-		encpwd, err := bcrypt.GenerateFromPassword([]byte(Password), bcrypt.DefaultCost)
+		encpwd, err := HashPassword(Password)
 		if err != nil {
 			return nil, err
 		}
-		user.HashedPassword = string(encpwd)
+		user.HashedPassword = encpwd
 	}
 
 	if UserName != "" {
@@ -83,101 +84,98 @@ func (s *userStoreImpl) UpdateRequest(UserName, Password string) (*User, error)
 
 }
 
-func (s *userStoreImpl) UpdateUser(u *User) (*User, error) {
+// UpdateUser writes the non-zero fields of u. Use PatchUser to write zero values.
+// When expectedUpdatedAt is non-zero the update only applies if the row's updated_at still
+// matches it, and ErrPreconditionFailed is returned otherwise.
+func (s *userStoreImpl) UpdateUser(ctx context.Context, u *User, expectedUpdatedAt time.Time) (*User, error) {
+	return s.PatchUser(ctx, u.ID, userUpdatePatch(u), expectedUpdatedAt)
+}
+
+// PatchUser writes every column in patch, including zero values and NULLs, with the same
+// precondition as UpdateUser.
+func (s *userStoreImpl) PatchUser(ctx context.Context, ID uuid.UUID, patch Patch, expectedUpdatedAt time.Time) (*User, error) {
+	user, err := patchRow[User](ctx, s.db, "users", ID, patch, expectedUpdatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, notFound("user", ID)
+	}
+	return user, err
+}
 
-	updateData := make(map[string]any)
-	updateData["updated_at"] = time.Now().UTC()
+// userUpdatePatch returns a Patch of the non-zero fields of u.
+func userUpdatePatch(u *User) Patch {
+	patch := Patch{}
 
 	if u.UserName != "" {
-		updateData["user_name"] = u.UserName
+		patch["user_name"] = u.UserName
 	}
 	if u.Email != "" {
-		updateData["email"] = u.Email
+		patch["email"] = u.Email
 	}
 	if u.HashedPassword != "" {
-		updateData["hashed_password"] = u.HashedPassword
+		patch["hashed_password"] = u.HashedPassword
 	}
 	if u.IsAdmin {
-		updateData["is_admin"] = u.IsAdmin
+		patch["is_admin"] = u.IsAdmin
 	}
 	if u.IsVerified {
-		updateData["is_verified"] = u.IsVerified
-	}
-	if u.IsDeleted {
-		updateData["is_deleted"] = u.IsDeleted
+		patch["is_verified"] = u.IsVerified
 	}
 	if !u.LastRequest.IsZero() {
-		updateData["last_request"] = u.LastRequest
+		patch["last_request"] = u.LastRequest
 	}
 	if !u.LastLogin.IsZero() {
-		updateData["last_login"] = u.LastLogin
+		patch["last_login"] = u.LastLogin
 	}
 	if u.FailedLoginAttempts != 0 {
-		updateData["failed_login_attempts"] = u.FailedLoginAttempts
+		patch["failed_login_attempts"] = u.FailedLoginAttempts
 	}
 
-	conditions := map[string]any{
-		"id": u.ID,
-	}
-
-	query, args, err := BuildUpdateQuery("users", updateData, conditions)
-	if err != nil {
-		return nil, err
-	}
-
-	rows, err := s.db.Query(query, args...)
-	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
-
-	if rows.Next() {
-		return scanIntoUser(rows)
-	}
-
-	return nil, fmt.Errorf("failed to update user")
+	return patch
 }
 
-func (s *userStoreImpl) GetUserByEmail(email string) (*User, error) {
+func (s *userStoreImpl) GetUserByEmail(ctx context.Context, email string) (*User, error) {
 
 	data := map[string]any{
 		"email": email,
 	}
 
-	query, values, err := BuildSelectQuery("users", data)
+	query, values, err := buildSelectQuery("users", data, includesDeleted(ctx))
 	if err != nil {
 		return nil, err
 	}
 
-	rows, err := s.db.Query(query, values...)
-	if err != nil {
-		return nil, err
-	}
-	if rows.Next() {
-		return scanIntoUser(rows)
+	user, err := queryOne(ctx, s.db.reader(ctx), scanRow[User], query, values...)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, notFound("user", email)
 	}
-	return nil, fmt.Errorf("user %s not found", email)
+	return user, err
 }
 
-func (s *userStoreImpl) GetUserByID(ID uuid.UUID) (*User, error) {
+func (s *userStoreImpl) GetUserByID(ctx context.Context, ID uuid.UUID) (*User, error) {
 
 	data := map[string]any{
 		"id": ID,
 	}
 
-	query, values, err := BuildSelectQuery("users", data)
+	query, values, err := buildSelectQuery("users", data, includesDeleted(ctx))
 	if err != nil {
 		return nil, err
 	}
 
-	rows, err := s.db.Query(query, values...)
-	if err != nil {
-		return nil, err
-	}
-	if rows.Next() {
-		return scanIntoUser(rows)
+	user, err := queryOne(ctx, s.db.reader(ctx), scanRow[User], query, values...)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, notFound("user", ID)
 	}
-	return nil, fmt.Errorf("user %s not found", ID)
+	return user, err
+}
+
+// DeleteUser soft-deletes the user, setting both deleted_at and is_deleted.
+func (s *userStoreImpl) DeleteUser(ctx context.Context, ID uuid.UUID) (*User, error) {
+	return softDelete[User](ctx, s.db, "users", "user", ID, map[string]any{"is_deleted": true})
+}
+
+func (s *userStoreImpl) RestoreUser(ctx context.Context, ID uuid.UUID) (*User, error) {
+	return restore[User](ctx, s.db, "users", "user", ID, map[string]any{"is_deleted": false})
 }
 
 func (u *User) ValidPassword(password string) bool {
@@ -186,56 +184,42 @@ func (u *User) ValidPassword(password string) bool {
 }
 
 type userStoreImpl struct {
-	db *sql.DB
+	db *DB
 }
 
-var NewUserStore = func(db *sql.DB) UserStore {
+var NewUserStore = func(db *DB) UserStore {
 	return &userStoreImpl{
 		db: db,
 	}
 }
 
 type UserStore interface {
-	GetUserByEmail(email string) (*User, error)
-	GetUserByID(ID uuid.UUID) (*User, error)
+	GetUserByEmail(ctx context.Context, email string) (*User, error)
+	GetUserByID(ctx context.Context, ID uuid.UUID) (*User, error)
 
-	CreateUser(user *User) (*User, error)
+	CreateUser(ctx context.Context, user *User) (*User, error)
 	CreateRequest(email, password string) (*User, error)
 
-	UpdateUser(user *User) (*User, error)
+	UpdateUser(ctx context.Context, user *User, expectedUpdatedAt time.Time) (*User, error)
+	PatchUser(ctx context.Context, ID uuid.UUID, patch Patch, expectedUpdatedAt time.Time) (*User, error)
 	UpdateRequest(userName, password string) (*User, error)
+
+	DeleteUser(ctx context.Context, ID uuid.UUID) (*User, error)
+	RestoreUser(ctx context.Context, ID uuid.UUID) (*User, error)
 }
 
 type User struct {
-	ID                  uuid.UUID `json:"id"`
-	CreatedAt           time.Time `json:"created_at"`
-	UpdatedAt           time.Time `json:"updated_at"`
-	UserName            string    `json:"user_name"`
-	Email               string    `json:"email"`
-	HashedPassword      string    `json:"-"`
-	IsAdmin             bool      `json:"-"`
-	IsVerified          bool      `json:"-"`
-	IsDeleted           bool      `json:"-"`
-	LastRequest         time.Time `json:"-"`
-	LastLogin           time.Time `json:"-"`
-	FailedLoginAttempts int       `json:"-"`
-}
-
-func scanIntoUser(rows *sql.Rows) (*User, error) {
-	u := new(User)
-	err := rows.Scan(
-		&u.ID,
-		&u.CreatedAt,
-		&u.UpdatedAt,
-		&u.UserName,
-		&u.Email,
-		&u.HashedPassword,
-		&u.IsAdmin,
-		&u.IsVerified,
-		&u.IsDeleted,
-		&u.LastRequest,
-		&u.LastLogin,
-		&u.FailedLoginAttempts,
-	)
-	return u, err
+	ID                  uuid.UUID  `json:"id" db:"id"`
+	CreatedAt           time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt           time.Time  `json:"updated_at" db:"updated_at"`
+	UserName            string     `json:"user_name" db:"user_name"`
+	Email               string     `json:"email" db:"email"`
+	HashedPassword      string     `json:"-" db:"hashed_password"`
+	IsAdmin             bool       `json:"-" db:"is_admin"`
+	IsVerified          bool       `json:"-" db:"is_verified"`
+	IsDeleted           bool       `json:"-" db:"is_deleted"`
+	LastRequest         time.Time  `json:"-" db:"last_request"`
+	LastLogin           time.Time  `json:"-" db:"last_login"`
+	FailedLoginAttempts int        `json:"-" db:"failed_login_attempts"`
+	DeletedAt           *time.Time `json:"-" db:"deleted_at"`
 }
@@ -2,18 +2,23 @@ package db
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
-	"os"
+	"log/slog"
 
 	_ "github.com/lib/pq"
 )
 
-func CreateDatabase() (*sql.DB, error) {
-	connStr := os.Getenv("DATABASE_URL")
+// CreateDatabase connects to the database at connStr.
+func CreateDatabase(connStr string) (*sql.DB, error) {
 	if connStr == "" {
 		return nil, fmt.Errorf("DATABASE_URL must be set")
 	}
 
+	return open(connStr)
+}
+
+func open(connStr string) (*sql.DB, error) {
 	db, err := sql.Open("postgres", connStr)
 	if err != nil {
 		return nil, err
@@ -27,17 +32,34 @@ func CreateDatabase() (*sql.DB, error) {
 	return db, nil
 }
 
-func Init() (*sql.DB, error) {
-	db, err := CreateDatabase()
+// Init connects to the primary database at primaryURL and to each of the read replicas
+// in replicaURLs. A replica that cannot be reached is left out, so the primary serves its reads.
+func Init(primaryURL string, replicaURLs ...string) (*sql.DB, []*sql.DB, error) {
+	db, err := CreateDatabase(primaryURL)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
-	return db, nil
+
+	replicas := make([]*sql.DB, 0, len(replicaURLs))
+	for i, url := range replicaURLs {
+		replica, err := open(url)
+		if err != nil {
+			slog.Warn("Failed to connect to replica", "replica", i, "error", err)
+			continue
+		}
+		replicas = append(replicas, replica)
+	}
+
+	return db, replicas, nil
 }
 
-func Close(db *sql.DB) error {
-	if db != nil {
-		return db.Close()
+// Close closes every database handle given.
+func Close(dbs ...*sql.DB) error {
+	var errs []error
+	for _, db := range dbs {
+		if db != nil {
+			errs = append(errs, db.Close())
+		}
 	}
-	return nil
+	return errors.Join(errs...)
 }
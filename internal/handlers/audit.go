@@ -0,0 +1,44 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/kevin-griley/api/internal/data"
+)
+
+// @Summary			List audit log entries
+// @Description		List the field-level audit log, oldest first. Every insert, update, delete and purge records its actor, request ID and the changed fields.
+// @Description		Filter by entity with table_name and row_id, and by actor with actor_id. Filters accept column=value or column[op]=value, where op is one of eq, ne, gt, gte, lt, lte or in (comma separated).
+// @Tags			Audit
+// @Security 		ApiKeyAuth
+// @Produce			json
+// @Param			table_name	query	string	false	"Table of the changed row, such as organizations"
+// @Param			row_id		query	string	false	"ID of the changed row"
+// @Param			actor_id	query	string	false	"ID of the user who made the change"
+// @Param			limit		query	int		false	"Page size"
+// @Param			cursor		query	string	false	"Cursor from a previous page"
+// @Param			sort		query	string	false	"Sort column, prefixed with - for descending (created_at)"
+// @Success         200			{object}	data.Page[data.AuditEntry]	"Audit log"
+// @Failure         400			{object} 	ApiError	"Bad Request"
+// @Failure         403			{object} 	ApiError	"Forbidden"
+// @Router			/audit	[get]
+func HandleListAuditLog(w http.ResponseWriter, r *http.Request) *ApiError {
+	ctx := r.Context()
+
+	store, ok := data.GetStore(ctx)
+	if !ok {
+		return &ApiError{Status: http.StatusInternalServerError, Message: "no database store in context"}
+	}
+
+	page, err := GetPageRequest(r, "audit_log")
+	if err != nil {
+		return &ApiError{Status: http.StatusBadRequest, Message: err.Error()}
+	}
+
+	resp, err := store.AuditLog.ListAuditLog(ctx, page)
+	if err != nil {
+		return StoreError(err)
+	}
+
+	return WriteJSON(w, http.StatusOK, resp)
+}
@@ -1,15 +1,22 @@
 package handlers
 
 import (
-	"log"
+	"log/slog"
 	"net/http"
-	"os"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/kevin-griley/api/internal/data"
+	"github.com/kevin-griley/api/internal/metrics"
+	"github.com/kevin-griley/api/internal/middleware"
+	"github.com/prometheus/client_golang/prometheus"
 )
 
+var loginAttempts = metrics.Factory.NewCounterVec(prometheus.CounterOpts{
+	Name: "auth_login_attempts_total",
+	Help: "Number of login attempts by result, success or failure.",
+}, []string{"result"})
+
 type PostAuthRequest struct {
 	Email    string `json:"email"`
 	Password string `json:"password"`
@@ -28,64 +35,83 @@ type PostAuthResponse struct {
 // @Success			200		{object}	PostAuthResponse	"Token Response"
 // @Failure			400		{object} 	ApiError	"Bad Request"
 // @Failure			401		{object} 	ApiError	"Unauthorized"
+// @Failure			429		{object} 	ApiError	"Too Many Requests"
 // @Router			/login	[post]
-func HandlePostLogin(w http.ResponseWriter, r *http.Request) *ApiError {
-	ctx := r.Context()
+func HandlePostLogin(jwtSecret string) ApiFunc {
+	return func(w http.ResponseWriter, r *http.Request) *ApiError {
+		apiErr := postLogin(w, r, jwtSecret)
+		switch {
+		case apiErr == nil:
+			loginAttempts.WithLabelValues("success").Inc()
+		case apiErr.Status == http.StatusUnauthorized:
+			loginAttempts.WithLabelValues("failure").Inc()
+		}
+		return apiErr
+	}
+}
+
+func postLogin(w http.ResponseWriter, r *http.Request, jwtSecret string) *ApiError {
+	// The lockout is decided by the failed login count, which a lagging replica would
+	// understate, so every read here goes to the primary.
+	ctx := data.WithPrimary(r.Context())
 
 	store, ok := data.GetStore(ctx)
 	if !ok {
-		return &ApiError{http.StatusInternalServerError, "no database store in context"}
+		return &ApiError{Status: http.StatusInternalServerError, Message: "no database store in context"}
 	}
 
 	postReq := new(PostAuthRequest)
 	if err := DecodeJSONRequest(r, postReq, 1<<20); err != nil {
-		return &ApiError{http.StatusBadRequest, err.Error()}
+		return &ApiError{Status: http.StatusBadRequest, Message: err.Error()}
 	}
 
 	if postReq.Email == "" || postReq.Password == "" {
-		return &ApiError{http.StatusBadRequest, "email and password are required"}
+		return &ApiError{Status: http.StatusBadRequest, Message: "email and password are required"}
 	}
 
-	user, err := store.User.GetUserByEmail(postReq.Email)
+	user, err := store.User.GetUserByEmail(ctx, postReq.Email)
 	if err != nil {
-		return &ApiError{http.StatusUnauthorized, "invalid user or password"}
+		return &ApiError{Status: http.StatusUnauthorized, Message: "invalid user or password"}
 	}
 
 	if user.IsDeleted {
-		return &ApiError{http.StatusUnauthorized, "invalid user or password"}
+		return &ApiError{Status: http.StatusUnauthorized, Message: "invalid user or password"}
 	}
 
 	if user.FailedLoginAttempts >= 10 && time.Since(user.UpdatedAt).Minutes() < 30 {
-		return &ApiError{http.StatusUnauthorized, "account locked due to too many failed login attempts please try again later"}
+		return &ApiError{Status: http.StatusUnauthorized, Message: "account locked due to too many failed login attempts please try again later"}
 	}
 
 	if !user.ValidPassword(postReq.Password) {
-		user.FailedLoginAttempts++
-		_, err := store.User.UpdateUser(user)
+		_, err := store.User.PatchUser(ctx, user.ID, data.Patch{
+			"failed_login_attempts": user.FailedLoginAttempts + 1,
+		}, time.Time{})
 		if err != nil {
-			log.Printf("failed to update user: %v", err)
-			return &ApiError{http.StatusInternalServerError, err.Error()}
+			slog.Error("HandlePostLogin", "PatchUser", err)
+			return StoreError(err)
 		}
-		return &ApiError{http.StatusUnauthorized, "invalid user or password"}
+		return &ApiError{Status: http.StatusUnauthorized, Message: "invalid user or password"}
 	}
 
-	user.FailedLoginAttempts = 1
-	user.LastLogin = time.Now().UTC()
-	user, err = store.User.UpdateUser(user)
+	user, err = store.User.PatchUser(ctx, user.ID, data.Patch{
+		"failed_login_attempts": 0,
+		"last_login":            time.Now().UTC(),
+	}, time.Time{})
 
 	if err != nil {
-		return &ApiError{http.StatusInternalServerError, err.Error()}
+		return StoreError(err)
 	}
 
-	tokenString, err := CreateJWT(user)
+	tokenString, err := CreateJWT(user, jwtSecret)
 	if err != nil {
-		return &ApiError{http.StatusInternalServerError, err.Error()}
+		return &ApiError{Status: http.StatusInternalServerError, Message: err.Error()}
 	}
 
 	return WriteJSON(w, http.StatusOK, PostAuthResponse{Token: tokenString})
 }
 
-func CreateJWT(user *data.User) (string, error) {
+// CreateJWT issues a day-long token for user, signed with jwtSecret.
+func CreateJWT(user *data.User, jwtSecret string) (string, error) {
 	claims := &jwt.RegisteredClaims{
 		ExpiresAt: jwt.NewNumericDate(time.Now().Add(24 * time.Hour)),
 		IssuedAt:  jwt.NewNumericDate(time.Now()),
@@ -94,8 +120,6 @@ func CreateJWT(user *data.User) (string, error) {
 		Subject:   user.ID.String(),
 	}
 
-	secret := os.Getenv("JWT_SECRET")
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString([]byte(secret))
+	return middleware.SignJWT(claims, jwtSecret)
 
 }
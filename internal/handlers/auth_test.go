@@ -2,26 +2,32 @@ package handlers
 
 import (
 	"bytes"
+	"database/sql"
+	"database/sql/driver"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
 	"github.com/kevin-griley/api/internal/data"
-	"github.com/kevin-griley/api/internal/db"
 	"github.com/kevin-griley/api/internal/middleware"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"golang.org/x/crypto/bcrypt"
 )
 
 func TestLogin(t *testing.T) {
 
-	dbConn, err := db.Init()
+	store, err := NewTestStore()
 	if err != nil {
-		t.Fatalf("Failed to connect to database: %v", err)
+		t.Fatalf("Failed to create test store: %v", err)
 	}
-	defer db.Close(dbConn)
-
-	store := data.NewStore(dbConn)
 
 	validEmail := "Kevin"
 	validPassword := "Kevin"
@@ -31,6 +37,7 @@ func TestLogin(t *testing.T) {
 		name           string
 		loginPayload   PostAuthRequest
 		expectedStatus int
+		expectedResult string
 	}{
 		{
 			name: "Valid Login",
@@ -39,6 +46,7 @@ func TestLogin(t *testing.T) {
 				Password: validPassword,
 			},
 			expectedStatus: http.StatusOK,
+			expectedResult: "success",
 		},
 		{
 			name: "Invalid Password",
@@ -47,6 +55,7 @@ func TestLogin(t *testing.T) {
 				Password: wrongPassword,
 			},
 			expectedStatus: http.StatusUnauthorized,
+			expectedResult: "failure",
 		},
 		{
 			name: "Empty Credentials",
@@ -59,7 +68,7 @@ func TestLogin(t *testing.T) {
 	}
 
 	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if apiErr := HandlePostLogin(w, r); apiErr != nil {
+		if apiErr := HandlePostLogin(testJWTSecret)(w, r); apiErr != nil {
 			http.Error(w, apiErr.Message, apiErr.Status)
 		}
 	})
@@ -77,9 +86,24 @@ func TestLogin(t *testing.T) {
 			req := httptest.NewRequest(http.MethodPost, "/login", bytes.NewBuffer(reqBody))
 			req.Header.Set("Content-Type", "application/json")
 
+			successes, failures := testutil.ToFloat64(loginAttempts.WithLabelValues("success")), testutil.ToFloat64(loginAttempts.WithLabelValues("failure"))
+
 			rr := httptest.NewRecorder()
 			finalHandler.ServeHTTP(rr, req)
 
+			switch tc.expectedResult {
+			case "success":
+				successes++
+			case "failure":
+				failures++
+			}
+			if got := testutil.ToFloat64(loginAttempts.WithLabelValues("success")); got != successes {
+				t.Errorf("Expected %v successful logins, got %v", successes, got)
+			}
+			if got := testutil.ToFloat64(loginAttempts.WithLabelValues("failure")); got != failures {
+				t.Errorf("Expected %v failed logins, got %v", failures, got)
+			}
+
 			if rr.Code != tc.expectedStatus {
 				t.Errorf("Expected status %d, got %d. Response body: %s",
 					tc.expectedStatus, rr.Code, rr.Body.String())
@@ -94,10 +118,13 @@ func TestLogin(t *testing.T) {
 					t.Errorf("Expected non-empty JWT token in response")
 				}
 
-				token, err := middleware.ValidateJWT(resp.Token)
+				token, err := middleware.ValidateJWT(resp.Token, testJWTSecret)
 				if err != nil {
 					t.Fatalf("Failed to validate JWT token: %v", err)
 				}
+				if _, err := middleware.ValidateJWT(resp.Token, "other-secret"); err == nil {
+					t.Errorf("Expected a token signed with another secret to be rejected")
+				}
 
 				_, ok := token.Claims.(jwt.MapClaims)
 				if !ok {
@@ -108,3 +135,80 @@ func TestLogin(t *testing.T) {
 		})
 	}
 }
+
+// userDriver is a driver whose queries all return the same users row, standing in for one
+// database's copy of the row.
+type userDriver struct {
+	failedLoginAttempts int64
+	hashedPassword      string
+}
+
+var userColumns = []string{"id", "email", "hashed_password", "failed_login_attempts", "updated_at"}
+
+func (d *userDriver) Open(name string) (driver.Conn, error)     { return d, nil }
+func (d *userDriver) Prepare(query string) (driver.Stmt, error) { return userStmt{d}, nil }
+func (d *userDriver) Close() error                              { return nil }
+func (d *userDriver) Begin() (driver.Tx, error)                 { return nil, errors.New("not implemented") }
+
+type userStmt struct{ d *userDriver }
+
+func (s userStmt) Close() error  { return nil }
+func (s userStmt) NumInput() int { return -1 }
+func (s userStmt) Exec(args []driver.Value) (driver.Result, error) {
+	return driver.RowsAffected(1), nil
+}
+func (s userStmt) Query(args []driver.Value) (driver.Rows, error) { return &userRows{d: s.d}, nil }
+
+type userRows struct {
+	d    *userDriver
+	done bool
+}
+
+func (r *userRows) Columns() []string { return userColumns }
+func (r *userRows) Close() error      { return nil }
+func (r *userRows) Next(dest []driver.Value) error {
+	if r.done {
+		return io.EOF
+	}
+	r.done = true
+	copy(dest, []driver.Value{uuid.NewString(), "Kevin", r.d.hashedPassword, r.d.failedLoginAttempts, time.Now().UTC()})
+	return nil
+}
+
+func TestLoginReadsPrimary(t *testing.T) {
+	hash, err := bcrypt.GenerateFromPassword([]byte("Kevin"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatalf("Failed to hash password: %v", err)
+	}
+
+	open := func(d *userDriver) *sql.DB {
+		name := fmt.Sprintf("user-%p", d)
+		sql.Register(name, d)
+		pool, err := sql.Open(name, "")
+		if err != nil {
+			t.Fatalf("Failed to open pool: %v", err)
+		}
+		t.Cleanup(func() { pool.Close() })
+		return pool
+	}
+
+	// The account is locked on the primary, but the replica has not seen the failed logins.
+	primary := open(&userDriver{failedLoginAttempts: 10, hashedPassword: string(hash)})
+	replica := open(&userDriver{failedLoginAttempts: 0, hashedPassword: string(hash)})
+	store := data.NewStore(primary, data.WithReplicas(replica))
+
+	handler := middleware.Chain(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if apiErr := HandlePostLogin(testJWTSecret)(w, r); apiErr != nil {
+			http.Error(w, apiErr.Message, apiErr.Status)
+		}
+	}), middleware.StoreMiddleware(store))
+
+	req := httptest.NewRequest(http.MethodPost, "/login", strings.NewReader(`{"email":"Kevin","password":"Kevin"}`))
+	req.Header.Set("Content-Type", "application/json")
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusUnauthorized || !strings.Contains(rr.Body.String(), "locked") {
+		t.Errorf("Expected the lockout on the primary to apply, got %d: %s", rr.Code, rr.Body.String())
+	}
+}
@@ -1,14 +1,19 @@
 package handlers
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log/slog"
 	"net/http"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/google/uuid"
+	"github.com/kevin-griley/api/internal/data"
 	"github.com/kevin-griley/api/internal/middleware"
 )
 
@@ -26,9 +31,87 @@ func GetPathID(r *http.Request) (uuid.UUID, error) {
 	return id, nil
 }
 
+// GetPageRequest reads the pagination, filter and sort query parameters shared by every list endpoint.
+// The data layer applies the default page size and caps the limit.
+func GetPageRequest(r *http.Request, tableName string) (data.PageRequest, error) {
+	return data.ParsePageRequest(tableName, r.URL.Query())
+}
+
+// GetIncludeDeleted returns the request context, marked to include soft-deleted rows when the
+// include_deleted query parameter is true. Only admins may ask for deleted rows.
+func GetIncludeDeleted(r *http.Request) (context.Context, *ApiError) {
+	ctx := r.Context()
+
+	includeDeleted, err := strconv.ParseBool(r.URL.Query().Get("include_deleted"))
+	if err != nil || !includeDeleted {
+		return ctx, nil
+	}
+
+	if !middleware.IsAdmin(ctx) {
+		return ctx, &ApiError{Status: http.StatusForbidden, Message: "include_deleted requires an admin"}
+	}
+
+	return data.WithDeleted(ctx), nil
+}
+
+// RequireMembership returns an error unless the authenticated user is an active member of
+// organizationID holding permission. Admins may act on any organization. Users outside the
+// organization get 404, so that rows of other organizations cannot be told apart from
+// missing ones.
+func RequireMembership(ctx context.Context, store *data.Store, organizationID uuid.UUID, permission data.Permission) *ApiError {
+	userID, ok := middleware.GetUserID(ctx)
+	if !ok {
+		return &ApiError{Status: http.StatusUnauthorized, Message: "authentication required"}
+	}
+	if middleware.IsAdmin(ctx) {
+		return nil
+	}
+
+	assoc, err := store.UserAssociation.GetUserAssociation(ctx, userID, organizationID)
+	if errors.Is(err, data.ErrNotFound) {
+		return &ApiError{Status: http.StatusNotFound, Message: "not found", Err: err}
+	}
+	if err != nil {
+		return StoreError(err)
+	}
+	if assoc.Status != data.AssociationActive || !assoc.Permissions.Has(permission) {
+		return &ApiError{Status: http.StatusForbidden, Message: fmt.Sprintf("%s permission required", permission)}
+	}
+	return nil
+}
+
+// ETag derives an entity tag from a row's updated_at, which changes on every update.
+func ETag(updatedAt time.Time) string {
+	return `"` + strconv.FormatInt(updatedAt.UnixMicro(), 36) + `"`
+}
+
+// GetIfMatch returns the updated_at named by the If-Match header, to be passed to an update
+// as its expected version. It returns the zero time when the header is absent or "*",
+// in which case the update is unconditional.
+func GetIfMatch(r *http.Request) (time.Time, *ApiError) {
+	ifMatch := strings.TrimSpace(r.Header.Get("If-Match"))
+	if ifMatch == "" || ifMatch == "*" {
+		return time.Time{}, nil
+	}
+
+	tag, ok := strings.CutPrefix(ifMatch, `"`)
+	if ok {
+		tag, ok = strings.CutSuffix(tag, `"`)
+	}
+	micros, err := strconv.ParseInt(tag, 36, 64)
+	if !ok || err != nil {
+		return time.Time{}, &ApiError{Status: http.StatusPreconditionFailed, Message: "If-Match does not match the current version"}
+	}
+
+	return time.UnixMicro(micros).UTC(), nil
+}
+
 type ApiError struct {
 	Status  int    `json:"status"`
 	Message string `json:"error"`
+
+	// Err is the underlying error. It is logged but never written to the client.
+	Err error `json:"-"`
 }
 
 func (e *ApiError) Error() string {
@@ -39,10 +122,30 @@ func Error(status int, message string) *ApiError {
 	return &ApiError{Status: status, Message: message}
 }
 
+// StoreError returns an ApiError for an error returned by a data store, with a status and
+// message chosen from the kind of error so that SQL never reaches the client. The error itself
+// is kept in Err, and logged by HandleApiError.
+func StoreError(err error) *ApiError {
+	var constraintErr *data.ConstraintError
+	switch {
+	case errors.Is(err, data.ErrNotFound):
+		return &ApiError{Status: http.StatusNotFound, Message: err.Error(), Err: err}
+	case errors.Is(err, data.ErrPreconditionFailed):
+		return &ApiError{Status: http.StatusPreconditionFailed, Message: err.Error(), Err: err}
+	case errors.Is(err, data.ErrInvalidTransition):
+		return &ApiError{Status: http.StatusConflict, Message: err.Error(), Err: err}
+	case errors.As(err, &constraintErr) && errors.Is(err, data.ErrConflict):
+		return &ApiError{Status: http.StatusConflict, Message: "conflict: " + constraintErr.Constraint, Err: err}
+	case errors.As(err, &constraintErr) && errors.Is(err, data.ErrInvalidReference):
+		return &ApiError{Status: http.StatusUnprocessableEntity, Message: "invalid reference: " + constraintErr.Constraint, Err: err}
+	}
+	return &ApiError{Status: http.StatusInternalServerError, Message: http.StatusText(http.StatusInternalServerError), Err: err}
+}
+
 func HTTPErrorHandler(err error, w http.ResponseWriter) {
 	apiErr, ok := err.(*ApiError)
 	if !ok {
-		apiErr = Error(http.StatusInternalServerError, err.Error())
+		apiErr = StoreError(err)
 	}
 	WriteJSON(w, apiErr.Status, apiErr)
 }
@@ -52,7 +155,7 @@ func WriteJSON(w http.ResponseWriter, status int, v any) *ApiError {
 	w.WriteHeader(status)
 	err := json.NewEncoder(w).Encode(v)
 	if err != nil {
-		return &ApiError{http.StatusInternalServerError, err.Error()}
+		return &ApiError{Status: http.StatusInternalServerError, Message: err.Error()}
 	}
 	return nil
 }
@@ -72,6 +175,7 @@ func HandleApiError(f ApiFunc) http.HandlerFunc {
 				"status", err.Status,
 				"error", err.Message,
 				"requestID", reqID,
+				"cause", err.Err,
 			)
 			WriteJSON(w, err.Status, err)
 		}
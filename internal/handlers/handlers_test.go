@@ -2,6 +2,7 @@ package handlers
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
@@ -9,19 +10,38 @@ import (
 
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/kevin-griley/api/internal/data"
-	"github.com/kevin-griley/api/internal/db"
 	"github.com/kevin-griley/api/internal/middleware"
 )
 
-func BaseLine(handlerFunc ApiFunc) (http.HandlerFunc, *jwt.Token, error) {
+// testJWTSecret signs the tokens the handler tests log in with.
+const testJWTSecret = "test-secret"
+
+// NewTestStore returns an in-memory store seeded with the "Kevin" user the handler tests log in as.
+func NewTestStore() (*data.Store, error) {
+	store := data.NewMemoryStore()
 
-	dbConn, err := db.Init()
+	user, err := store.User.CreateRequest("Kevin", "Kevin")
 	if err != nil {
-		return nil, nil, err
+		return nil, err
 	}
 
-	store := data.NewStore(dbConn)
+	if _, err := store.User.CreateUser(context.Background(), user); err != nil {
+		return nil, err
+	}
+
+	return store, nil
+}
+
+func BaseLine(handlerFunc ApiFunc) (http.HandlerFunc, *jwt.Token, error) {
+	store, err := NewTestStore()
+	if err != nil {
+		return nil, nil, err
+	}
+	return BaseLineWithStore(store, handlerFunc)
+}
 
+// BaseLineWithStore is BaseLine against a store from NewTestStore that the test has seeded.
+func BaseLineWithStore(store *data.Store, handlerFunc ApiFunc) (http.HandlerFunc, *jwt.Token, error) {
 	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		if apiErr := handlerFunc(w, r); apiErr != nil {
 			http.Error(w, apiErr.Message, apiErr.Status)
@@ -39,7 +59,7 @@ func BaseLine(handlerFunc ApiFunc) (http.HandlerFunc, *jwt.Token, error) {
 	}
 
 	loginHandler := middleware.Chain(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if apiErr := HandlePostLogin(w, r); apiErr != nil {
+		if apiErr := HandlePostLogin(testJWTSecret)(w, r); apiErr != nil {
 			http.Error(w, apiErr.Message, apiErr.Status)
 		}
 	}), middleware.StoreMiddleware(store))
@@ -55,14 +75,14 @@ func BaseLine(handlerFunc ApiFunc) (http.HandlerFunc, *jwt.Token, error) {
 		return nil, nil, fmt.Errorf("failed to unmarshal response: %v", err)
 	}
 
-	token, err := middleware.ValidateJWT(resp.Token)
+	token, err := middleware.ValidateJWT(resp.Token, testJWTSecret)
 	if err != nil {
 		return nil, token, fmt.Errorf("failed to validate JWT: %v", err)
 	}
 
 	handler = middleware.Chain(
 		handler,
-		middleware.JwtAuthMiddleware,
+		middleware.JwtAuthMiddleware(testJWTSecret),
 		middleware.StoreMiddleware(store),
 	)
 
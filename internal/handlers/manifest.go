@@ -0,0 +1,212 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/kevin-griley/api/internal/data"
+)
+
+// @Summary			Get manifest by ID
+// @Description		Get manifest by ID
+// @Tags			Manifest
+// @Security 		ApiKeyAuth
+// @Produce			json
+// @Param			id	path	string	true	"Manifest ID"
+// @Param			include_deleted	query	bool	false	"Include a deleted manifest (admin only)"
+// @Success         200			{object}	data.DeliveryManifest	"Manifest"
+// @Failure         400			{object} 	ApiError	"Bad Request"
+// @Failure         403			{object} 	ApiError	"Forbidden"
+// @Failure         404			{object} 	ApiError	"Not Found"
+// @Router			/manifest/{id}	[get]
+func HandleGetManifestByID(w http.ResponseWriter, r *http.Request) *ApiError {
+	ctx, apiErr := GetIncludeDeleted(r)
+	if apiErr != nil {
+		return apiErr
+	}
+
+	store, ok := data.GetStore(ctx)
+	if !ok {
+		return &ApiError{Status: http.StatusInternalServerError, Message: "no database store in context"}
+	}
+
+	id, err := GetPathID(r)
+	if err != nil {
+		return &ApiError{Status: http.StatusBadRequest, Message: err.Error()}
+	}
+
+	resp, err := store.DeliveryManifest.GetDeliveryManifestByID(ctx, id)
+	if err != nil {
+		return StoreError(err)
+	}
+
+	w.Header().Set("ETag", ETag(resp.UpdatedAt))
+	return WriteJSON(w, http.StatusOK, resp)
+}
+
+// @Summary			List manifests
+// @Description		List manifests ordered by creation time, or by the sort parameter. Follow the next and prev cursors to page through the results.
+// @Description		Filter with column=value or column[op]=value, where op is one of eq, ne, gt, gte, lt, lte or in (comma separated).
+// @Tags			Manifest
+// @Security 		ApiKeyAuth
+// @Produce			json
+// @Param			limit	query	int		false	"Page size"
+// @Param			cursor	query	string	false	"Cursor from a previous page"
+// @Param			sort	query	string	false	"Sort column, prefixed with - for descending (created_at, updated_at, manifest_date)"
+// @Param			include_deleted	query	bool	false	"Include deleted manifests (admin only)"
+// @Success         200			{object}	data.Page[data.DeliveryManifest]	"Manifests"
+// @Failure         400			{object} 	ApiError	"Bad Request"
+// @Failure         403			{object} 	ApiError	"Forbidden"
+// @Router			/manifest	[get]
+func HandleListManifests(w http.ResponseWriter, r *http.Request) *ApiError {
+	ctx, apiErr := GetIncludeDeleted(r)
+	if apiErr != nil {
+		return apiErr
+	}
+
+	store, ok := data.GetStore(ctx)
+	if !ok {
+		return &ApiError{Status: http.StatusInternalServerError, Message: "no database store in context"}
+	}
+
+	page, err := GetPageRequest(r, "delivery_manifests")
+	if err != nil {
+		return &ApiError{Status: http.StatusBadRequest, Message: err.Error()}
+	}
+
+	resp, err := store.DeliveryManifest.ListDeliveryManifests(ctx, page)
+	if err != nil {
+		return StoreError(err)
+	}
+
+	return WriteJSON(w, http.StatusOK, resp)
+}
+
+// @Summary			Delete manifest by ID
+// @Description		Soft delete a manifest. It is hidden from reads until restored, and purged after the retention window. Requires manifest.write in the owning organization, or an admin.
+// @Tags			Manifest
+// @Security 		ApiKeyAuth
+// @Produce			json
+// @Param			id	path	string	true	"Manifest ID"
+// @Success         200			{object}	data.DeliveryManifest	"Manifest"
+// @Failure         400			{object} 	ApiError	"Bad Request"
+// @Failure         403			{object} 	ApiError	"Forbidden"
+// @Failure         404			{object} 	ApiError	"Not Found"
+// @Router			/manifest/{id}	[delete]
+func HandleDeleteManifestByID(w http.ResponseWriter, r *http.Request) *ApiError {
+	ctx := r.Context()
+
+	store, ok := data.GetStore(ctx)
+	if !ok {
+		return &ApiError{Status: http.StatusInternalServerError, Message: "no database store in context"}
+	}
+
+	id, err := GetPathID(r)
+	if err != nil {
+		return &ApiError{Status: http.StatusBadRequest, Message: err.Error()}
+	}
+
+	manifest, err := store.DeliveryManifest.GetDeliveryManifestByID(ctx, id)
+	if err != nil {
+		return StoreError(err)
+	}
+	if apiErr := RequireMembership(ctx, store, manifest.OrganizationID, data.PermissionManifestWrite); apiErr != nil {
+		return apiErr
+	}
+
+	resp, err := store.DeliveryManifest.DeleteDeliveryManifest(ctx, id)
+	if err != nil {
+		return StoreError(err)
+	}
+
+	return WriteJSON(w, http.StatusOK, resp)
+}
+
+// @Summary			Restore manifest by ID
+// @Description		Restore a soft deleted manifest (admin only)
+// @Tags			Manifest
+// @Security 		ApiKeyAuth
+// @Produce			json
+// @Param			id	path	string	true	"Manifest ID"
+// @Success         200			{object}	data.DeliveryManifest	"Manifest"
+// @Failure         400			{object} 	ApiError	"Bad Request"
+// @Failure         404			{object} 	ApiError	"Not Found"
+// @Router			/manifest/{id}/restore	[post]
+func HandleRestoreManifestByID(w http.ResponseWriter, r *http.Request) *ApiError {
+	ctx := r.Context()
+
+	store, ok := data.GetStore(ctx)
+	if !ok {
+		return &ApiError{Status: http.StatusInternalServerError, Message: "no database store in context"}
+	}
+
+	id, err := GetPathID(r)
+	if err != nil {
+		return &ApiError{Status: http.StatusBadRequest, Message: err.Error()}
+	}
+
+	resp, err := store.DeliveryManifest.RestoreDeliveryManifest(ctx, id)
+	if err != nil {
+		return StoreError(err)
+	}
+
+	w.Header().Set("ETag", ETag(resp.UpdatedAt))
+	return WriteJSON(w, http.StatusOK, resp)
+}
+
+type PatchManifestStatusRequest struct {
+	ManifestStatus data.ManifestStatus `json:"manifest_status"`
+}
+
+// @Summary			Update manifest status
+// @Description		Move a manifest from draft to submitted, or from submitted to accepted or rejected. Each change publishes a manifest event such as manifest.accepted.
+// @Tags			Manifest
+// @Security 		ApiKeyAuth
+// @Accept			application/merge-patch+json
+// @Accept			json
+// @Produce			json
+// @Param			id	path	string	true	"Manifest ID"
+// @Param			body	body		PatchManifestStatusRequest	true	"Patch Manifest Status Request"
+// @Param			Idempotency-Key	header	string	false	"Replays the first response to retries with the same key"
+// @Success         200			{object}	data.DeliveryManifest	"Manifest"
+// @Failure         400			{object} 	ApiError	"Bad Request"
+// @Failure         404			{object} 	ApiError	"Not Found"
+// @Failure         409			{object} 	ApiError	"Conflict"
+// @Failure         422			{object} 	ApiError	"Idempotency-Key Reused"
+// @Router			/manifest/{id}/status	[patch]
+func HandlePatchManifestStatus(w http.ResponseWriter, r *http.Request) *ApiError {
+	ctx := r.Context()
+
+	store, ok := data.GetStore(ctx)
+	if !ok {
+		return &ApiError{Status: http.StatusInternalServerError, Message: "no database store in context"}
+	}
+
+	id, err := GetPathID(r)
+	if err != nil {
+		return &ApiError{Status: http.StatusBadRequest, Message: err.Error()}
+	}
+
+	mergePatch, apiErr := DecodeMergePatch(r, 1<<20)
+	if apiErr != nil {
+		return apiErr
+	}
+
+	patch, err := mergePatch.Apply(map[string]PatchField{
+		"manifest_status": {Column: "manifest_status", Decode: DecodeAs[data.ManifestStatus]()},
+	})
+	if err != nil {
+		return &ApiError{Status: http.StatusBadRequest, Message: err.Error()}
+	}
+	status, ok := patch["manifest_status"].(data.ManifestStatus)
+	if !ok {
+		return &ApiError{Status: http.StatusBadRequest, Message: "manifest_status is required"}
+	}
+
+	resp, err := store.DeliveryManifest.UpdateManifestStatus(ctx, id, status)
+	if err != nil {
+		return StoreError(err)
+	}
+
+	w.Header().Set("ETag", ETag(resp.UpdatedAt))
+	return WriteJSON(w, http.StatusOK, resp)
+}
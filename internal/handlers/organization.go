@@ -4,6 +4,7 @@ import (
 	"net/http"
 
 	"github.com/kevin-griley/api/internal/data"
+	"github.com/kevin-griley/api/internal/middleware"
 )
 
 type PostOrganizationRequest struct {
@@ -14,126 +15,295 @@ type PostOrganizationRequest struct {
 }
 
 // @Summary			Create a new organization
-// @Description		Create a new organization
+// @Description		Create a new organization and make the caller an active member with every permission
 // @Tags			Organization
+// @Security 		ApiKeyAuth
 // @Accept			json
 // @Produce			json
 // @Param			body	body		PostOrganizationRequest	true	"Create Organization Request"
+// @Param			Idempotency-Key	header	string	false	"Replays the first response to retries with the same key"
 // @Success         200		{object}	data.Organization	"Organization"
 // @Failure         400		{object} 	ApiError	"Bad Request"
+// @Failure         409		{object} 	ApiError	"Conflict"
+// @Failure         422		{object} 	ApiError	"Idempotency-Key Reused"
 // @Router			/organization	[post]
 func HandlePostOrganization(w http.ResponseWriter, r *http.Request) *ApiError {
 	ctx := r.Context()
 
 	store, ok := data.GetStore(ctx)
 	if !ok {
-		return &ApiError{http.StatusInternalServerError, "no database store in context"}
+		return &ApiError{Status: http.StatusInternalServerError, Message: "no database store in context"}
 	}
 
 	postReq := new(PostOrganizationRequest)
 	if err := DecodeJSONRequest(r, postReq, 1<<20); err != nil {
-		return &ApiError{http.StatusBadRequest, err.Error()}
+		return &ApiError{Status: http.StatusBadRequest, Message: err.Error()}
+	}
+
+	userID, ok := middleware.GetUserID(ctx)
+	if !ok {
+		return &ApiError{Status: http.StatusBadRequest, Message: "Invalid user id"}
 	}
 
 	org, err := store.Organization.CreateRequest(postReq.Name, postReq.Address, postReq.ContactInfo, postReq.OrganizationType)
 	if err != nil {
-		return &ApiError{http.StatusBadRequest, err.Error()}
+		return &ApiError{Status: http.StatusBadRequest, Message: err.Error()}
 	}
 
-	resp, err := store.Organization.CreateOrganization(org)
+	var resp *data.Organization
+	err = store.WithTx(ctx, func(tx *data.Store) error {
+		created, err := tx.Organization.CreateOrganization(ctx, org)
+		if err != nil {
+			return err
+		}
+
+		assoc, err := tx.UserAssociation.CreateRequest(userID, created.ID, data.AssociationActive, data.AllPermissions)
+		if err != nil {
+			return err
+		}
+
+		if _, err := tx.UserAssociation.CreateUserAssociation(ctx, assoc); err != nil {
+			return err
+		}
+
+		resp = created
+		return nil
+	})
 	if err != nil {
-		return &ApiError{http.StatusInternalServerError, err.Error()}
+		return StoreError(err)
 	}
 
 	return WriteJSON(w, http.StatusOK, resp)
 }
 
 // @Summary			Get organization by ID
-// @Description		Get organization by ID
+// @Description		Get organization by ID. Requires organization.read in the organization, or an admin.
 // @Tags			Organization
 // @Security 		ApiKeyAuth
 // @Accept			json
 // @Produce			json
 // @Param			id	path	string	true	"Organization ID"
+// @Param			include_deleted	query	bool	false	"Include a deleted organization (admin only)"
 // @Success         200			{object}	data.Organization	"Organization"
 // @Failure         400			{object} 	ApiError	"Bad Request"
+// @Failure         403			{object} 	ApiError	"Forbidden"
+// @Failure         404			{object} 	ApiError	"Not Found"
 // @Router			/organization/{id}	[get]
 func HandleGetOrganizationByID(w http.ResponseWriter, r *http.Request) *ApiError {
-	ctx := r.Context()
+	ctx, apiErr := GetIncludeDeleted(r)
+	if apiErr != nil {
+		return apiErr
+	}
 
 	store, ok := data.GetStore(ctx)
 	if !ok {
-		return &ApiError{http.StatusInternalServerError, "no database store in context"}
+		return &ApiError{Status: http.StatusInternalServerError, Message: "no database store in context"}
 	}
 
 	orgId, err := GetPathID(r)
 	if err != nil {
-		return &ApiError{http.StatusBadRequest, err.Error()}
+		return &ApiError{Status: http.StatusBadRequest, Message: err.Error()}
+	}
+
+	if apiErr := RequireMembership(ctx, store, orgId, data.PermissionOrganizationRead); apiErr != nil {
+		return apiErr
 	}
 
-	org, err := store.Organization.GetOrganizationByID(orgId)
+	org, err := store.Organization.GetOrganizationByID(ctx, orgId)
 	if err != nil {
-		return &ApiError{http.StatusInternalServerError, err.Error()}
+		return StoreError(err)
 	}
 
+	w.Header().Set("ETag", ETag(org.UpdatedAt))
 	return WriteJSON(w, http.StatusOK, org)
 }
 
+// @Summary			List organizations
+// @Description		List the organizations the caller is an active member of, or every organization for an admin, ordered by creation time or by the sort parameter. Follow the next and prev cursors to page through the results.
+// @Description		Filter with column=value or column[op]=value, where op is one of eq, ne, gt, gte, lt, lte or in (comma separated).
+// @Tags			Organization
+// @Security 		ApiKeyAuth
+// @Accept			json
+// @Produce			json
+// @Param			limit	query	int		false	"Page size"
+// @Param			cursor	query	string	false	"Cursor from a previous page"
+// @Param			sort	query	string	false	"Sort column, prefixed with - for descending (created_at, updated_at, name)"
+// @Param			include_deleted	query	bool	false	"Include deleted organizations (admin only)"
+// @Success         200			{object}	data.Page[data.Organization]	"Organizations"
+// @Failure         400			{object} 	ApiError	"Bad Request"
+// @Failure         403			{object} 	ApiError	"Forbidden"
+// @Router			/organization	[get]
+func HandleListOrganizations(w http.ResponseWriter, r *http.Request) *ApiError {
+	ctx, apiErr := GetIncludeDeleted(r)
+	if apiErr != nil {
+		return apiErr
+	}
+
+	store, ok := data.GetStore(ctx)
+	if !ok {
+		return &ApiError{Status: http.StatusInternalServerError, Message: "no database store in context"}
+	}
+
+	page, err := GetPageRequest(r, "organizations")
+	if err != nil {
+		return &ApiError{Status: http.StatusBadRequest, Message: err.Error()}
+	}
+
+	var resp *data.Page[data.Organization]
+	if middleware.IsAdmin(ctx) {
+		resp, err = store.Organization.ListOrganizations(ctx, page)
+	} else {
+		userID, _ := middleware.GetUserID(ctx)
+		resp, err = store.Organization.ListOrganizationsForUser(ctx, userID, page)
+	}
+	if err != nil {
+		return StoreError(err)
+	}
+
+	return WriteJSON(w, http.StatusOK, resp)
+}
+
+// PatchOrganizationRequest documents the merge patch accepted by PATCH /organization/{id}.
+// Omitted fields are left unchanged. Null clears address, contact_info and unique_url.
 type PatchOrganizationRequest struct {
-	name             string
-	uniqueURL        string
-	address          string
-	contactInfo      string
-	organizationType data.OrganizationType
+	Name             string                `json:"name,omitempty"`
+	UniqueURL        string                `json:"unique_url,omitempty"`
+	Address          string                `json:"address,omitempty"`
+	ContactInfo      string                `json:"contact_info,omitempty"`
+	OrganizationType data.OrganizationType `json:"organization_type,omitempty"`
+}
+
+var organizationPatchFields = map[string]PatchField{
+	"name":              {Column: "name", Decode: DecodeAs[string]()},
+	"unique_url":        {Column: "unique_url", Nullable: true, Decode: DecodeAs[string]()},
+	"address":           {Column: "address", Nullable: true, Null: "", Decode: DecodeAs[string]()},
+	"contact_info":      {Column: "contact_info", Nullable: true, Null: "", Decode: DecodeAs[string]()},
+	"organization_type": {Column: "organization_type", Decode: DecodeAs[data.OrganizationType]()},
 }
 
 // @Summary			Patch organization by ID
-// @Description		Patch organization by ID
+// @Description		Apply a JSON merge patch (RFC 7396) to an organization. Omitted fields are left unchanged, and null clears a field. Requires organization.write in the organization, or an admin.
 // @Tags			Organization
 // @Security 		ApiKeyAuth
+// @Accept			application/merge-patch+json
 // @Accept			json
 // @Produce			json
 // @Param			id	path	string	true	"Organization ID"
 // @Param			body	body		PatchOrganizationRequest	true	"Patch Organization Request"
+// @Param			If-Match	header	string	false	"ETag from a previous read; the update fails if the organization changed since"
 // @Success         200			{object}	data.Organization	"Organization"
 // @Failure         400			{object} 	ApiError	"Bad Request"
+// @Failure         403			{object} 	ApiError	"Forbidden"
+// @Failure         404			{object} 	ApiError	"Not Found"
+// @Failure         412			{object} 	ApiError	"Precondition Failed"
+// @Failure         415			{object} 	ApiError	"Unsupported Media Type"
 // @Router			/organization/{id}	[patch]
 func HandlePatchOrganizationByID(w http.ResponseWriter, r *http.Request) *ApiError {
 	ctx := r.Context()
 
 	store, ok := data.GetStore(ctx)
 	if !ok {
-		return &ApiError{http.StatusInternalServerError, "no database store in context"}
+		return &ApiError{Status: http.StatusInternalServerError, Message: "no database store in context"}
+	}
+
+	expectedUpdatedAt, apiErr := GetIfMatch(r)
+	if apiErr != nil {
+		return apiErr
+	}
+
+	orgId, err := GetPathID(r)
+	if err != nil {
+		return &ApiError{Status: http.StatusBadRequest, Message: err.Error()}
+	}
+
+	if apiErr := RequireMembership(ctx, store, orgId, data.PermissionOrganizationWrite); apiErr != nil {
+		return apiErr
 	}
 
-	patchReq := new(PatchOrganizationRequest)
-	if err := DecodeJSONRequest(r, patchReq, 1<<20); err != nil {
-		return &ApiError{http.StatusBadRequest, err.Error()}
+	mergePatch, apiErr := DecodeMergePatch(r, 1<<20)
+	if apiErr != nil {
+		return apiErr
 	}
 
-	org, err := store.Organization.UpdateRequest(
-		patchReq.name,
-		patchReq.uniqueURL,
-		patchReq.address,
-		patchReq.contactInfo,
-		patchReq.organizationType,
-	)
+	patch, err := mergePatch.Apply(organizationPatchFields)
 	if err != nil {
-		return &ApiError{http.StatusBadRequest, err.Error()}
+		return &ApiError{Status: http.StatusBadRequest, Message: err.Error()}
+	}
+
+	resp, err := store.Organization.PatchOrganization(ctx, orgId, patch, expectedUpdatedAt)
+	if err != nil {
+		return StoreError(err)
+	}
+
+	w.Header().Set("ETag", ETag(resp.UpdatedAt))
+	return WriteJSON(w, http.StatusOK, resp)
+
+}
+
+// @Summary			Delete organization by ID
+// @Description		Soft delete an organization. It is hidden from reads until restored, and purged after the retention window. Requires organization.write in the owning organization, or an admin.
+// @Tags			Organization
+// @Security 		ApiKeyAuth
+// @Produce			json
+// @Param			id	path	string	true	"Organization ID"
+// @Success         200			{object}	data.Organization	"Organization"
+// @Failure         400			{object} 	ApiError	"Bad Request"
+// @Failure         403			{object} 	ApiError	"Forbidden"
+// @Failure         404			{object} 	ApiError	"Not Found"
+// @Router			/organization/{id}	[delete]
+func HandleDeleteOrganizationByID(w http.ResponseWriter, r *http.Request) *ApiError {
+	ctx := r.Context()
+
+	store, ok := data.GetStore(ctx)
+	if !ok {
+		return &ApiError{Status: http.StatusInternalServerError, Message: "no database store in context"}
 	}
 
 	orgId, err := GetPathID(r)
 	if err != nil {
-		return &ApiError{http.StatusBadRequest, err.Error()}
+		return &ApiError{Status: http.StatusBadRequest, Message: err.Error()}
 	}
 
-	org.ID = orgId
+	if apiErr := RequireMembership(ctx, store, orgId, data.PermissionOrganizationWrite); apiErr != nil {
+		return apiErr
+	}
 
-	resp, err := store.Organization.UpdateOrganization(org)
+	resp, err := store.Organization.DeleteOrganization(ctx, orgId)
 	if err != nil {
-		return &ApiError{http.StatusInternalServerError, err.Error()}
+		return StoreError(err)
 	}
 
 	return WriteJSON(w, http.StatusOK, resp)
+}
 
+// @Summary			Restore organization by ID
+// @Description		Restore a soft deleted organization (admin only)
+// @Tags			Organization
+// @Security 		ApiKeyAuth
+// @Produce			json
+// @Param			id	path	string	true	"Organization ID"
+// @Success         200			{object}	data.Organization	"Organization"
+// @Failure         400			{object} 	ApiError	"Bad Request"
+// @Failure         404			{object} 	ApiError	"Not Found"
+// @Router			/organization/{id}/restore	[post]
+func HandleRestoreOrganizationByID(w http.ResponseWriter, r *http.Request) *ApiError {
+	ctx := r.Context()
+
+	store, ok := data.GetStore(ctx)
+	if !ok {
+		return &ApiError{Status: http.StatusInternalServerError, Message: "no database store in context"}
+	}
+
+	orgId, err := GetPathID(r)
+	if err != nil {
+		return &ApiError{Status: http.StatusBadRequest, Message: err.Error()}
+	}
+
+	resp, err := store.Organization.RestoreOrganization(ctx, orgId)
+	if err != nil {
+		return StoreError(err)
+	}
+
+	w.Header().Set("ETag", ETag(resp.UpdatedAt))
+	return WriteJSON(w, http.StatusOK, resp)
 }
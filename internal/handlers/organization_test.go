@@ -0,0 +1,239 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/kevin-griley/api/internal/data"
+)
+
+func TestListOrganizationsIncludeDeleted(t *testing.T) {
+	testCases := []struct {
+		name           string
+		path           string
+		expectedStatus int
+	}{
+		{
+			name:           "Live Rows",
+			path:           "/organization",
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "Include Deleted Requires Admin",
+			path:           "/organization?include_deleted=true",
+			expectedStatus: http.StatusForbidden,
+		},
+	}
+
+	finalHandler, token, err := BaseLine(HandleListOrganizations)
+	if err != nil {
+		t.Fatalf("Failed to create baseline: %v", err)
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tc.path, nil)
+			req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token.Raw))
+
+			rr := httptest.NewRecorder()
+			finalHandler.ServeHTTP(rr, req)
+
+			if rr.Code != tc.expectedStatus {
+				t.Errorf("Expected status %d, got %d: %s", tc.expectedStatus, rr.Code, rr.Body.String())
+			}
+		})
+	}
+}
+
+func TestListOrganizationsScopedToMember(t *testing.T) {
+	ctx := context.Background()
+	store, err := NewTestStore()
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	user, err := store.User.GetUserByEmail(ctx, "Kevin")
+	if err != nil {
+		t.Fatalf("Failed to get user: %v", err)
+	}
+
+	own := newMemberOrganization(t, store, user.ID, "own", data.AllPermissions)
+	newMemberOrganization(t, store, user.ID, "other", nil)
+
+	finalHandler, token, err := BaseLineWithStore(store, HandleListOrganizations)
+	if err != nil {
+		t.Fatalf("Failed to create baseline: %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/organization", nil)
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token.Raw))
+	rr := httptest.NewRecorder()
+	finalHandler.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var page data.Page[data.Organization]
+	if err := json.Unmarshal(rr.Body.Bytes(), &page); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if len(page.Items) != 1 || page.Items[0].ID != own.ID {
+		t.Errorf("Expected only the caller's organization, got %+v", page.Items)
+	}
+}
+
+func TestDeleteRequiresMembership(t *testing.T) {
+	ctx := context.Background()
+	store, err := NewTestStore()
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	user, err := store.User.GetUserByEmail(ctx, "Kevin")
+	if err != nil {
+		t.Fatalf("Failed to get user: %v", err)
+	}
+
+	own := newMemberOrganization(t, store, user.ID, "own", data.AllPermissions)
+	readOnly := newMemberOrganization(t, store, user.ID, "read-only", data.Permissions{data.PermissionOrganizationRead})
+	other := newMemberOrganization(t, store, user.ID, "other", nil)
+
+	u, _ := store.UldInventory.CreateRequest("AKE12345DL", data.UldTypeAKE, data.UldInWarehouse, other.ID, data.Carrier, other.ID)
+	otherUld, err := store.UldInventory.CreateUldInventory(ctx, u)
+	if err != nil {
+		t.Fatalf("Failed to create ULD: %v", err)
+	}
+	m, _ := store.DeliveryManifest.CreateRequest(time.Now().UTC(), other.ID, other.ID, other.ID, "", user.ID, other.ID)
+	otherManifest, err := store.DeliveryManifest.CreateDeliveryManifest(ctx, m)
+	if err != nil {
+		t.Fatalf("Failed to create manifest: %v", err)
+	}
+
+	testCases := []struct {
+		name           string
+		handler        ApiFunc
+		id             uuid.UUID
+		expectedStatus int
+	}{
+		{"Other Organization", HandleDeleteOrganizationByID, other.ID, http.StatusNotFound},
+		{"Without Write Permission", HandleDeleteOrganizationByID, readOnly.ID, http.StatusForbidden},
+		{"Other Organization's ULD", HandleDeleteUldByID, otherUld.ID, http.StatusNotFound},
+		{"Other Organization's Manifest", HandleDeleteManifestByID, otherManifest.ID, http.StatusNotFound},
+		{"Own Organization", HandleDeleteOrganizationByID, own.ID, http.StatusOK},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			finalHandler, token, err := BaseLineWithStore(store, tc.handler)
+			if err != nil {
+				t.Fatalf("Failed to create baseline: %v", err)
+			}
+
+			req := httptest.NewRequest(http.MethodDelete, "/"+tc.id.String(), nil)
+			req.SetPathValue("id", tc.id.String())
+			req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token.Raw))
+
+			rr := httptest.NewRecorder()
+			finalHandler.ServeHTTP(rr, req)
+
+			if rr.Code != tc.expectedStatus {
+				t.Errorf("Expected status %d, got %d: %s", tc.expectedStatus, rr.Code, rr.Body.String())
+			}
+		})
+	}
+
+	if _, err := store.Organization.GetOrganizationByID(ctx, other.ID); err != nil {
+		t.Errorf("Expected the other organization to survive, got %v", err)
+	}
+	if _, err := store.UldInventory.GetUldInventoryByID(ctx, otherUld.ID); err != nil {
+		t.Errorf("Expected the other organization's ULD to survive, got %v", err)
+	}
+}
+
+func TestGetAndPatchOrganizationRequireMembership(t *testing.T) {
+	ctx := context.Background()
+	store, err := NewTestStore()
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	user, err := store.User.GetUserByEmail(ctx, "Kevin")
+	if err != nil {
+		t.Fatalf("Failed to get user: %v", err)
+	}
+
+	own := newMemberOrganization(t, store, user.ID, "own", data.AllPermissions)
+	readOnly := newMemberOrganization(t, store, user.ID, "read-only", data.Permissions{data.PermissionOrganizationRead})
+	noRead := newMemberOrganization(t, store, user.ID, "no-read", data.Permissions{data.PermissionUldRead})
+	other := newMemberOrganization(t, store, user.ID, "other", nil)
+
+	testCases := []struct {
+		name           string
+		method         string
+		handler        ApiFunc
+		id             uuid.UUID
+		expectedStatus int
+	}{
+		{"Get Other Organization", http.MethodGet, HandleGetOrganizationByID, other.ID, http.StatusNotFound},
+		{"Get Without Read Permission", http.MethodGet, HandleGetOrganizationByID, noRead.ID, http.StatusForbidden},
+		{"Get Own Organization", http.MethodGet, HandleGetOrganizationByID, readOnly.ID, http.StatusOK},
+		{"Patch Other Organization", http.MethodPatch, HandlePatchOrganizationByID, other.ID, http.StatusNotFound},
+		{"Patch Without Write Permission", http.MethodPatch, HandlePatchOrganizationByID, readOnly.ID, http.StatusForbidden},
+		{"Patch Own Organization", http.MethodPatch, HandlePatchOrganizationByID, own.ID, http.StatusOK},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			finalHandler, token, err := BaseLineWithStore(store, tc.handler)
+			if err != nil {
+				t.Fatalf("Failed to create baseline: %v", err)
+			}
+
+			req := httptest.NewRequest(tc.method, "/"+tc.id.String(), strings.NewReader(`{"name":"renamed"}`))
+			req.SetPathValue("id", tc.id.String())
+			req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token.Raw))
+			req.Header.Set("Content-Type", "application/merge-patch+json")
+
+			rr := httptest.NewRecorder()
+			finalHandler.ServeHTTP(rr, req)
+
+			if rr.Code != tc.expectedStatus {
+				t.Errorf("Expected status %d, got %d: %s", tc.expectedStatus, rr.Code, rr.Body.String())
+			}
+		})
+	}
+
+	org, err := store.Organization.GetOrganizationByID(ctx, other.ID)
+	if err != nil {
+		t.Fatalf("Failed to get organization: %v", err)
+	}
+	if org.Name != "other" {
+		t.Errorf("Expected the other organization to be left unchanged, got name %q", org.Name)
+	}
+}
+
+// newMemberOrganization creates an organization, and an active association to it for userID
+// when permissions is not nil.
+func newMemberOrganization(t *testing.T, store *data.Store, userID uuid.UUID, name string, permissions data.Permissions) *data.Organization {
+	t.Helper()
+	ctx := context.Background()
+	o, err := store.Organization.CreateRequest(name, "1 Cargo Way", "ops@example.com", data.Carrier)
+	if err != nil {
+		t.Fatalf("Failed to build organization: %v", err)
+	}
+	org, err := store.Organization.CreateOrganization(ctx, o)
+	if err != nil {
+		t.Fatalf("Failed to create organization: %v", err)
+	}
+	if permissions != nil {
+		a, _ := store.UserAssociation.CreateRequest(userID, org.ID, data.AssociationActive, permissions)
+		if _, err := store.UserAssociation.CreateUserAssociation(ctx, a); err != nil {
+			t.Fatalf("Failed to create association: %v", err)
+		}
+	}
+	return org
+}
@@ -0,0 +1,97 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+
+	"github.com/kevin-griley/api/internal/data"
+)
+
+const MergePatchContentType = "application/merge-patch+json"
+
+// MergePatch is a decoded RFC 7396 merge patch. Every member present in the document maps to
+// its raw JSON, so an absent member, an explicit null and a zero value can be told apart.
+type MergePatch map[string]json.RawMessage
+
+// PatchField describes how a merge patch member is written to a column.
+type PatchField struct {
+	Column string
+	// Nullable allows the member to be null, which writes Null to the column.
+	Nullable bool
+	Null     any
+	// Decode converts the member's JSON value to the column value.
+	Decode func(raw json.RawMessage) (any, error)
+}
+
+// DecodeAs returns a PatchField decoder that unmarshals the member into a T.
+func DecodeAs[T any]() func(json.RawMessage) (any, error) {
+	return func(raw json.RawMessage) (any, error) {
+		var v T
+		if err := json.Unmarshal(raw, &v); err != nil {
+			return nil, err
+		}
+		return v, nil
+	}
+}
+
+// DecodeMergePatch reads an application/merge-patch+json body. Plain application/json is
+// accepted with the same semantics. The patch must be a JSON object.
+func DecodeMergePatch(r *http.Request, maxSize int64) (MergePatch, *ApiError) {
+	if maxSize <= 0 {
+		maxSize = 1 << 20
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType != MergePatchContentType && mediaType != "application/json" {
+		return nil, &ApiError{Status: http.StatusUnsupportedMediaType, Message: "invalid content type: expected " + MergePatchContentType}
+	}
+
+	body := http.MaxBytesReader(nil, r.Body, maxSize)
+	defer r.Body.Close()
+
+	raw, err := io.ReadAll(body)
+	if err != nil {
+		return nil, &ApiError{Status: http.StatusBadRequest, Message: fmt.Sprintf("invalid JSON: %v", err)}
+	}
+	if len(bytes.TrimSpace(raw)) == 0 {
+		return nil, &ApiError{Status: http.StatusBadRequest, Message: "empty request body"}
+	}
+
+	var patch MergePatch
+	if err := json.Unmarshal(raw, &patch); err != nil || patch == nil {
+		return nil, &ApiError{Status: http.StatusBadRequest, Message: "merge patch must be a JSON object"}
+	}
+
+	return patch, nil
+}
+
+// Apply converts the patch to a data.Patch using fields, keyed by member name.
+// Unknown members and nulls for fields that are not nullable are rejected.
+func (p MergePatch) Apply(fields map[string]PatchField) (data.Patch, error) {
+	patch := data.Patch{}
+	for name, raw := range p {
+		field, ok := fields[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown field %q", name)
+		}
+
+		if bytes.Equal(bytes.TrimSpace(raw), []byte("null")) {
+			if !field.Nullable {
+				return nil, fmt.Errorf("field %q cannot be null", name)
+			}
+			patch[field.Column] = field.Null
+			continue
+		}
+
+		value, err := field.Decode(raw)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value for %q: %v", name, err)
+		}
+		patch[field.Column] = value
+	}
+	return patch, nil
+}
@@ -0,0 +1,209 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/kevin-griley/api/internal/data"
+)
+
+// @Summary			Get ULD by ID
+// @Description		Get ULD by ID
+// @Tags			Uld
+// @Security 		ApiKeyAuth
+// @Produce			json
+// @Param			id	path	string	true	"ULD ID"
+// @Param			include_deleted	query	bool	false	"Include a deleted ULD (admin only)"
+// @Success         200			{object}	data.UldInventory	"ULD"
+// @Failure         400			{object} 	ApiError	"Bad Request"
+// @Failure         403			{object} 	ApiError	"Forbidden"
+// @Failure         404			{object} 	ApiError	"Not Found"
+// @Router			/uld/{id}	[get]
+func HandleGetUldByID(w http.ResponseWriter, r *http.Request) *ApiError {
+	ctx, apiErr := GetIncludeDeleted(r)
+	if apiErr != nil {
+		return apiErr
+	}
+
+	store, ok := data.GetStore(ctx)
+	if !ok {
+		return &ApiError{Status: http.StatusInternalServerError, Message: "no database store in context"}
+	}
+
+	id, err := GetPathID(r)
+	if err != nil {
+		return &ApiError{Status: http.StatusBadRequest, Message: err.Error()}
+	}
+
+	resp, err := store.UldInventory.GetUldInventoryByID(ctx, id)
+	if err != nil {
+		return StoreError(err)
+	}
+
+	w.Header().Set("ETag", ETag(resp.UpdatedAt))
+	return WriteJSON(w, http.StatusOK, resp)
+}
+
+// @Summary			List ULDs
+// @Description		List ULDs ordered by creation time, or by the sort parameter. Follow the next and prev cursors to page through the results.
+// @Description		Filter with column=value or column[op]=value, where op is one of eq, ne, gt, gte, lt, lte or in (comma separated).
+// @Tags			Uld
+// @Security 		ApiKeyAuth
+// @Produce			json
+// @Param			limit	query	int		false	"Page size"
+// @Param			cursor	query	string	false	"Cursor from a previous page"
+// @Param			sort	query	string	false	"Sort column, prefixed with - for descending (created_at, updated_at)"
+// @Param			include_deleted	query	bool	false	"Include deleted ULDs (admin only)"
+// @Success         200			{object}	data.Page[data.UldInventory]	"ULDs"
+// @Failure         400			{object} 	ApiError	"Bad Request"
+// @Failure         403			{object} 	ApiError	"Forbidden"
+// @Router			/uld	[get]
+func HandleListUlds(w http.ResponseWriter, r *http.Request) *ApiError {
+	ctx, apiErr := GetIncludeDeleted(r)
+	if apiErr != nil {
+		return apiErr
+	}
+
+	store, ok := data.GetStore(ctx)
+	if !ok {
+		return &ApiError{Status: http.StatusInternalServerError, Message: "no database store in context"}
+	}
+
+	page, err := GetPageRequest(r, "uld_inventories")
+	if err != nil {
+		return &ApiError{Status: http.StatusBadRequest, Message: err.Error()}
+	}
+
+	resp, err := store.UldInventory.ListUldInventories(ctx, page)
+	if err != nil {
+		return StoreError(err)
+	}
+
+	return WriteJSON(w, http.StatusOK, resp)
+}
+
+// @Summary			Delete ULD by ID
+// @Description		Soft delete a ULD. It is hidden from reads until restored, and purged after the retention window. Requires uld.write in the owning organization, or an admin.
+// @Tags			Uld
+// @Security 		ApiKeyAuth
+// @Produce			json
+// @Param			id	path	string	true	"ULD ID"
+// @Success         200			{object}	data.UldInventory	"ULD"
+// @Failure         400			{object} 	ApiError	"Bad Request"
+// @Failure         403			{object} 	ApiError	"Forbidden"
+// @Failure         404			{object} 	ApiError	"Not Found"
+// @Router			/uld/{id}	[delete]
+func HandleDeleteUldByID(w http.ResponseWriter, r *http.Request) *ApiError {
+	ctx := r.Context()
+
+	store, ok := data.GetStore(ctx)
+	if !ok {
+		return &ApiError{Status: http.StatusInternalServerError, Message: "no database store in context"}
+	}
+
+	id, err := GetPathID(r)
+	if err != nil {
+		return &ApiError{Status: http.StatusBadRequest, Message: err.Error()}
+	}
+
+	uld, err := store.UldInventory.GetUldInventoryByID(ctx, id)
+	if err != nil {
+		return StoreError(err)
+	}
+	if apiErr := RequireMembership(ctx, store, uld.OrganizationID, data.PermissionUldWrite); apiErr != nil {
+		return apiErr
+	}
+
+	resp, err := store.UldInventory.DeleteUldInventory(ctx, id)
+	if err != nil {
+		return StoreError(err)
+	}
+
+	return WriteJSON(w, http.StatusOK, resp)
+}
+
+// @Summary			Restore ULD by ID
+// @Description		Restore a soft deleted ULD (admin only)
+// @Tags			Uld
+// @Security 		ApiKeyAuth
+// @Produce			json
+// @Param			id	path	string	true	"ULD ID"
+// @Success         200			{object}	data.UldInventory	"ULD"
+// @Failure         400			{object} 	ApiError	"Bad Request"
+// @Failure         404			{object} 	ApiError	"Not Found"
+// @Router			/uld/{id}/restore	[post]
+func HandleRestoreUldByID(w http.ResponseWriter, r *http.Request) *ApiError {
+	ctx := r.Context()
+
+	store, ok := data.GetStore(ctx)
+	if !ok {
+		return &ApiError{Status: http.StatusInternalServerError, Message: "no database store in context"}
+	}
+
+	id, err := GetPathID(r)
+	if err != nil {
+		return &ApiError{Status: http.StatusBadRequest, Message: err.Error()}
+	}
+
+	resp, err := store.UldInventory.RestoreUldInventory(ctx, id)
+	if err != nil {
+		return StoreError(err)
+	}
+
+	w.Header().Set("ETag", ETag(resp.UpdatedAt))
+	return WriteJSON(w, http.StatusOK, resp)
+}
+
+type PatchUldStatusRequest struct {
+	UldStatus data.UldStatus `json:"uld_status"`
+}
+
+// @Summary			Update ULD status
+// @Description		Move a ULD to a new status. A change publishes a uld.status_changed event.
+// @Tags			Uld
+// @Security 		ApiKeyAuth
+// @Accept			application/merge-patch+json
+// @Accept			json
+// @Produce			json
+// @Param			id	path	string	true	"ULD ID"
+// @Param			body	body		PatchUldStatusRequest	true	"Patch ULD Status Request"
+// @Success         200			{object}	data.UldInventory	"ULD"
+// @Failure         400			{object} 	ApiError	"Bad Request"
+// @Failure         404			{object} 	ApiError	"Not Found"
+// @Router			/uld/{id}/status	[patch]
+func HandlePatchUldStatus(w http.ResponseWriter, r *http.Request) *ApiError {
+	ctx := r.Context()
+
+	store, ok := data.GetStore(ctx)
+	if !ok {
+		return &ApiError{Status: http.StatusInternalServerError, Message: "no database store in context"}
+	}
+
+	id, err := GetPathID(r)
+	if err != nil {
+		return &ApiError{Status: http.StatusBadRequest, Message: err.Error()}
+	}
+
+	mergePatch, apiErr := DecodeMergePatch(r, 1<<20)
+	if apiErr != nil {
+		return apiErr
+	}
+
+	patch, err := mergePatch.Apply(map[string]PatchField{
+		"uld_status": {Column: "uld_status", Decode: DecodeAs[data.UldStatus]()},
+	})
+	if err != nil {
+		return &ApiError{Status: http.StatusBadRequest, Message: err.Error()}
+	}
+	status, ok := patch["uld_status"].(data.UldStatus)
+	if !ok {
+		return &ApiError{Status: http.StatusBadRequest, Message: "uld_status is required"}
+	}
+
+	resp, err := store.UldInventory.UpdateUldStatus(ctx, id, status)
+	if err != nil {
+		return StoreError(err)
+	}
+
+	w.Header().Set("ETag", ETag(resp.UpdatedAt))
+	return WriteJSON(w, http.StatusOK, resp)
+}
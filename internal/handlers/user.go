@@ -1,6 +1,8 @@
 package handlers
 
 import (
+	"encoding/json"
+	"fmt"
 	"net/http"
 
 	"github.com/kevin-griley/api/internal/data"
@@ -18,30 +20,34 @@ type PostUserRequest struct {
 // @Accept			json
 // @Produce			json
 // @Param			body	body		PostUserRequest	true	"Create User Request"
+// @Param			Idempotency-Key	header	string	false	"Replays the first response to retries with the same key"
 // @Success         200		{object}	data.User	"User"
 // @Failure         400		{object} 	ApiError	"Bad Request"
+// @Failure         409		{object} 	ApiError	"Conflict"
+// @Failure         422		{object} 	ApiError	"Idempotency-Key Reused"
+// @Failure         429		{object} 	ApiError	"Too Many Requests"
 // @Router			/user	[post]
 func HandlePostUser(w http.ResponseWriter, r *http.Request) *ApiError {
 	ctx := r.Context()
 
 	store, ok := data.GetStore(ctx)
 	if !ok {
-		return &ApiError{http.StatusInternalServerError, "no database store in context"}
+		return &ApiError{Status: http.StatusInternalServerError, Message: "no database store in context"}
 	}
 
 	postReq := new(PostUserRequest)
 	if err := DecodeJSONRequest(r, postReq, 1<<20); err != nil {
-		return &ApiError{http.StatusBadRequest, err.Error()}
+		return &ApiError{Status: http.StatusBadRequest, Message: err.Error()}
 	}
 
 	user, err := store.User.CreateRequest(postReq.Email, postReq.Password)
 	if err != nil {
-		return &ApiError{http.StatusBadRequest, err.Error()}
+		return &ApiError{Status: http.StatusBadRequest, Message: err.Error()}
 	}
 
-	resp, err := store.User.CreateUser(user)
+	resp, err := store.User.CreateUser(ctx, user)
 	if err != nil {
-		return &ApiError{http.StatusInternalServerError, err.Error()}
+		return StoreError(err)
 	}
 
 	return WriteJSON(w, http.StatusOK, resp)
@@ -61,66 +67,153 @@ func HandleGetUserByKey(w http.ResponseWriter, r *http.Request) *ApiError {
 
 	store, ok := data.GetStore(ctx)
 	if !ok {
-		return &ApiError{http.StatusInternalServerError, "no database store in context"}
+		return &ApiError{Status: http.StatusInternalServerError, Message: "no database store in context"}
 	}
 
 	userID, ok := middleware.GetUserID(ctx)
 	if !ok {
-		return &ApiError{http.StatusBadRequest, "Invalid user id"}
+		return &ApiError{Status: http.StatusBadRequest, Message: "Invalid user id"}
 	}
 
-	user, err := store.User.GetUserByID(userID)
+	user, err := store.User.GetUserByID(ctx, userID)
 	if err != nil {
-		return &ApiError{http.StatusNotFound, err.Error()}
+		return StoreError(err)
 	}
 
+	w.Header().Set("ETag", ETag(user.UpdatedAt))
 	return WriteJSON(w, http.StatusOK, user)
 }
 
+// PatchUserRequest documents the merge patch accepted by PATCH /user/me.
+// Omitted fields are left unchanged. Null clears user_name.
 type PatchUserRequest struct {
-	UserName string `json:"user_name"`
-	Password string `json:"password"`
+	UserName string `json:"user_name,omitempty"`
+	Password string `json:"password,omitempty"`
+}
+
+var userPatchFields = map[string]PatchField{
+	"user_name": {Column: "user_name", Nullable: true, Decode: DecodeAs[string]()},
+	"password": {Column: "hashed_password", Decode: func(raw json.RawMessage) (any, error) {
+		var password string
+		if err := json.Unmarshal(raw, &password); err != nil {
+			return nil, err
+		}
+		if password == "" {
+			return nil, fmt.Errorf("password cannot be empty")
+		}
+		return data.HashPassword(password)
+	}},
 }
 
 // @Summary			Patch user by apiKey
-// @Description		Patch user by apiKey
+// @Description		Apply a JSON merge patch (RFC 7396) to the caller. Omitted fields are left unchanged, and null clears a field.
 // @Tags			User
 // @Security 		ApiKeyAuth
+// @Accept			application/merge-patch+json
 // @Accept			json
 // @Produce			json
 // @Param			body		body		PatchUserRequest	true	"Patch User Request"
+// @Param			If-Match	header		string				false	"ETag from a previous read; the update fails if the user changed since"
 // @Success         200			{object}	data.User	"User"
 // @Failure         400			{object} 	ApiError	"Bad Request"
+// @Failure         412			{object} 	ApiError	"Precondition Failed"
+// @Failure         415			{object} 	ApiError	"Unsupported Media Type"
 // @Router			/user/me	[patch]
 func HandlePatchUser(w http.ResponseWriter, r *http.Request) *ApiError {
 	ctx := r.Context()
 
 	store, ok := data.GetStore(ctx)
 	if !ok {
-		return &ApiError{http.StatusInternalServerError, "no database store in context"}
+		return &ApiError{Status: http.StatusInternalServerError, Message: "no database store in context"}
 	}
 
-	patchReq := new(PatchUserRequest)
-	if err := DecodeJSONRequest(r, patchReq, 1<<20); err != nil {
-		return &ApiError{http.StatusBadRequest, err.Error()}
+	expectedUpdatedAt, apiErr := GetIfMatch(r)
+	if apiErr != nil {
+		return apiErr
 	}
 
-	user, err := store.User.UpdateRequest(patchReq.UserName, patchReq.Password)
+	mergePatch, apiErr := DecodeMergePatch(r, 1<<20)
+	if apiErr != nil {
+		return apiErr
+	}
+
+	patch, err := mergePatch.Apply(userPatchFields)
 	if err != nil {
-		return &ApiError{http.StatusBadRequest, err.Error()}
+		return &ApiError{Status: http.StatusBadRequest, Message: err.Error()}
 	}
 
 	userID, ok := middleware.GetUserID(ctx)
 	if !ok {
-		return &ApiError{http.StatusBadRequest, "Invalid user id"}
+		return &ApiError{Status: http.StatusBadRequest, Message: "Invalid user id"}
+	}
+
+	resp, err := store.User.PatchUser(ctx, userID, patch, expectedUpdatedAt)
+	if err != nil {
+		return StoreError(err)
 	}
 
-	user.ID = userID
+	w.Header().Set("ETag", ETag(resp.UpdatedAt))
+	return WriteJSON(w, http.StatusOK, resp)
+}
+
+// @Summary			Delete user by apiKey
+// @Description		Soft delete the caller's account. An admin can restore it until it is purged after the retention window.
+// @Tags			User
+// @Security 		ApiKeyAuth
+// @Produce			json
+// @Success         200			{object}	data.User	"User"
+// @Failure         400			{object} 	ApiError	"Bad Request"
+// @Failure         404			{object} 	ApiError	"Not Found"
+// @Router			/user/me	[delete]
+func HandleDeleteUser(w http.ResponseWriter, r *http.Request) *ApiError {
+	ctx := r.Context()
+
+	store, ok := data.GetStore(ctx)
+	if !ok {
+		return &ApiError{Status: http.StatusInternalServerError, Message: "no database store in context"}
+	}
+
+	userID, ok := middleware.GetUserID(ctx)
+	if !ok {
+		return &ApiError{Status: http.StatusBadRequest, Message: "Invalid user id"}
+	}
+
+	resp, err := store.User.DeleteUser(ctx, userID)
+	if err != nil {
+		return StoreError(err)
+	}
+
+	return WriteJSON(w, http.StatusOK, resp)
+}
+
+// @Summary			Restore user by ID
+// @Description		Restore a soft deleted user (admin only)
+// @Tags			User
+// @Security 		ApiKeyAuth
+// @Produce			json
+// @Param			id	path	string	true	"User ID"
+// @Success         200			{object}	data.User	"User"
+// @Failure         400			{object} 	ApiError	"Bad Request"
+// @Failure         404			{object} 	ApiError	"Not Found"
+// @Router			/user/{id}/restore	[post]
+func HandleRestoreUser(w http.ResponseWriter, r *http.Request) *ApiError {
+	ctx := r.Context()
+
+	store, ok := data.GetStore(ctx)
+	if !ok {
+		return &ApiError{Status: http.StatusInternalServerError, Message: "no database store in context"}
+	}
+
+	userID, err := GetPathID(r)
+	if err != nil {
+		return &ApiError{Status: http.StatusBadRequest, Message: err.Error()}
+	}
 
-	resp, err := store.User.UpdateUser(user)
+	resp, err := store.User.RestoreUser(ctx, userID)
 	if err != nil {
-		return &ApiError{http.StatusInternalServerError, err.Error()}
+		return StoreError(err)
 	}
 
+	w.Header().Set("ETag", ETag(resp.UpdatedAt))
 	return WriteJSON(w, http.StatusOK, resp)
 }
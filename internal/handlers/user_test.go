@@ -7,9 +7,11 @@ import (
 	"math/rand"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 
 	"github.com/kevin-griley/api/internal/data"
+	"github.com/kevin-griley/api/internal/middleware"
 )
 
 func TestUsers(t *testing.T) {
@@ -80,3 +82,187 @@ func TestUsers(t *testing.T) {
 	}
 
 }
+
+func TestPatchUserIfMatch(t *testing.T) {
+	finalHandler, token, err := BaseLine(HandlePatchUser)
+	if err != nil {
+		t.Fatalf("Failed to create baseline: %v", err)
+	}
+
+	patch := func(ifMatch string) *httptest.ResponseRecorder {
+		reqBody, err := json.Marshal(PatchUserRequest{Password: "Kevin"})
+		if err != nil {
+			t.Fatalf("Failed to marshal JSON: %v", err)
+		}
+
+		req := httptest.NewRequest(http.MethodPatch, "/user/me", bytes.NewBuffer(reqBody))
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token.Raw))
+		req.Header.Set("Content-Type", "application/json")
+		if ifMatch != "" {
+			req.Header.Set("If-Match", ifMatch)
+		}
+
+		rr := httptest.NewRecorder()
+		finalHandler.ServeHTTP(rr, req)
+		return rr
+	}
+
+	rr := patch("")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, rr.Code)
+	}
+	first := rr.Header().Get("ETag")
+	if first == "" {
+		t.Fatalf("Expected ETag header")
+	}
+
+	rr = patch(first)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status %d with current ETag, got %d", http.StatusOK, rr.Code)
+	}
+	if rr.Header().Get("ETag") == first {
+		t.Errorf("Expected ETag to change after update")
+	}
+
+	for _, stale := range []string{first, `"garbage"`, "not-quoted"} {
+		if rr := patch(stale); rr.Code != http.StatusPreconditionFailed {
+			t.Errorf("Expected status %d for If-Match %s, got %d", http.StatusPreconditionFailed, stale, rr.Code)
+		}
+	}
+}
+
+func TestPatchUserMergePatch(t *testing.T) {
+	finalHandler, token, err := BaseLine(HandlePatchUser)
+	if err != nil {
+		t.Fatalf("Failed to create baseline: %v", err)
+	}
+
+	testCases := []struct {
+		name             string
+		contentType      string
+		body             string
+		expectedStatus   int
+		expectedUserName string
+	}{
+		{
+			name:             "Set User Name",
+			contentType:      MergePatchContentType,
+			body:             `{"user_name": "Kevin G"}`,
+			expectedStatus:   http.StatusOK,
+			expectedUserName: "Kevin G",
+		},
+		{
+			name:             "Absent Fields Unchanged",
+			contentType:      MergePatchContentType,
+			body:             `{"password": "Kevin"}`,
+			expectedStatus:   http.StatusOK,
+			expectedUserName: "Kevin G",
+		},
+		{
+			name:             "Null Clears User Name",
+			contentType:      MergePatchContentType,
+			body:             `{"user_name": null}`,
+			expectedStatus:   http.StatusOK,
+			expectedUserName: "",
+		},
+		{
+			name:           "Null Password",
+			contentType:    MergePatchContentType,
+			body:           `{"password": null}`,
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "Unknown Field",
+			contentType:    MergePatchContentType,
+			body:           `{"is_admin": true}`,
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "Not An Object",
+			contentType:    MergePatchContentType,
+			body:           `["user_name"]`,
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "Unsupported Content Type",
+			contentType:    "text/plain",
+			body:           `{"user_name": "Kevin"}`,
+			expectedStatus: http.StatusUnsupportedMediaType,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPatch, "/user/me", bytes.NewBufferString(tc.body))
+			req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token.Raw))
+			req.Header.Set("Content-Type", tc.contentType)
+
+			rr := httptest.NewRecorder()
+			finalHandler.ServeHTTP(rr, req)
+
+			if rr.Code != tc.expectedStatus {
+				t.Fatalf("Expected status %d, got %d: %s", tc.expectedStatus, rr.Code, rr.Body.String())
+			}
+			if tc.expectedStatus != http.StatusOK {
+				return
+			}
+
+			resp := new(data.User)
+			if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("Failed to unmarshal response: %v", err)
+			}
+			if resp.UserName != tc.expectedUserName {
+				t.Errorf("Expected user name %q, got %q", tc.expectedUserName, resp.UserName)
+			}
+		})
+	}
+}
+
+func TestPostUserDuplicateEmail(t *testing.T) {
+	store, err := NewTestStore()
+	if err != nil {
+		t.Fatalf("Failed to create test store: %v", err)
+	}
+
+	handler := middleware.Chain(HandleApiError(HandlePostUser), middleware.StoreMiddleware(store))
+
+	testCases := []struct {
+		name           string
+		email          string
+		expectedStatus int
+	}{
+		{name: "New Email", email: "duplicate@example.com", expectedStatus: http.StatusOK},
+		{name: "Duplicate Email", email: "duplicate@example.com", expectedStatus: http.StatusConflict},
+		{name: "Duplicate Email Case", email: "DUPLICATE@example.com", expectedStatus: http.StatusConflict},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			reqBody, err := json.Marshal(PostUserRequest{Email: tc.email, Password: "Kevin"})
+			if err != nil {
+				t.Fatalf("Failed to marshal JSON: %v", err)
+			}
+
+			req := httptest.NewRequest(http.MethodPost, "/user", bytes.NewBuffer(reqBody))
+			req.Header.Set("Content-Type", "application/json")
+
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+
+			if rr.Code != tc.expectedStatus {
+				t.Fatalf("Expected status %d, got %d: %s", tc.expectedStatus, rr.Code, rr.Body.String())
+			}
+			if rr.Code == http.StatusOK {
+				return
+			}
+
+			resp := new(ApiError)
+			if err := json.Unmarshal(rr.Body.Bytes(), resp); err != nil {
+				t.Fatalf("Failed to unmarshal response: %v", err)
+			}
+			if !strings.HasPrefix(resp.Message, "conflict: users_") || strings.Contains(resp.Message, "duplicate key") {
+				t.Errorf("Expected a conflict naming a users constraint, got %q", resp.Message)
+			}
+		})
+	}
+}
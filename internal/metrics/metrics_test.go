@@ -0,0 +1,40 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestHandler(t *testing.T) {
+	testCases := []struct {
+		name        string
+		accept      string
+		contentType string
+	}{
+		{"Text", "", "text/plain; version=0.0.4"},
+		{"OpenMetrics", "application/openmetrics-text; version=1.0.0", "application/openmetrics-text; version=1.0.0"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+			if tc.accept != "" {
+				req.Header.Set("Accept", tc.accept)
+			}
+			rr := httptest.NewRecorder()
+			Handler().ServeHTTP(rr, req)
+
+			if rr.Code != http.StatusOK {
+				t.Fatalf("Expected status 200, got %d", rr.Code)
+			}
+			if got := rr.Header().Get("Content-Type"); !strings.HasPrefix(got, tc.contentType) {
+				t.Errorf("Expected content type %q, got %q", tc.contentType, got)
+			}
+			if !strings.Contains(rr.Body.String(), "go_goroutines") {
+				t.Errorf("Expected the Go runtime metrics, got:\n%s", rr.Body.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,42 @@
+package middleware
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+
+	"github.com/kevin-griley/api/internal/data"
+)
+
+// AdminMiddleware only lets through requests whose authenticated user is an admin.
+// It must run after JwtAuthMiddleware and StoreMiddleware.
+func AdminMiddleware(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !IsAdmin(r.Context()) {
+			PermissionDenied(w)
+			return
+		}
+		next(w, r)
+	}
+}
+
+// IsAdmin reports whether the authenticated user in ctx is an admin.
+func IsAdmin(ctx context.Context) bool {
+	userID, ok := GetUserID(ctx)
+	if !ok {
+		return false
+	}
+
+	store, ok := data.GetStore(ctx)
+	if !ok {
+		return false
+	}
+
+	user, err := store.User.GetUserByID(ctx, userID)
+	if err != nil {
+		slog.Error("IsAdmin", "GetUserByID", err)
+		return false
+	}
+
+	return user.IsAdmin
+}
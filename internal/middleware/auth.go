@@ -7,11 +7,11 @@ import (
 	"log/slog"
 
 	"net/http"
-	"os"
 	"strings"
 
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
+	"github.com/kevin-griley/api/internal/data"
 )
 
 const ContextKeyUserID ContextKey = "ContextKeyUserID"
@@ -25,56 +25,61 @@ func ExtractBearerToken(authHeader string) (string, error) {
 	return strings.TrimSpace(strings.TrimPrefix(authHeader, prefix)), nil
 }
 
-func JwtAuthMiddleware(next http.HandlerFunc) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-
-		ctx := r.Context()
-
-		authHeader := r.Header.Get("Authorization")
-		tokenStr, err := ExtractBearerToken(authHeader)
-		if err != nil {
-			slog.Error("JwtAuthMiddleware", "ExtractBearerToken", err)
-			PermissionDenied(w)
-			return
-		}
-
-		token, err := ValidateJWT(tokenStr)
-		if err != nil || !token.Valid {
-			slog.Error("JwtAuthMiddleware", "ValidateJWT", err)
-			PermissionDenied(w)
-			return
-		}
-
-		claims, ok := token.Claims.(jwt.MapClaims)
-		if !ok {
-			slog.Error("JwtAuthMiddleware", "token.Claims", err)
-			PermissionDenied(w)
-			return
-		}
-
-		subject, err := claims.GetSubject()
-		if err != nil {
-			slog.Error("JwtAuthMiddleware", "claims.GetSubject", err)
-			PermissionDenied(w)
-			return
+// JwtAuthMiddleware requires a bearer token signed with secret, and puts its subject in the
+// context as the user ID.
+func JwtAuthMiddleware(secret string) Middleware {
+	return func(next http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+
+			ctx := r.Context()
+
+			authHeader := r.Header.Get("Authorization")
+			tokenStr, err := ExtractBearerToken(authHeader)
+			if err != nil {
+				slog.Error("JwtAuthMiddleware", "ExtractBearerToken", err)
+				PermissionDenied(w)
+				return
+			}
+
+			token, err := ValidateJWT(tokenStr, secret)
+			if err != nil || !token.Valid {
+				slog.Error("JwtAuthMiddleware", "ValidateJWT", err)
+				PermissionDenied(w)
+				return
+			}
+
+			claims, ok := token.Claims.(jwt.MapClaims)
+			if !ok {
+				slog.Error("JwtAuthMiddleware", "token.Claims", err)
+				PermissionDenied(w)
+				return
+			}
+
+			subject, err := claims.GetSubject()
+			if err != nil {
+				slog.Error("JwtAuthMiddleware", "claims.GetSubject", err)
+				PermissionDenied(w)
+				return
+			}
+
+			userID, err := uuid.Parse(subject)
+			if err != nil {
+				PermissionDenied(w)
+				return
+			}
+
+			ctx = withUserID(ctx, userID)
+			ctx = withClaims(ctx, claims)
+			ctx = data.WithActor(ctx, userID)
+			logUserID(ctx, userID)
+
+			next(w, r.WithContext(ctx))
 		}
-
-		userID, err := uuid.Parse(subject)
-		if err != nil {
-			PermissionDenied(w)
-			return
-		}
-
-		ctx = withUserID(ctx, userID)
-		ctx = withClaims(ctx, claims)
-
-		next(w, r.WithContext(ctx))
-
 	}
 }
 
-func ValidateJWT(tokenStr string) (*jwt.Token, error) {
-	secret := os.Getenv("JWT_SECRET")
+// ValidateJWT parses tokenStr and checks it was signed with secret.
+func ValidateJWT(tokenStr, secret string) (*jwt.Token, error) {
 	return jwt.Parse(tokenStr, func(token *jwt.Token) (any, error) {
 		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
 			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
@@ -83,6 +88,11 @@ func ValidateJWT(tokenStr string) (*jwt.Token, error) {
 	})
 }
 
+// SignJWT signs claims with secret, for JwtAuthMiddleware to accept.
+func SignJWT(claims jwt.Claims, secret string) (string, error) {
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(secret))
+}
+
 type ApiError struct {
 	Status  int    `json:"status"`
 	Message string `json:"error"`
@@ -0,0 +1,307 @@
+package middleware
+
+import (
+	"compress/gzip"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DefaultMinCompressSize is the smallest response body Compress compresses. Smaller bodies
+// gain little and can grow once headers and framing are added.
+const DefaultMinCompressSize = 1024
+
+// Encoding is a content coding Compress can apply, such as gzip. Other codings, such as
+// zstd or brotli, are supported by passing an Encoding built on their writer.
+type Encoding struct {
+	Name string
+	// NewWriter returns a writer compressing into w. Compress closes it to finish the body.
+	NewWriter func(w io.Writer) io.WriteCloser
+}
+
+var gzipWriters = sync.Pool{
+	New: func() any { return gzip.NewWriter(io.Discard) },
+}
+
+// pooledGzip returns its writer to gzipWriters once closed.
+type pooledGzip struct {
+	*gzip.Writer
+}
+
+func (g pooledGzip) Close() error {
+	err := g.Writer.Close()
+	gzipWriters.Put(g.Writer)
+	return err
+}
+
+// Gzip compresses with compress/gzip at its default level.
+var Gzip = Encoding{
+	Name: "gzip",
+	NewWriter: func(w io.Writer) io.WriteCloser {
+		gz := gzipWriters.Get().(*gzip.Writer)
+		gz.Reset(w)
+		return pooledGzip{gz}
+	},
+}
+
+// Compress compresses response bodies of at least minSize bytes with the encoding the
+// client ranks highest in Accept-Encoding among encodings, preferring earlier encodings on
+// ties. Bodies that are already compressed, by Content-Encoding or by their Content-Type,
+// are sent as they are. Request bodies sent with Content-Encoding gzip are decompressed
+// before later handlers read them.
+func Compress(minSize int, encodings ...Encoding) Middleware {
+	if len(encodings) == 0 {
+		encodings = []Encoding{Gzip}
+	}
+
+	return func(next http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			if ok := decompressRequest(w, r); !ok {
+				return
+			}
+
+			w.Header().Add("Vary", "Accept-Encoding")
+			encoding, ok := negotiateEncoding(r.Header.Get("Accept-Encoding"), encodings)
+			if !ok || r.Method == http.MethodHead {
+				next(w, r)
+				return
+			}
+
+			cw := &compressWriter{ResponseWriter: w, encoding: encoding, minSize: minSize}
+			next(cw, r)
+			cw.Close()
+		}
+	}
+}
+
+// decompressRequest replaces a gzip request body with its decompressed content. It answers
+// the request itself and returns false when the body cannot be decoded.
+func decompressRequest(w http.ResponseWriter, r *http.Request) bool {
+	switch strings.ToLower(strings.TrimSpace(r.Header.Get("Content-Encoding"))) {
+	case "", "identity":
+		return true
+	case "gzip", "x-gzip":
+	default:
+		w.Header().Set("Accept-Encoding", "gzip")
+		UnsupportedContentEncoding(w)
+		return false
+	}
+
+	gz, err := gzip.NewReader(r.Body)
+	if err != nil {
+		InvalidRequestBody(w)
+		return false
+	}
+	r.Body = gzipBody{Reader: gz, body: r.Body}
+	r.Header.Del("Content-Encoding")
+	r.Header.Del("Content-Length")
+	r.ContentLength = -1
+	return true
+}
+
+type gzipBody struct {
+	*gzip.Reader
+	body io.ReadCloser
+}
+
+func (b gzipBody) Close() error {
+	b.Reader.Close()
+	return b.body.Close()
+}
+
+func UnsupportedContentEncoding(w http.ResponseWriter) {
+	w.Header().Add("Content-Type", "application/json")
+	w.WriteHeader(http.StatusUnsupportedMediaType)
+	w.Write([]byte(`{"status":415,"error":"Unsupported Content-Encoding"}`))
+}
+
+func InvalidRequestBody(w http.ResponseWriter) {
+	w.Header().Add("Content-Type", "application/json")
+	w.WriteHeader(http.StatusBadRequest)
+	w.Write([]byte(`{"status":400,"error":"Invalid Request Body"}`))
+}
+
+// negotiateEncoding picks the encoding with the highest quality in an Accept-Encoding
+// header. Codings the client gives a quality of zero, directly or through "*", are never
+// picked.
+func negotiateEncoding(header string, encodings []Encoding) (Encoding, bool) {
+	if header == "" {
+		return Encoding{}, false
+	}
+
+	qualities := map[string]float64{}
+	for _, part := range strings.Split(header, ",") {
+		name, params, _ := strings.Cut(part, ";")
+		name = strings.ToLower(strings.TrimSpace(name))
+		q := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			parsed, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				continue
+			}
+			q = parsed
+		}
+		qualities[name] = q
+	}
+
+	var best Encoding
+	bestQ := 0.0
+	for _, e := range encodings {
+		q, ok := qualities[e.Name]
+		if !ok {
+			q, ok = qualities["*"]
+		}
+		if ok && q > bestQ {
+			best, bestQ = e, q
+		}
+	}
+	return best, bestQ > 0
+}
+
+// compressWriter holds the response back until it has minSize bytes, then decides whether
+// to compress it. WriteHeader is deferred with it, so handlers such as WriteJSON that set
+// the status before writing still get compressed.
+type compressWriter struct {
+	http.ResponseWriter
+	encoding Encoding
+	minSize  int
+
+	status  int
+	buf     []byte
+	started bool
+	writer  io.WriteCloser // nil when the body is sent as it is
+}
+
+func (w *compressWriter) WriteHeader(status int) {
+	if w.started || w.status != 0 {
+		return
+	}
+	// Informational responses are sent on as they come.
+	if status >= 100 && status < 200 {
+		w.ResponseWriter.WriteHeader(status)
+		return
+	}
+	w.status = status
+}
+
+func (w *compressWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	if w.started {
+		return w.body().Write(b)
+	}
+
+	w.buf = append(w.buf, b...)
+	if len(w.buf) >= w.minSize || !w.compressible() {
+		if err := w.start(true); err != nil {
+			return 0, err
+		}
+	}
+	return len(b), nil
+}
+
+// Close sends whatever was held back and finishes the compressed body.
+func (w *compressWriter) Close() error {
+	if !w.started {
+		if w.status == 0 {
+			w.status = http.StatusOK
+		}
+		// The whole body is known, so it is compressed only if large enough.
+		if err := w.start(len(w.buf) >= w.minSize); err != nil {
+			return err
+		}
+	}
+	if w.writer != nil {
+		return w.writer.Close()
+	}
+	return nil
+}
+
+// Flush sends what has been written so far, compressing it if the body is being compressed.
+func (w *compressWriter) Flush() {
+	if !w.started {
+		if w.status == 0 {
+			w.status = http.StatusOK
+		}
+		w.start(len(w.buf) >= w.minSize)
+	}
+	if f, ok := w.writer.(interface{ Flush() error }); ok {
+		f.Flush()
+	}
+	http.NewResponseController(w.ResponseWriter).Flush()
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer.
+func (w *compressWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+func (w *compressWriter) body() io.Writer {
+	if w.writer != nil {
+		return w.writer
+	}
+	return w.ResponseWriter
+}
+
+// start sends the header, compressing the body if compress is set and the response allows,
+// and writes the held back bytes.
+func (w *compressWriter) start(compress bool) error {
+	w.started = true
+
+	if compress && w.compressible() {
+		h := w.Header()
+		h.Set("Content-Encoding", w.encoding.Name)
+		h.Del("Content-Length")
+		// ETags are left strong: they name the row's version, which If-Match compares
+		// against, rather than the bytes sent.
+		w.writer = w.encoding.NewWriter(w.ResponseWriter)
+	}
+	w.ResponseWriter.WriteHeader(w.status)
+
+	buf := w.buf
+	w.buf = nil
+	if len(buf) == 0 {
+		return nil
+	}
+	_, err := w.body().Write(buf)
+	return err
+}
+
+func (w *compressWriter) compressible() bool {
+	if w.status == http.StatusNoContent || w.status == http.StatusNotModified || w.status < 200 {
+		return false
+	}
+	h := w.Header()
+	if h.Get("Content-Encoding") != "" || h.Get("Content-Range") != "" {
+		return false
+	}
+	return compressibleType(h.Get("Content-Type"))
+}
+
+// compressibleType reports whether a body of contentType is worth compressing. Images,
+// audio, video and archives are already compressed. An unset type is sniffed by net/http
+// from the body, which cannot be done once it is compressed, so it is left alone.
+func compressibleType(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	switch {
+	case mediaType == "image/svg+xml":
+		return true
+	case strings.HasPrefix(mediaType, "image/"),
+		strings.HasPrefix(mediaType, "audio/"),
+		strings.HasPrefix(mediaType, "video/"):
+		return false
+	}
+	switch mediaType {
+	case "application/zip", "application/gzip", "application/x-gzip", "application/zstd",
+		"application/x-brotli", "application/octet-stream", "application/pdf":
+		return false
+	}
+	return true
+}
@@ -0,0 +1,277 @@
+package middleware
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// writeJSON mirrors handlers.WriteJSON, which sets the status before writing the body.
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Add("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func gunzip(t *testing.T, b []byte) string {
+	t.Helper()
+
+	gz, err := gzip.NewReader(bytes.NewReader(b))
+	if err != nil {
+		t.Fatalf("Failed to read gzip body: %v", err)
+	}
+	out, err := io.ReadAll(gz)
+	if err != nil {
+		t.Fatalf("Failed to read gzip body: %v", err)
+	}
+	return string(out)
+}
+
+func TestCompress(t *testing.T) {
+	large := map[string]string{"ulds": strings.Repeat("AKE12345DL,", 200)}
+	largeJSON, _ := json.Marshal(large)
+
+	testCases := []struct {
+		name             string
+		method           string
+		acceptEncoding   string
+		handler          http.HandlerFunc
+		expectedStatus   int
+		expectedEncoding string
+		expectedBody     string
+	}{
+		{
+			name:           "Large JSON",
+			acceptEncoding: "gzip, deflate, br",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				writeJSON(w, http.StatusCreated, large)
+			},
+			expectedStatus:   http.StatusCreated,
+			expectedEncoding: "gzip",
+			expectedBody:     string(largeJSON) + "\n",
+		},
+		{
+			name:           "Large JSON In Pieces",
+			acceptEncoding: "gzip",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				for _, b := range bytes.SplitAfter(largeJSON, []byte(",")) {
+					w.Write(b)
+				}
+			},
+			expectedStatus:   http.StatusOK,
+			expectedEncoding: "gzip",
+			expectedBody:     string(largeJSON),
+		},
+		{
+			name:           "Small JSON",
+			acceptEncoding: "gzip",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				writeJSON(w, http.StatusOK, map[string]string{"id": "1"})
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody:   `{"id":"1"}` + "\n",
+		},
+		{
+			name: "Not Accepted",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				writeJSON(w, http.StatusOK, large)
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody:   string(largeJSON) + "\n",
+		},
+		{
+			name:           "Refused",
+			acceptEncoding: "gzip;q=0, *;q=0.5",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				writeJSON(w, http.StatusOK, large)
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody:   string(largeJSON) + "\n",
+		},
+		{
+			name:           "Wildcard",
+			acceptEncoding: "*",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				writeJSON(w, http.StatusOK, large)
+			},
+			expectedStatus:   http.StatusOK,
+			expectedEncoding: "gzip",
+			expectedBody:     string(largeJSON) + "\n",
+		},
+		{
+			name:           "Already Compressed Type",
+			acceptEncoding: "gzip",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "image/png")
+				w.Write(largeJSON)
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody:   string(largeJSON),
+		},
+		{
+			name:           "Already Encoded",
+			acceptEncoding: "gzip",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				w.Header().Set("Content-Encoding", "identity")
+				w.Write(largeJSON)
+			},
+			expectedStatus:   http.StatusOK,
+			expectedEncoding: "identity",
+			expectedBody:     string(largeJSON),
+		},
+		{
+			name:           "No Content",
+			acceptEncoding: "gzip",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusNoContent)
+			},
+			expectedStatus: http.StatusNoContent,
+		},
+		{
+			name:           "Head",
+			method:         http.MethodHead,
+			acceptEncoding: "gzip",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusOK)
+			},
+			expectedStatus: http.StatusOK,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			method := tc.method
+			if method == "" {
+				method = http.MethodGet
+			}
+			req := httptest.NewRequest(method, "/uld", nil)
+			if tc.acceptEncoding != "" {
+				req.Header.Set("Accept-Encoding", tc.acceptEncoding)
+			}
+			rr := httptest.NewRecorder()
+			Chain(tc.handler, Compress(DefaultMinCompressSize)).ServeHTTP(rr, req)
+
+			if rr.Code != tc.expectedStatus {
+				t.Errorf("Expected status %d, got %d", tc.expectedStatus, rr.Code)
+			}
+			encoding := rr.Header().Get("Content-Encoding")
+			if encoding != tc.expectedEncoding {
+				t.Errorf("Expected Content-Encoding %q, got %q", tc.expectedEncoding, encoding)
+			}
+			if got := rr.Header().Get("Vary"); got != "Accept-Encoding" {
+				t.Errorf("Expected Vary Accept-Encoding, got %q", got)
+			}
+
+			body := rr.Body.String()
+			if encoding == "gzip" {
+				if rr.Body.Len() >= len(tc.expectedBody) {
+					t.Errorf("Expected the body to shrink, got %d bytes", rr.Body.Len())
+				}
+				body = gunzip(t, rr.Body.Bytes())
+			}
+			if body != tc.expectedBody {
+				t.Errorf("Expected body %q, got %q", tc.expectedBody, body)
+			}
+		})
+	}
+}
+
+func TestCompressEncodings(t *testing.T) {
+	// identityEncoding stands in for a coding such as zstd that the server may prefer.
+	identityEncoding := Encoding{
+		Name: "zstd",
+		NewWriter: func(w io.Writer) io.WriteCloser {
+			return nopWriteCloser{w}
+		},
+	}
+	handler := Chain(func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, strings.Repeat("x", 2000))
+	}, Compress(DefaultMinCompressSize, identityEncoding, Gzip))
+
+	testCases := []struct {
+		acceptEncoding string
+		expected       string
+	}{
+		{"gzip, zstd", "zstd"},
+		{"gzip;q=1.0, zstd;q=0.8", "gzip"},
+		{"br", ""},
+		{"gzip;q=invalid, zstd", "zstd"},
+	}
+
+	for _, tc := range testCases {
+		req := httptest.NewRequest(http.MethodGet, "/uld", nil)
+		req.Header.Set("Accept-Encoding", tc.acceptEncoding)
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+
+		if got := rr.Header().Get("Content-Encoding"); got != tc.expected {
+			t.Errorf("Accept-Encoding %q: expected %q, got %q", tc.acceptEncoding, tc.expected, got)
+		}
+	}
+}
+
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error { return nil }
+
+func TestCompressRequestBody(t *testing.T) {
+	payload := `[{"uld_number":"AKE12345DL"}]`
+
+	var compressed bytes.Buffer
+	gz := gzip.NewWriter(&compressed)
+	gz.Write([]byte(payload))
+	gz.Close()
+
+	testCases := []struct {
+		name            string
+		contentEncoding string
+		body            []byte
+		expectedStatus  int
+		expectedBody    string
+	}{
+		{"Gzip", "gzip", compressed.Bytes(), http.StatusOK, payload},
+		{"Identity", "", []byte(payload), http.StatusOK, payload},
+		{"Corrupt Gzip", "gzip", []byte(payload), http.StatusBadRequest, ""},
+		{"Unsupported", "compress", []byte(payload), http.StatusUnsupportedMediaType, ""},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var got string
+			handler := Chain(func(w http.ResponseWriter, r *http.Request) {
+				b, err := io.ReadAll(r.Body)
+				if err != nil {
+					t.Errorf("Failed to read body: %v", err)
+				}
+				if r.Header.Get("Content-Encoding") != "" {
+					t.Errorf("Expected Content-Encoding to be removed")
+				}
+				got = string(b)
+			}, Compress(DefaultMinCompressSize))
+
+			req := httptest.NewRequest(http.MethodPost, "/uld", bytes.NewReader(tc.body))
+			if tc.contentEncoding != "" {
+				req.Header.Set("Content-Encoding", tc.contentEncoding)
+			}
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+
+			if rr.Code != tc.expectedStatus {
+				t.Errorf("Expected status %d, got %d", tc.expectedStatus, rr.Code)
+			}
+			if got != tc.expectedBody {
+				t.Errorf("Expected body %q, got %q", tc.expectedBody, got)
+			}
+		})
+	}
+}
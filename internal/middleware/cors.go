@@ -0,0 +1,163 @@
+package middleware
+
+import (
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CORSConfig lists what cross-origin callers may do. Empty method and header lists fall back
+// to DefaultCORSMethods, DefaultCORSHeaders and DefaultCORSExposedHeaders.
+type CORSConfig struct {
+	// AllowedOrigins holds exact origins such as "http://localhost:8081", origins with a
+	// wildcard subdomain such as "https://*.example.com", or "*" for any origin. Browsers
+	// refuse credentialed responses to "*", so it must not be combined with AllowCredentials.
+	AllowedOrigins   []string
+	AllowedMethods   []string
+	AllowedHeaders   []string
+	ExposedHeaders   []string
+	AllowCredentials bool
+	// MaxAge is how long browsers may cache a preflight response; zero leaves it to them.
+	MaxAge time.Duration
+}
+
+var (
+	DefaultCORSMethods = []string{
+		http.MethodGet, http.MethodPost, http.MethodPatch, http.MethodDelete,
+	}
+	DefaultCORSHeaders = []string{
+		"Authorization", "Content-Type", "If-Match", "X-API-Key", RequestIDHeader, "traceparent",
+		IdempotencyKeyHeader,
+	}
+	DefaultCORSExposedHeaders = []string{
+		"ETag", RequestIDHeader, "Retry-After", "Idempotent-Replayed",
+		"RateLimit-Limit", "RateLimit-Remaining", "RateLimit-Reset", "RateLimit-Policy",
+	}
+)
+
+// CORS answers cross-origin requests from the configured origins. Preflight requests are
+// answered for every pattern registered on routes, allowing the configured methods that the
+// requested path has a route for; preflights for paths without one are refused.
+// It must run before any middleware that rejects requests, as preflights carry no credentials.
+func CORS(cfg CORSConfig, routes *http.ServeMux) Middleware {
+	if len(cfg.AllowedMethods) == 0 {
+		cfg.AllowedMethods = DefaultCORSMethods
+	}
+	if len(cfg.AllowedHeaders) == 0 {
+		cfg.AllowedHeaders = DefaultCORSHeaders
+	}
+	if len(cfg.ExposedHeaders) == 0 {
+		cfg.ExposedHeaders = DefaultCORSExposedHeaders
+	}
+	allowedHeaders := make(map[string]bool, len(cfg.AllowedHeaders))
+	for _, h := range cfg.AllowedHeaders {
+		allowedHeaders[http.CanonicalHeaderKey(h)] = true
+	}
+	exposed := strings.Join(cfg.ExposedHeaders, ", ")
+
+	return func(next http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			origin := r.Header.Get("Origin")
+			if origin == "" {
+				next(w, r)
+				return
+			}
+
+			h := w.Header()
+			h.Add("Vary", "Origin")
+
+			preflight := r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != ""
+			if preflight {
+				h.Add("Vary", "Access-Control-Request-Method")
+				h.Add("Vary", "Access-Control-Request-Headers")
+			}
+
+			if !cfg.allowOrigin(origin) {
+				if preflight {
+					w.WriteHeader(http.StatusNoContent)
+					return
+				}
+				next(w, r)
+				return
+			}
+
+			allowOrigin := origin
+			if slices.Contains(cfg.AllowedOrigins, "*") {
+				allowOrigin = "*"
+			}
+			h.Set("Access-Control-Allow-Origin", allowOrigin)
+			if cfg.AllowCredentials {
+				h.Set("Access-Control-Allow-Credentials", "true")
+			}
+
+			if !preflight {
+				h.Set("Access-Control-Expose-Headers", exposed)
+				next(w, r)
+				return
+			}
+
+			methods := routeMethods(routes, r, cfg.AllowedMethods)
+			if len(methods) == 0 {
+				http.NotFound(w, r)
+				return
+			}
+
+			// A refused preflight is answered without the allow headers, which the browser
+			// reports as a CORS error.
+			refuse := func() {
+				h.Del("Access-Control-Allow-Origin")
+				h.Del("Access-Control-Allow-Credentials")
+				w.WriteHeader(http.StatusNoContent)
+			}
+			if !slices.Contains(methods, r.Header.Get("Access-Control-Request-Method")) {
+				refuse()
+				return
+			}
+			requested := r.Header.Get("Access-Control-Request-Headers")
+			for _, name := range strings.Split(requested, ",") {
+				name = http.CanonicalHeaderKey(strings.TrimSpace(name))
+				if name != "" && !allowedHeaders[name] {
+					refuse()
+					return
+				}
+			}
+
+			h.Set("Access-Control-Allow-Methods", strings.Join(methods, ", "))
+			if requested != "" {
+				h.Set("Access-Control-Allow-Headers", strings.Join(cfg.AllowedHeaders, ", "))
+			}
+			if cfg.MaxAge > 0 {
+				h.Set("Access-Control-Max-Age", strconv.Itoa(int(cfg.MaxAge.Seconds())))
+			}
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}
+}
+
+func (cfg CORSConfig) allowOrigin(origin string) bool {
+	for _, allowed := range cfg.AllowedOrigins {
+		if allowed == "*" || strings.EqualFold(allowed, origin) {
+			return true
+		}
+		scheme, host, ok := strings.Cut(allowed, "://*.")
+		if ok && strings.HasPrefix(origin, scheme+"://") && strings.HasSuffix(origin, "."+host) {
+			return true
+		}
+	}
+	return false
+}
+
+// routeMethods returns the methods among candidates that routes has a pattern for at r's path.
+func routeMethods(routes *http.ServeMux, r *http.Request, candidates []string) []string {
+	methods := []string{}
+	for _, method := range candidates {
+		probe := r.Clone(r.Context())
+		probe.Method = method
+		if _, pattern := routes.Handler(probe); pattern != "" {
+			methods = append(methods, method)
+		}
+	}
+	return methods
+}
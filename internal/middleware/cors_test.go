@@ -0,0 +1,184 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestCORS(t *testing.T) {
+	mux := http.NewServeMux()
+	ok := func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}
+	mux.HandleFunc("GET /uld/{id}", ok)
+	mux.HandleFunc("DELETE /uld/{id}", ok)
+	mux.HandleFunc("PATCH /uld/{id}/status", ok)
+
+	cfg := CORSConfig{
+		AllowedOrigins:   []string{"http://localhost:8081", "https://*.example.com"},
+		AllowCredentials: true,
+		MaxAge:           10 * time.Minute,
+	}
+	handler := Chain(mux.ServeHTTP, CORS(cfg, mux))
+
+	testCases := []struct {
+		name             string
+		method           string
+		path             string
+		origin           string
+		requestMethod    string
+		requestHeaders   string
+		expectedStatus   int
+		expectedOrigin   string
+		expectedMethods  string
+		expectedMaxAge   string
+		expectedExposure bool
+	}{
+		{
+			name:             "Simple Request",
+			method:           http.MethodGet,
+			path:             "/uld/1",
+			origin:           "http://localhost:8081",
+			expectedStatus:   http.StatusOK,
+			expectedOrigin:   "http://localhost:8081",
+			expectedExposure: true,
+		},
+		{
+			name:             "Wildcard Subdomain",
+			method:           http.MethodGet,
+			path:             "/uld/1",
+			origin:           "https://dashboard.example.com",
+			expectedStatus:   http.StatusOK,
+			expectedOrigin:   "https://dashboard.example.com",
+			expectedExposure: true,
+		},
+		{
+			name:           "Disallowed Origin",
+			method:         http.MethodGet,
+			path:           "/uld/1",
+			origin:         "https://example.com.evil.test",
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "No Origin",
+			method:         http.MethodGet,
+			path:           "/uld/1",
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:            "Preflight",
+			method:          http.MethodOptions,
+			path:            "/uld/1",
+			origin:          "http://localhost:8081",
+			requestMethod:   http.MethodDelete,
+			requestHeaders:  "authorization, content-type",
+			expectedStatus:  http.StatusNoContent,
+			expectedOrigin:  "http://localhost:8081",
+			expectedMethods: "GET, DELETE",
+			expectedMaxAge:  "600",
+		},
+		{
+			name:            "Preflight Other Route",
+			method:          http.MethodOptions,
+			path:            "/uld/1/status",
+			origin:          "http://localhost:8081",
+			requestMethod:   http.MethodPatch,
+			expectedStatus:  http.StatusNoContent,
+			expectedOrigin:  "http://localhost:8081",
+			expectedMethods: "PATCH",
+			expectedMaxAge:  "600",
+		},
+		{
+			name:           "Preflight Method Without Route",
+			method:         http.MethodOptions,
+			path:           "/uld/1/status",
+			origin:         "http://localhost:8081",
+			requestMethod:  http.MethodDelete,
+			expectedStatus: http.StatusNoContent,
+		},
+		{
+			name:           "Preflight Disallowed Header",
+			method:         http.MethodOptions,
+			path:           "/uld/1",
+			origin:         "http://localhost:8081",
+			requestMethod:  http.MethodGet,
+			requestHeaders: "X-Internal",
+			expectedStatus: http.StatusNoContent,
+		},
+		{
+			name:           "Preflight Unknown Path",
+			method:         http.MethodOptions,
+			path:           "/nowhere",
+			origin:         "http://localhost:8081",
+			requestMethod:  http.MethodGet,
+			expectedStatus: http.StatusNotFound,
+			expectedOrigin: "http://localhost:8081",
+		},
+		{
+			name:           "Preflight Disallowed Origin",
+			method:         http.MethodOptions,
+			path:           "/uld/1",
+			origin:         "http://localhost:3001",
+			requestMethod:  http.MethodGet,
+			expectedStatus: http.StatusNoContent,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(tc.method, tc.path, nil)
+			if tc.origin != "" {
+				req.Header.Set("Origin", tc.origin)
+			}
+			if tc.requestMethod != "" {
+				req.Header.Set("Access-Control-Request-Method", tc.requestMethod)
+			}
+			if tc.requestHeaders != "" {
+				req.Header.Set("Access-Control-Request-Headers", tc.requestHeaders)
+			}
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+
+			h := rr.Header()
+			if rr.Code != tc.expectedStatus {
+				t.Errorf("Expected status %d, got %d", tc.expectedStatus, rr.Code)
+			}
+			if got := h.Get("Access-Control-Allow-Origin"); got != tc.expectedOrigin {
+				t.Errorf("Expected Access-Control-Allow-Origin %q, got %q", tc.expectedOrigin, got)
+			}
+			if got := h.Get("Access-Control-Allow-Methods"); got != tc.expectedMethods {
+				t.Errorf("Expected Access-Control-Allow-Methods %q, got %q", tc.expectedMethods, got)
+			}
+			if got := h.Get("Access-Control-Max-Age"); got != tc.expectedMaxAge {
+				t.Errorf("Expected Access-Control-Max-Age %q, got %q", tc.expectedMaxAge, got)
+			}
+			if got := h.Get("Access-Control-Expose-Headers") != ""; got != tc.expectedExposure {
+				t.Errorf("Expected exposed headers %v, got %q", tc.expectedExposure, h.Get("Access-Control-Expose-Headers"))
+			}
+			if tc.expectedOrigin != "" && h.Get("Access-Control-Allow-Credentials") != "true" {
+				t.Errorf("Expected credentials to be allowed")
+			}
+			if tc.origin != "" && h.Get("Vary") != "Origin" {
+				t.Errorf("Expected responses to vary by Origin, got %v", h.Values("Vary"))
+			}
+		})
+	}
+}
+
+func TestCORSAnyOrigin(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /uld", func(w http.ResponseWriter, r *http.Request) {})
+
+	handler := Chain(mux.ServeHTTP, CORS(CORSConfig{AllowedOrigins: []string{"*"}}, mux))
+
+	req := httptest.NewRequest(http.MethodGet, "/uld", nil)
+	req.Header.Set("Origin", "https://anywhere.test")
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	if got := rr.Header().Get("Access-Control-Allow-Origin"); got != "*" {
+		t.Errorf("Expected Access-Control-Allow-Origin %q, got %q", "*", got)
+	}
+}
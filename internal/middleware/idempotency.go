@@ -0,0 +1,192 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/kevin-griley/api/internal/data"
+)
+
+// IdempotencyKeyHeader names the header clients send to make a request safe to retry.
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+// maxIdempotentBody bounds the request bodies read to fingerprint a request.
+const maxIdempotentBody = 10 << 20
+
+// replayedHeaders lists the response headers stored and replayed with a response. Others,
+// such as X-Request-ID, belong to the request that is answering.
+var replayedHeaders = []string{"Content-Type", "Location", "ETag"}
+
+// Idempotent makes a route safe to retry with an Idempotency-Key header. The first response
+// to a key is stored for ttl, keyed by the authenticated user and the key, and replayed to
+// requests repeating it. Reusing a key for a different request is rejected with 422, and
+// a retry arriving while the first request is still running gets 409. A request that never
+// finishes, because the server crashed or was redeployed, holds its key only for
+// data.DefaultIdempotencyLease. Requests without the header run as usual.
+//
+// Unauthenticated routes share one key space, so clients should use random keys such as
+// UUIDs. Responses with a 5xx status are not stored, so the request can be retried.
+// It must run after JwtAuthMiddleware on authenticated routes.
+func Idempotent(ttl time.Duration) Middleware {
+	return func(next http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			key := r.Header.Get(IdempotencyKeyHeader)
+			if key == "" {
+				next(w, r)
+				return
+			}
+			// Keys follow the rules of request IDs: short printable ASCII without spaces.
+			if !validRequestID(key) {
+				invalidIdempotencyKey(w)
+				return
+			}
+
+			ctx := r.Context()
+			store, ok := data.GetStore(ctx)
+			if !ok {
+				InternalServerError(w)
+				return
+			}
+
+			body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxIdempotentBody))
+			r.Body.Close()
+			if err != nil {
+				InvalidRequestBody(w)
+				return
+			}
+			r.Body = io.NopCloser(bytes.NewReader(body))
+
+			owner := ""
+			if userID, ok := GetUserID(ctx); ok {
+				owner = userID.String()
+			}
+			hash := requestHash(r, body)
+
+			record, claimed, err := store.Idempotency.Claim(ctx, owner, key, hash, data.DefaultIdempotencyLease, ttl)
+			if err != nil {
+				slog.Error("Idempotent", "Claim", err)
+				InternalServerError(w)
+				return
+			}
+
+			if !claimed {
+				switch {
+				case record.RequestHash != hash:
+					idempotencyKeyReused(w)
+				case !record.Completed():
+					w.Header().Set("Retry-After", "1")
+					idempotentRequestInProgress(w)
+				default:
+					replay(w, record)
+				}
+				return
+			}
+
+			rec := &recordingWriter{ResponseWriter: w}
+			completed := false
+			defer func() {
+				if completed {
+					return
+				}
+				// A panic or a server error leaves nothing to replay, so the key is freed
+				// for the retry.
+				if err := store.Idempotency.Release(context.WithoutCancel(ctx), owner, key); err != nil {
+					slog.Error("Idempotent", "Release", err)
+				}
+			}()
+
+			next(rec, r)
+
+			status := rec.status
+			if status == 0 {
+				status = http.StatusOK
+			}
+			if status >= 500 {
+				return
+			}
+
+			header := data.StoredHeader{}
+			for _, name := range replayedHeaders {
+				if v := w.Header().Values(name); len(v) > 0 {
+					header[name] = v
+				}
+			}
+			if err := store.Idempotency.Complete(context.WithoutCancel(ctx), owner, key, status, header, rec.body.Bytes()); err != nil {
+				slog.Error("Idempotent", "Complete", err)
+				return
+			}
+			completed = true
+		}
+	}
+}
+
+// requestHash fingerprints the parts of a request a retry must repeat.
+func requestHash(r *http.Request, body []byte) string {
+	h := sha256.New()
+	io.WriteString(h, r.Method+" "+r.URL.RequestURI()+"\n")
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func replay(w http.ResponseWriter, record *data.IdempotencyKey) {
+	h := w.Header()
+	for name, values := range record.Header {
+		h[name] = values
+	}
+	h.Set("Idempotent-Replayed", "true")
+	h.Set("Content-Length", strconv.Itoa(len(record.Body)))
+	w.WriteHeader(record.Status)
+	w.Write(record.Body)
+}
+
+// recordingWriter passes the response on while keeping a copy of its status and body.
+type recordingWriter struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (w *recordingWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *recordingWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer.
+func (w *recordingWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+func invalidIdempotencyKey(w http.ResponseWriter) {
+	w.Header().Add("Content-Type", "application/json")
+	w.WriteHeader(http.StatusBadRequest)
+	w.Write([]byte(`{"status":400,"error":"Invalid Idempotency-Key"}`))
+}
+
+func idempotencyKeyReused(w http.ResponseWriter) {
+	w.Header().Add("Content-Type", "application/json")
+	w.WriteHeader(http.StatusUnprocessableEntity)
+	w.Write([]byte(`{"status":422,"error":"Idempotency-Key was used for a different request"}`))
+}
+
+func idempotentRequestInProgress(w http.ResponseWriter) {
+	w.Header().Add("Content-Type", "application/json")
+	w.WriteHeader(http.StatusConflict)
+	w.Write([]byte(`{"status":409,"error":"A request with this Idempotency-Key is in progress"}`))
+}
@@ -0,0 +1,118 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/kevin-griley/api/internal/data"
+)
+
+func TestIdempotent(t *testing.T) {
+	calls := 0
+	status := http.StatusCreated
+	store := data.NewMemoryStore()
+	handler := Chain(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.Header().Set("Location", "/organization/1")
+		w.Header().Set(RequestIDHeader, "req-"+strings.Repeat("x", calls))
+		writeJSON(w, status, map[string]int{"call": calls})
+	}, StoreMiddleware(store), Idempotent(time.Hour))
+
+	send := func(key, body string, userID uuid.UUID) *httptest.ResponseRecorder {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodPost, "/organization", strings.NewReader(body))
+		if key != "" {
+			req.Header.Set(IdempotencyKeyHeader, key)
+		}
+		if userID != uuid.Nil {
+			req = req.WithContext(withUserID(req.Context(), userID))
+		}
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr
+	}
+
+	user := uuid.New()
+
+	t.Run("Replay", func(t *testing.T) {
+		first := send("create-1", `{"name":"a"}`, user)
+		retry := send("create-1", `{"name":"a"}`, user)
+
+		if calls != 1 {
+			t.Fatalf("Expected the handler to run once, ran %d times", calls)
+		}
+		if retry.Code != http.StatusCreated || retry.Body.String() != first.Body.String() {
+			t.Errorf("Expected the first response to be replayed, got %d %q", retry.Code, retry.Body.String())
+		}
+		if retry.Header().Get("Idempotent-Replayed") != "true" {
+			t.Errorf("Expected the replay to be marked")
+		}
+		if retry.Header().Get("Location") != "/organization/1" {
+			t.Errorf("Expected Location to be replayed, got %q", retry.Header().Get("Location"))
+		}
+		if retry.Header().Get(RequestIDHeader) != "" {
+			t.Errorf("Expected the request id not to be replayed")
+		}
+	})
+
+	t.Run("Different Body", func(t *testing.T) {
+		rr := send("create-1", `{"name":"b"}`, user)
+		if rr.Code != http.StatusUnprocessableEntity {
+			t.Errorf("Expected status 422, got %d", rr.Code)
+		}
+	})
+
+	t.Run("Other User", func(t *testing.T) {
+		before := calls
+		rr := send("create-1", `{"name":"b"}`, uuid.New())
+		if rr.Code != http.StatusCreated || calls != before+1 {
+			t.Errorf("Expected another user's key to run the handler, got %d", rr.Code)
+		}
+	})
+
+	t.Run("Server Error", func(t *testing.T) {
+		status = http.StatusInternalServerError
+		before := calls
+		send("create-2", `{"name":"c"}`, user)
+
+		status = http.StatusCreated
+		rr := send("create-2", `{"name":"c"}`, user)
+		if rr.Code != http.StatusCreated || calls != before+2 {
+			t.Errorf("Expected a failed request to be retried, got %d after %d calls", rr.Code, calls-before)
+		}
+	})
+
+	t.Run("Abandoned Request", func(t *testing.T) {
+		// A request that claimed the key and then died with the server, its lease long gone.
+		probe := httptest.NewRequest(http.MethodPost, "/organization", strings.NewReader(`{"name":"d"}`))
+		hash := requestHash(probe, []byte(`{"name":"d"}`))
+		store.Idempotency.Claim(context.Background(), user.String(), "create-3", hash, -time.Second, time.Hour)
+
+		before := calls
+		rr := send("create-3", `{"name":"d"}`, user)
+		if rr.Code != http.StatusCreated || calls != before+1 {
+			t.Errorf("Expected the retry to take the key over, got %d", rr.Code)
+		}
+	})
+
+	t.Run("Invalid Key", func(t *testing.T) {
+		rr := send("has spaces", `{}`, user)
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("Expected status 400, got %d", rr.Code)
+		}
+	})
+
+	t.Run("No Key", func(t *testing.T) {
+		before := calls
+		send("", `{}`, user)
+		send("", `{}`, user)
+		if calls != before+2 {
+			t.Errorf("Expected requests without a key to always run, ran %d times", calls-before)
+		}
+	})
+}
@@ -2,30 +2,134 @@ package middleware
 
 import (
 	"context"
+	"fmt"
+	"io"
+	"log/slog"
 	"net/http"
+	"strings"
+	"time"
 
 	"github.com/google/uuid"
+	"github.com/kevin-griley/api/internal/data"
 )
 
 var contextKeyRequestID ContextKey = "contextKeyRequestID"
+var contextKeyAccessLog ContextKey = "contextKeyAccessLog"
+
+// RequestIDHeader carries the request ID in both directions.
+const RequestIDHeader = "X-Request-ID"
+
+// maxRequestIDLength bounds inbound request IDs, which end up in every log line.
+const maxRequestIDLength = 128
 
 func GenerateRequestID() string {
 	return uuid.New().String()
 }
 
+// accessLog collects fields set by later middleware, such as the authenticated user, for
+// the access log line LoggingMiddleware writes once the request is done.
+type accessLog struct {
+	userID uuid.UUID
+	route  string
+}
+
+// LoggingMiddleware assigns each request an ID, honouring a valid inbound X-Request-ID and
+// echoing it in the response, and writes one access log line per request once it completes.
 func LoggingMiddleware(next http.HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
 		ctx := r.Context()
+
 		reqID, ok := GetRequestID(ctx)
 		if !ok || reqID == "" {
-			reqID = GenerateRequestID()
-			ctx := withRequestID(ctx, reqID)
-			r = r.WithContext(ctx)
+			reqID = r.Header.Get(RequestIDHeader)
+			if !validRequestID(reqID) {
+				reqID = GenerateRequestID()
+			}
+			ctx = withRequestID(ctx, reqID)
+		}
+		w.Header().Set(RequestIDHeader, reqID)
+
+		fields := &accessLog{}
+		ctx = context.WithValue(ctx, contextKeyAccessLog, fields)
+
+		rw := &responseWriter{ResponseWriter: w}
+		r = r.WithContext(data.WithRequestID(ctx, reqID))
+		next(rw, r)
+
+		status := rw.status
+		if !rw.wroteHeader {
+			status = http.StatusOK
 		}
+
+		level := slog.LevelInfo
+		switch {
+		case status >= 500:
+			level = slog.LevelError
+		case status >= 400:
+			level = slog.LevelWarn
+		}
+
+		attrs := []slog.Attr{
+			slog.String("method", r.Method),
+			slog.String("route", routePattern(r)),
+			slog.Int("status", status),
+			slog.Duration("latency", time.Since(start)),
+			slog.Int("bytes", rw.bytes),
+			slog.String("requestID", reqID),
+		}
+		if fields.userID != uuid.Nil {
+			attrs = append(attrs, slog.String("userID", fields.userID.String()))
+		}
+		slog.LogAttrs(ctx, level, "Request", attrs...)
+	}
+}
+
+// validRequestID accepts short IDs of printable ASCII without spaces, so that a client
+// cannot inject arbitrary text into the logs.
+func validRequestID(reqID string) bool {
+	if reqID == "" || len(reqID) > maxRequestIDLength {
+		return false
+	}
+	for _, c := range reqID {
+		if c <= ' ' || c > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+// RouteMiddleware records the pattern of the route ServeMux matched for the access log and
+// metrics. ServeMux sets the pattern only on the request it is passed, which middleware
+// further out never see once a later one calls r.WithContext, so this must be the last
+// middleware before the mux. The pattern is recorded in a defer, so that a panicking handler
+// is still attributed to its route by the time RecoverMiddleware recovers.
+func RouteMiddleware(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		defer func() {
+			if fields, ok := r.Context().Value(contextKeyAccessLog).(*accessLog); ok {
+				fields.route = r.Pattern
+			}
+		}()
 		next(w, r)
 	}
 }
 
+// routePattern returns the pattern recorded by RouteMiddleware, falling back to r's own.
+func routePattern(r *http.Request) string {
+	if fields, ok := r.Context().Value(contextKeyAccessLog).(*accessLog); ok && fields.route != "" {
+		return fields.route
+	}
+	return r.Pattern
+}
+
+// logUserID records the authenticated user for the access log line.
+func logUserID(ctx context.Context, userID uuid.UUID) {
+	if fields, ok := ctx.Value(contextKeyAccessLog).(*accessLog); ok {
+		fields.userID = userID
+	}
+}
+
 func withRequestID(ctx context.Context, reqID string) context.Context {
 	return context.WithValue(ctx, contextKeyRequestID, reqID)
 }
@@ -34,3 +138,23 @@ func GetRequestID(ctx context.Context) (string, bool) {
 	reqID, ok := ctx.Value(contextKeyRequestID).(string)
 	return reqID, ok
 }
+
+// NewLogger returns a logger writing to w in format, "json" or "text", at level, one of
+// "debug", "info", "warn" or "error". Empty values default to text at info.
+func NewLogger(w io.Writer, format, level string) (*slog.Logger, error) {
+	var lvl slog.Level
+	if level != "" {
+		if err := lvl.UnmarshalText([]byte(level)); err != nil {
+			return nil, fmt.Errorf("invalid log level: %s", level)
+		}
+	}
+	opts := &slog.HandlerOptions{Level: lvl}
+
+	switch strings.ToLower(format) {
+	case "json":
+		return slog.New(slog.NewJSONHandler(w, opts)), nil
+	case "", "text":
+		return slog.New(slog.NewTextHandler(w, opts)), nil
+	}
+	return nil, fmt.Errorf("invalid log format: %s", format)
+}
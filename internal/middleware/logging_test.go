@@ -0,0 +1,122 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+// captureLogs sends the default logger's JSON output to the returned buffer for the test.
+func captureLogs(t *testing.T) *bytes.Buffer {
+	t.Helper()
+
+	var buf bytes.Buffer
+	logger, err := NewLogger(&buf, "json", "debug")
+	if err != nil {
+		t.Fatalf("Failed to create logger: %v", err)
+	}
+
+	previous := slog.Default()
+	slog.SetDefault(logger)
+	t.Cleanup(func() { slog.SetDefault(previous) })
+
+	return &buf
+}
+
+func TestLoggingMiddleware(t *testing.T) {
+	userID := uuid.Must(uuid.NewV7())
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /uld/{id}", func(w http.ResponseWriter, r *http.Request) {
+		logUserID(r.Context(), userID)
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte("missing"))
+	})
+	handler := Chain(mux.ServeHTTP, LoggingMiddleware)
+
+	testCases := []struct {
+		name        string
+		inboundID   string
+		expectEcho  bool
+		expectLevel string
+	}{
+		{name: "Inbound ID", inboundID: "abc-123", expectEcho: true, expectLevel: "WARN"},
+		{name: "No Inbound ID", expectLevel: "WARN"},
+		{name: "Invalid Inbound ID", inboundID: "bad id\nforged=1", expectLevel: "WARN"},
+		{name: "Oversized Inbound ID", inboundID: strings.Repeat("a", 200), expectLevel: "WARN"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			logs := captureLogs(t)
+
+			req := httptest.NewRequest(http.MethodGet, "/uld/42", nil)
+			if tc.inboundID != "" {
+				req.Header.Set(RequestIDHeader, tc.inboundID)
+			}
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+
+			reqID := rr.Header().Get(RequestIDHeader)
+			if reqID == "" {
+				t.Fatalf("Expected a request ID in the response")
+			}
+			if tc.expectEcho && reqID != tc.inboundID {
+				t.Errorf("Expected inbound request ID %q to be echoed, got %q", tc.inboundID, reqID)
+			}
+			if !tc.expectEcho && reqID == tc.inboundID {
+				t.Errorf("Expected inbound request ID %q to be replaced", tc.inboundID)
+			}
+
+			var line map[string]any
+			if err := json.Unmarshal(logs.Bytes(), &line); err != nil {
+				t.Fatalf("Expected one JSON log line, got %q: %v", logs.String(), err)
+			}
+
+			expected := map[string]any{
+				"level":     tc.expectLevel,
+				"msg":       "Request",
+				"method":    "GET",
+				"route":     "GET /uld/{id}",
+				"status":    float64(http.StatusNotFound),
+				"bytes":     float64(len("missing")),
+				"requestID": reqID,
+				"userID":    userID.String(),
+			}
+			for key, want := range expected {
+				if line[key] != want {
+					t.Errorf("Expected %s=%v, got %v", key, want, line[key])
+				}
+			}
+			if _, ok := line["latency"]; !ok {
+				t.Errorf("Expected latency in %v", line)
+			}
+		})
+	}
+}
+
+func TestNewLogger(t *testing.T) {
+	testCases := []struct {
+		format, level string
+		valid         bool
+	}{
+		{"", "", true},
+		{"json", "warn", true},
+		{"TEXT", "DEBUG", true},
+		{"xml", "info", false},
+		{"json", "loud", false},
+	}
+
+	for _, tc := range testCases {
+		_, err := NewLogger(&bytes.Buffer{}, tc.format, tc.level)
+		if (err == nil) != tc.valid {
+			t.Errorf("NewLogger(%q, %q): expected valid=%v, got %v", tc.format, tc.level, tc.valid, err)
+		}
+	}
+}
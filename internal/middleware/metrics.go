@@ -0,0 +1,69 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/kevin-griley/api/internal/metrics"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	requestsTotal = metrics.Factory.NewCounterVec(prometheus.CounterOpts{
+		Name: "http_requests_total",
+		Help: "Number of HTTP requests by route pattern and status.",
+	}, []string{"route", "status"})
+	requestDuration = metrics.Factory.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "http_request_duration_seconds",
+		Help:    "HTTP request latency by route pattern and status.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"route", "status"})
+	_ = metrics.Factory.NewCounterFunc(prometheus.CounterOpts{
+		Name: "http_panics_total",
+		Help: "Number of handler panics recovered from.",
+	}, func() float64 { return float64(PanicCount()) })
+)
+
+// MetricsMiddleware counts requests and records their latency by route pattern and status.
+// Requests no route matched are recorded under the route "unmatched".
+func MetricsMiddleware(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+
+		rw := &responseWriter{ResponseWriter: w}
+		next(rw, r)
+
+		status := rw.status
+		if !rw.wroteHeader {
+			status = http.StatusOK
+		}
+
+		route := routePattern(r)
+		if route == "" {
+			route = "unmatched"
+		}
+
+		code := strconv.Itoa(status)
+		requestsTotal.WithLabelValues(route, code).Inc()
+		requestDuration.WithLabelValues(route, code).Observe(time.Since(start).Seconds())
+	}
+}
+
+// MetricsAuthMiddleware requires the bearer token to equal token. An empty token leaves
+// the route open, for deployments that keep /metrics off the public network.
+func MetricsAuthMiddleware(token string) Middleware {
+	return func(next http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			if token != "" {
+				got, err := ExtractBearerToken(r.Header.Get("Authorization"))
+				if err != nil || subtle.ConstantTimeCompare([]byte(got), []byte(token)) != 1 {
+					PermissionDenied(w)
+					return
+				}
+			}
+			next(w, r)
+		}
+	}
+}
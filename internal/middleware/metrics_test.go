@@ -0,0 +1,101 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestMetricsMiddleware(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /manifest/{id}", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	})
+
+	// The pass-through middleware replaces the request, as StoreMiddleware does, so the
+	// route has to reach the outer middleware through RouteMiddleware.
+	withContext := func(next http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			next(w, r.WithContext(r.Context()))
+		}
+	}
+	handler := Chain(mux.ServeHTTP, LoggingMiddleware, MetricsMiddleware, withContext, RouteMiddleware)
+
+	route, unmatched := "GET /manifest/{id}", "unmatched"
+	beforeRoute := testutil.ToFloat64(requestsTotal.WithLabelValues(route, "404"))
+	beforeUnmatched := testutil.ToFloat64(requestsTotal.WithLabelValues(unmatched, "404"))
+
+	for _, path := range []string{"/manifest/1", "/manifest/2", "/nowhere"} {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	if got := testutil.ToFloat64(requestsTotal.WithLabelValues(route, "404")); got != beforeRoute+2 {
+		t.Errorf("Expected %v requests for %s, got %v", beforeRoute+2, route, got)
+	}
+	if got := testutil.ToFloat64(requestsTotal.WithLabelValues(unmatched, "404")); got != beforeUnmatched+1 {
+		t.Errorf("Expected %v unmatched requests, got %v", beforeUnmatched+1, got)
+	}
+}
+
+func TestMetricsMiddlewarePanic(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /panic/{id}", func(w http.ResponseWriter, r *http.Request) {
+		panic("boom")
+	})
+
+	// RecoverMiddleware sits between the metrics and the route, as it does in main.
+	withContext := func(next http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			next(w, r.WithContext(r.Context()))
+		}
+	}
+	handler := Chain(mux.ServeHTTP, LoggingMiddleware, MetricsMiddleware, RecoverMiddleware, withContext, RouteMiddleware)
+
+	route := "GET /panic/{id}"
+	before := testutil.ToFloat64(requestsTotal.WithLabelValues(route, "500"))
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/panic/1", nil))
+
+	if rr.Code != http.StatusInternalServerError {
+		t.Errorf("Expected status %d, got %d", http.StatusInternalServerError, rr.Code)
+	}
+	if got := testutil.ToFloat64(requestsTotal.WithLabelValues(route, "500")); got != before+1 {
+		t.Errorf("Expected %v panicking requests for %s, got %v", before+1, route, got)
+	}
+}
+
+func TestMetricsAuthMiddleware(t *testing.T) {
+	ok := func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}
+
+	testCases := []struct {
+		name           string
+		token          string
+		authorization  string
+		expectedStatus int
+	}{
+		{name: "Open", expectedStatus: http.StatusOK},
+		{name: "Valid Token", token: "scrape", authorization: "Bearer scrape", expectedStatus: http.StatusOK},
+		{name: "Wrong Token", token: "scrape", authorization: "Bearer guess", expectedStatus: http.StatusForbidden},
+		{name: "Missing Token", token: "scrape", expectedStatus: http.StatusForbidden},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+			if tc.authorization != "" {
+				req.Header.Set("Authorization", tc.authorization)
+			}
+			rr := httptest.NewRecorder()
+			Chain(ok, MetricsAuthMiddleware(tc.token)).ServeHTTP(rr, req)
+
+			if rr.Code != tc.expectedStatus {
+				t.Errorf("Expected status %d, got %d", tc.expectedStatus, rr.Code)
+			}
+		})
+	}
+}
@@ -0,0 +1,273 @@
+package middleware
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"math"
+	"net"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Policy is a token bucket: Burst tokens that refill at Rate per second. Each request takes
+// one token, so Burst requests may arrive at once and Rate per second are sustained.
+// Name namespaces the buckets, so routes with different policies never share one.
+type Policy struct {
+	Name  string
+	Rate  float64
+	Burst int
+}
+
+// PerMinute returns a Policy allowing n requests a minute, all of which may arrive at once.
+func PerMinute(name string, n int) Policy {
+	return Policy{Name: name, Rate: float64(n) / 60, Burst: n}
+}
+
+// PerHour returns a Policy allowing n requests an hour, all of which may arrive at once.
+func PerHour(name string, n int) Policy {
+	return Policy{Name: name, Rate: float64(n) / 3600, Burst: n}
+}
+
+// refillTime is how long an empty bucket takes to fill.
+func (p Policy) refillTime() time.Duration {
+	return time.Duration(float64(p.Burst) / p.Rate * float64(time.Second))
+}
+
+// Decision is the outcome of taking a token.
+type Decision struct {
+	Allowed bool
+	// Remaining is the number of whole tokens left in the bucket.
+	Remaining int
+	// RetryAfter is how long until the next token, when the request was not allowed.
+	RetryAfter time.Duration
+	// Reset is how long until the bucket is full again.
+	Reset time.Duration
+}
+
+// Limiter takes tokens from the bucket for key under policy.
+type Limiter interface {
+	Allow(ctx context.Context, key string, policy Policy) (Decision, error)
+}
+
+// KeyFunc identifies the client a request is limited as. It returns false when the request
+// carries no such identity, in which case RateLimit tries the next one.
+type KeyFunc func(r *http.Request) (string, bool)
+
+// KeyByIP limits by the address of the connection the request came in on. Behind a load
+// balancer that is the balancer's address, so use TrustedProxies.KeyByIP there instead.
+func KeyByIP(r *http.Request) (string, bool) {
+	return TrustedProxies(nil).KeyByIP(r)
+}
+
+// TrustedProxies are the reverse proxies whose X-Forwarded-For hops are believed.
+type TrustedProxies []netip.Prefix
+
+// KeyByIP limits by the client address ClientIP finds.
+func (p TrustedProxies) KeyByIP(r *http.Request) (string, bool) {
+	ip := p.ClientIP(r)
+	return ip, ip != ""
+}
+
+// ClientIP returns the address the request came from. When the connection is from a trusted
+// proxy, that is the right-most X-Forwarded-For hop that is not itself a trusted proxy: hops
+// to its left were added by the client or by proxies it chose, and could be forged. When
+// every hop is trusted it is the left-most one.
+func (p TrustedProxies) ClientIP(r *http.Request) string {
+	remote := r.RemoteAddr
+	if host, _, err := net.SplitHostPort(remote); err == nil {
+		remote = host
+	}
+	if !p.trusts(remote) {
+		return remote
+	}
+
+	var hops []string
+	for _, header := range r.Header.Values("X-Forwarded-For") {
+		hops = append(hops, strings.Split(header, ",")...)
+	}
+
+	client := remote
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := strings.TrimSpace(hops[i])
+		if _, err := netip.ParseAddr(hop); err != nil {
+			// A hop that is no address cannot be trusted or followed; the proxy that
+			// passed it on is the last address known.
+			break
+		}
+		client = hop
+		if !p.trusts(hop) {
+			break
+		}
+	}
+	return client
+}
+
+func (p TrustedProxies) trusts(ip string) bool {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return false
+	}
+	addr = addr.Unmap()
+	for _, prefix := range p {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// KeyByUser limits by the authenticated user, so it must run after JwtAuthMiddleware.
+func KeyByUser(r *http.Request) (string, bool) {
+	userID, ok := GetUserID(r.Context())
+	if !ok {
+		return "", false
+	}
+	return "user:" + userID.String(), true
+}
+
+// KeyByAPIKey limits by the X-API-Key header. The key is hashed so it is never stored.
+func KeyByAPIKey(r *http.Request) (string, bool) {
+	apiKey := r.Header.Get("X-API-Key")
+	if apiKey == "" {
+		return "", false
+	}
+	sum := sha256.Sum256([]byte(apiKey))
+	return "key:" + hex.EncodeToString(sum[:16]), true
+}
+
+// RateLimit rejects requests beyond policy with 429 Too Many Requests. Every response
+// carries RateLimit-Limit, RateLimit-Remaining and RateLimit-Reset headers, and rejected
+// ones a Retry-After header. Requests are let through if the limiter fails, so an
+// unavailable shared backend does not take the API down with it. That fails open: while
+// a RedisLimiter cannot reach Redis, no policy is enforced at all, login throttling
+// included, and the only sign is the error logged for each request.
+//
+// Requests are limited by the first of keys that identifies them, and by the address of
+// their connection when none does.
+func RateLimit(limiter Limiter, policy Policy, keys ...KeyFunc) Middleware {
+	return func(next http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			id, ok := "", false
+			for _, key := range keys {
+				if id, ok = key(r); ok {
+					break
+				}
+			}
+			if !ok {
+				id, _ = KeyByIP(r)
+			}
+
+			decision, err := limiter.Allow(r.Context(), policy.Name+":"+id, policy)
+			if err != nil {
+				slog.Error("RateLimit", "policy", policy.Name, "error", err)
+				next(w, r)
+				return
+			}
+
+			h := w.Header()
+			h.Set("RateLimit-Limit", strconv.Itoa(policy.Burst))
+			h.Set("RateLimit-Remaining", strconv.Itoa(decision.Remaining))
+			h.Set("RateLimit-Reset", strconv.Itoa(ceilSeconds(decision.Reset)))
+			h.Set("RateLimit-Policy", fmt.Sprintf("%d;w=%d", policy.Burst, ceilSeconds(policy.refillTime())))
+
+			if !decision.Allowed {
+				h.Set("Retry-After", strconv.Itoa(ceilSeconds(decision.RetryAfter)))
+				TooManyRequests(w)
+				return
+			}
+
+			next(w, r)
+		}
+	}
+}
+
+func TooManyRequests(w http.ResponseWriter) {
+	w.Header().Add("Content-Type", "application/json")
+	w.WriteHeader(http.StatusTooManyRequests)
+	w.Write([]byte(`{"status":429,"error":"Too Many Requests"}`))
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
+
+// takeToken refills a bucket holding tokens, last updated elapsed ago, and takes a token
+// from it if there is one. It returns the tokens left and the decision.
+func takeToken(tokens float64, elapsed time.Duration, policy Policy) (float64, Decision) {
+	burst := float64(policy.Burst)
+	tokens = math.Min(burst, tokens+elapsed.Seconds()*policy.Rate)
+
+	var d Decision
+	if tokens >= 1 {
+		tokens--
+		d.Allowed = true
+	} else {
+		d.RetryAfter = time.Duration((1 - tokens) / policy.Rate * float64(time.Second))
+	}
+	d.Remaining = int(tokens)
+	d.Reset = time.Duration((burst - tokens) / policy.Rate * float64(time.Second))
+	return tokens, d
+}
+
+// MemoryLimiter keeps buckets in process. Each instance of the API limits independently,
+// so a deployment of several instances should use a RedisLimiter instead.
+type MemoryLimiter struct {
+	now func() time.Time
+
+	mu        sync.Mutex
+	buckets   map[string]*memoryBucket
+	lastSweep time.Time
+}
+
+type memoryBucket struct {
+	tokens  float64
+	updated time.Time
+	full    time.Time // when the bucket will have refilled, after which it can be dropped
+}
+
+func NewMemoryLimiter() *MemoryLimiter {
+	return &MemoryLimiter{
+		now:     time.Now,
+		buckets: make(map[string]*memoryBucket),
+	}
+}
+
+func (l *MemoryLimiter) Allow(ctx context.Context, key string, policy Policy) (Decision, error) {
+	now := l.now()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.sweep(now)
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &memoryBucket{tokens: float64(policy.Burst), updated: now}
+		l.buckets[key] = b
+	}
+
+	tokens, d := takeToken(b.tokens, now.Sub(b.updated), policy)
+	b.tokens, b.updated, b.full = tokens, now, now.Add(d.Reset)
+	return d, nil
+}
+
+// sweep drops full buckets, which behave the same as missing ones, at most once a minute.
+func (l *MemoryLimiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < time.Minute {
+		return
+	}
+	l.lastSweep = now
+
+	for key, b := range l.buckets {
+		if !now.Before(b.full) {
+			delete(l.buckets, key)
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// DefaultRedisTimeout bounds each call to Redis when the request context has no deadline.
+const DefaultRedisTimeout = time.Second
+
+// tokenBucketScript is takeToken run atomically in Redis, so every instance of the API
+// shares the bucket. It uses the Redis clock rather than the instances' clocks, and expires
+// the bucket once it would have refilled. It returns whether the token was taken, the whole
+// tokens remaining, and the retry-after and reset durations in microseconds.
+const tokenBucketScript = `
+local rate = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+local t = redis.call('TIME')
+local now = tonumber(t[1]) * 1000000 + tonumber(t[2])
+
+local state = redis.call('HMGET', KEYS[1], 'tokens', 'updated')
+local tokens = tonumber(state[1]) or burst
+local updated = tonumber(state[2]) or now
+tokens = math.min(burst, tokens + math.max(0, now - updated) / 1000000 * rate)
+
+local allowed, retry = 0, 0
+if tokens >= 1 then
+	tokens = tokens - 1
+	allowed = 1
+else
+	retry = math.ceil((1 - tokens) / rate * 1000000)
+end
+local reset = math.ceil((burst - tokens) / rate * 1000000)
+
+redis.call('HSET', KEYS[1], 'tokens', tostring(tokens), 'updated', now)
+redis.call('PEXPIRE', KEYS[1], math.max(1, math.ceil(reset / 1000)))
+return {allowed, math.floor(tokens), retry, reset}
+`
+
+// tokenBucket runs tokenBucketScript by its digest, loading it on a Redis that has not seen it.
+var tokenBucket = redis.NewScript(tokenBucketScript)
+
+// RedisLimiter keeps buckets in Redis, so that every instance of the API shares them.
+type RedisLimiter struct {
+	client *redis.Client
+}
+
+// NewRedisLimiter returns a RedisLimiter for a URL of the form
+// redis://[[user]:password@]host:port[/db], or rediss:// for TLS, keeping up to poolSize
+// connections open.
+func NewRedisLimiter(rawURL string, poolSize int) (*RedisLimiter, error) {
+	opts, err := redis.ParseURL(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	opts.PoolSize = max(poolSize, 1)
+	return &RedisLimiter{client: redis.NewClient(opts)}, nil
+}
+
+func (l *RedisLimiter) Allow(ctx context.Context, key string, policy Policy) (Decision, error) {
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, DefaultRedisTimeout)
+		defer cancel()
+	}
+
+	values, err := tokenBucket.Run(ctx, l.client, []string{"ratelimit:" + key}, policy.Rate, policy.Burst).Int64Slice()
+	if err != nil {
+		return Decision{}, err
+	}
+	if len(values) != 4 {
+		return Decision{}, fmt.Errorf("unexpected redis reply: %v", values)
+	}
+
+	return Decision{
+		Allowed:    values[0] == 1,
+		Remaining:  int(values[1]),
+		RetryAfter: time.Duration(values[2]) * time.Microsecond,
+		Reset:      time.Duration(values[3]) * time.Microsecond,
+	}, nil
+}
+
+// Close closes the connections to Redis.
+func (l *RedisLimiter) Close() error {
+	return l.client.Close()
+}
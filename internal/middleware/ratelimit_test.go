@@ -0,0 +1,377 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+)
+
+type fakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+func TestMemoryLimiter(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(1_700_000_000, 0)}
+	limiter := NewMemoryLimiter()
+	limiter.now = clock.Now
+
+	ctx := context.Background()
+	policy := PerMinute("test", 3)
+
+	for i := range 3 {
+		d, _ := limiter.Allow(ctx, "a", policy)
+		if !d.Allowed || d.Remaining != 2-i {
+			t.Fatalf("Expected request %d allowed with %d remaining, got %+v", i+1, 2-i, d)
+		}
+	}
+
+	d, _ := limiter.Allow(ctx, "a", policy)
+	if d.Allowed {
+		t.Fatalf("Expected the fourth request to be limited")
+	}
+	if d.RetryAfter != 20*time.Second {
+		t.Errorf("Expected to retry after 20s, got %s", d.RetryAfter)
+	}
+
+	if d, _ := limiter.Allow(ctx, "b", policy); !d.Allowed {
+		t.Errorf("Expected another key to have its own bucket")
+	}
+
+	clock.Advance(20 * time.Second)
+	if d, _ := limiter.Allow(ctx, "a", policy); !d.Allowed {
+		t.Errorf("Expected a token after it refilled")
+	}
+
+	clock.Advance(2 * time.Minute)
+	limiter.Allow(ctx, "c", policy)
+	if _, ok := limiter.buckets["b"]; ok {
+		t.Errorf("Expected full buckets to be swept")
+	}
+}
+
+func TestRateLimit(t *testing.T) {
+	limiter := NewMemoryLimiter()
+	handler := Chain(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}, RateLimit(limiter, PerMinute("login", 2), KeyByAPIKey))
+
+	request := func(remoteAddr, apiKey string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/login", nil)
+		req.RemoteAddr = remoteAddr
+		if apiKey != "" {
+			req.Header.Set("X-API-Key", apiKey)
+		}
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr
+	}
+
+	for range 2 {
+		if rr := request("10.0.0.1:1234", ""); rr.Code != http.StatusOK {
+			t.Fatalf("Expected 200, got %d", rr.Code)
+		}
+	}
+
+	rr := request("10.0.0.1:5678", "")
+	if rr.Code != http.StatusTooManyRequests {
+		t.Fatalf("Expected 429 from the same IP, got %d", rr.Code)
+	}
+	if got := rr.Header().Get("Retry-After"); got != "30" {
+		t.Errorf("Expected Retry-After 30, got %q", got)
+	}
+	if got := rr.Header().Get("RateLimit-Limit"); got != "2" {
+		t.Errorf("Expected RateLimit-Limit 2, got %q", got)
+	}
+	if got := rr.Header().Get("RateLimit-Remaining"); got != "0" {
+		t.Errorf("Expected RateLimit-Remaining 0, got %q", got)
+	}
+	if got := rr.Header().Get("RateLimit-Policy"); got != "2;w=60" {
+		t.Errorf("Expected RateLimit-Policy 2;w=60, got %q", got)
+	}
+
+	if rr := request("10.0.0.2:1234", ""); rr.Code != http.StatusOK {
+		t.Errorf("Expected another IP to be allowed, got %d", rr.Code)
+	}
+	if rr := request("10.0.0.1:1234", "secret"); rr.Code != http.StatusOK {
+		t.Errorf("Expected an API key to be limited separately from its IP, got %d", rr.Code)
+	}
+}
+
+func TestClientIP(t *testing.T) {
+	proxies := TrustedProxies{netip.MustParsePrefix("10.0.0.0/8"), netip.MustParsePrefix("::1/128")}
+
+	testCases := []struct {
+		name         string
+		proxies      TrustedProxies
+		remoteAddr   string
+		forwardedFor []string
+		expectedIP   string
+	}{
+		{"Direct", proxies, "203.0.113.9:1234", nil, "203.0.113.9"},
+		{"Direct Ignores Forwarded For", proxies, "203.0.113.9:1234", []string{"198.51.100.1"}, "203.0.113.9"},
+		{"No Trusted Proxies", nil, "10.0.0.1:1234", []string{"198.51.100.1"}, "10.0.0.1"},
+		{"Proxied", proxies, "10.0.0.1:1234", []string{"198.51.100.1"}, "198.51.100.1"},
+		{"Forged Hops Ignored", proxies, "10.0.0.1:1234", []string{"1.2.3.4, 198.51.100.1"}, "198.51.100.1"},
+		{"Chained Proxies", proxies, "10.0.0.1:1234", []string{"1.2.3.4, 198.51.100.1, 10.0.0.2", "10.0.0.3"}, "198.51.100.1"},
+		{"Every Hop Trusted", proxies, "10.0.0.1:1234", []string{"10.0.0.2"}, "10.0.0.2"},
+		{"Invalid Hop", proxies, "10.0.0.1:1234", []string{"198.51.100.1, unknown, 10.0.0.2"}, "10.0.0.2"},
+		{"No Forwarded For", proxies, "10.0.0.1:1234", nil, "10.0.0.1"},
+		{"IPv6 Proxy", proxies, "[::1]:1234", []string{"2001:db8::1"}, "2001:db8::1"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/login", nil)
+			req.RemoteAddr = tc.remoteAddr
+			for _, v := range tc.forwardedFor {
+				req.Header.Add("X-Forwarded-For", v)
+			}
+			if got := tc.proxies.ClientIP(req); got != tc.expectedIP {
+				t.Errorf("Expected client IP %q, got %q", tc.expectedIP, got)
+			}
+		})
+	}
+}
+
+func TestRateLimitBehindProxy(t *testing.T) {
+	proxies := TrustedProxies{netip.MustParsePrefix("10.0.0.0/8")}
+	handler := Chain(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}, RateLimit(NewMemoryLimiter(), PerMinute("login", 1), proxies.KeyByIP))
+
+	request := func(forwardedFor string) int {
+		req := httptest.NewRequest(http.MethodPost, "/login", nil)
+		req.RemoteAddr = "10.0.0.1:1234"
+		req.Header.Set("X-Forwarded-For", forwardedFor)
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr.Code
+	}
+
+	if code := request("198.51.100.1"); code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", code)
+	}
+	if code := request("6.6.6.6, 198.51.100.1"); code != http.StatusTooManyRequests {
+		t.Errorf("Expected a forged hop not to escape the limit, got %d", code)
+	}
+	if code := request("198.51.100.2"); code != http.StatusOK {
+		t.Errorf("Expected another client behind the same proxy to be allowed, got %d", code)
+	}
+}
+
+// newMiniredis starts a local stand-in for Redis that needs a password and runs scripts,
+// and returns it with a URL to reach it.
+func newMiniredis(t *testing.T) (*miniredis.Miniredis, string) {
+	server := miniredis.RunT(t)
+	server.RequireAuth("hunter2")
+	return server, "redis://:hunter2@" + server.Addr() + "/0"
+}
+
+func TestRedisLimiter(t *testing.T) {
+	server, url := newMiniredis(t)
+	server.SetTime(time.Unix(1_700_000_000, 0))
+
+	ctx := context.Background()
+	policy := PerMinute("signup", 2)
+
+	// Two limiters stand in for two instances of the API sharing one Redis.
+	first, err := NewRedisLimiter(url, 2)
+	if err != nil {
+		t.Fatalf("Failed to create limiter: %v", err)
+	}
+	defer first.Close()
+	second, err := NewRedisLimiter(url, 2)
+	if err != nil {
+		t.Fatalf("Failed to create limiter: %v", err)
+	}
+	defer second.Close()
+
+	for i, limiter := range []*RedisLimiter{first, second} {
+		d, err := limiter.Allow(ctx, "10.0.0.1", policy)
+		if err != nil {
+			t.Fatalf("Allow failed: %v", err)
+		}
+		if !d.Allowed || d.Remaining != 1-i {
+			t.Fatalf("Expected request %d allowed with %d remaining, got %+v", i+1, 1-i, d)
+		}
+	}
+
+	d, err := first.Allow(ctx, "10.0.0.1", policy)
+	if err != nil {
+		t.Fatalf("Allow failed: %v", err)
+	}
+	if d.Allowed || d.RetryAfter != 30*time.Second {
+		t.Errorf("Expected the shared bucket to be empty with a 30s retry, got %+v", d)
+	}
+
+	if !server.Exists("ratelimit:10.0.0.1") {
+		t.Errorf("Expected the bucket key to be prefixed, got %v", server.Keys())
+	}
+
+	// The bucket refills by the Redis clock, not the instances'.
+	server.SetTime(time.Unix(1_700_000_030, 0))
+	if d, err := second.Allow(ctx, "10.0.0.1", policy); err != nil || !d.Allowed {
+		t.Errorf("Expected a token after 30s, got %+v, %v", d, err)
+	}
+
+	// A Redis that has forgotten the script has it loaded again.
+	first.client.ScriptFlush(ctx)
+	if _, err := first.Allow(ctx, "10.0.0.2", policy); err != nil {
+		t.Errorf("Expected the script to be reloaded, got %v", err)
+	}
+}
+
+func TestRedisLimiterErrors(t *testing.T) {
+	_, url := newMiniredis(t)
+
+	limiter, err := NewRedisLimiter(strings.Replace(url, "hunter2", "wrong", 1), 1)
+	if err != nil {
+		t.Fatalf("Failed to create limiter: %v", err)
+	}
+	defer limiter.Close()
+	if _, err := limiter.Allow(context.Background(), "k", PerMinute("p", 1)); err == nil {
+		t.Errorf("Expected an error for a wrong password")
+	}
+
+	// A failing limiter lets requests through rather than failing them.
+	handler := Chain(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}, RateLimit(limiter, PerMinute("p", 1), KeyByIP))
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/", nil))
+	if rr.Code != http.StatusOK {
+		t.Errorf("Expected requests to pass when the limiter fails, got %d", rr.Code)
+	}
+
+	for _, rawURL := range []string{"http://localhost:6379", "redis://localhost:6379/db"} {
+		if _, err := NewRedisLimiter(rawURL, 1); err == nil {
+			t.Errorf("Expected an error for %s", rawURL)
+		}
+	}
+}
+
+// tokenBucketCases pin down the token bucket. They run through takeToken, and through
+// tokenBucketScript against miniredis and against a real Redis when REDIS_URL is set, so
+// the two stay in step.
+var tokenBucketCases = []struct {
+	name       string
+	policy     Policy
+	tokens     float64 // tokens in the bucket when it was last updated; -1 for a new bucket
+	elapsed    time.Duration
+	allowed    bool
+	remaining  int
+	retryAfter time.Duration
+	reset      time.Duration
+}{
+	{"New Bucket", PerMinute("p", 2), -1, 0, true, 1, 0, 30 * time.Second},
+	{"Empty", PerMinute("p", 2), 0, 0, false, 0, 30 * time.Second, 60 * time.Second},
+	{"Partly Refilled", PerMinute("p", 2), 0, 45 * time.Second, true, 0, 0, 45 * time.Second},
+	{"Refill Capped At Burst", PerMinute("p", 2), 0, 10 * time.Minute, true, 1, 0, 30 * time.Second},
+	{"Fraction Short", PerHour("p", 20), 0.25, 0, false, 0, 135 * time.Second, 3555 * time.Second},
+}
+
+func TestTakeToken(t *testing.T) {
+	for _, tc := range tokenBucketCases {
+		t.Run(tc.name, func(t *testing.T) {
+			tokens := tc.tokens
+			if tokens < 0 {
+				tokens = float64(tc.policy.Burst)
+			}
+			_, d := takeToken(tokens, tc.elapsed, tc.policy)
+			checkDecision(t, d, tc.allowed, tc.remaining, tc.retryAfter, tc.reset, time.Millisecond)
+		})
+	}
+}
+
+// TestRedisLimiterScript runs tokenBucketScript itself, which the stand-in server does not.
+func TestRedisLimiterScript(t *testing.T) {
+	_, url := newMiniredis(t)
+	t.Run("Miniredis", func(t *testing.T) { testTokenBucketScript(t, url) })
+
+	if redisURL := os.Getenv("REDIS_URL"); redisURL != "" {
+		t.Run("Redis", func(t *testing.T) { testTokenBucketScript(t, redisURL) })
+	}
+}
+
+// testTokenBucketScript runs tokenBucketCases through RedisLimiter against the Redis at url.
+func testTokenBucketScript(t *testing.T, url string) {
+	limiter, err := NewRedisLimiter(url, 1)
+	if err != nil {
+		t.Fatalf("Failed to create limiter: %v", err)
+	}
+	defer limiter.Close()
+
+	ctx := context.Background()
+	client := limiter.client
+
+	suffix := strconv.FormatInt(time.Now().UnixNano(), 36)
+	for i, tc := range tokenBucketCases {
+		t.Run(tc.name, func(t *testing.T) {
+			key := fmt.Sprintf("script-test:%s:%d", suffix, i)
+			defer client.Del(ctx, "ratelimit:"+key)
+
+			if tc.tokens >= 0 {
+				// Seed the bucket as if it was last updated elapsed ago by the Redis clock.
+				now, err := client.Time(ctx).Result()
+				if err != nil {
+					t.Fatalf("TIME failed: %v", err)
+				}
+				updated := now.UnixMicro() - tc.elapsed.Microseconds()
+				if err := client.HSet(ctx, "ratelimit:"+key, "tokens", tc.tokens, "updated", updated).Err(); err != nil {
+					t.Fatalf("HSET failed: %v", err)
+				}
+			}
+
+			d, err := limiter.Allow(ctx, key, tc.policy)
+			if err != nil {
+				t.Fatalf("Allow failed: %v", err)
+			}
+			// The Redis clock moves on between seeding and the script, so durations get slack.
+			checkDecision(t, d, tc.allowed, tc.remaining, tc.retryAfter, tc.reset, 100*time.Millisecond)
+
+			ttl, err := client.PTTL(ctx, "ratelimit:"+key).Result()
+			if err != nil {
+				t.Fatalf("PTTL failed: %v", err)
+			}
+			if ttl <= 0 || ttl > tc.reset+time.Second {
+				t.Errorf("Expected the bucket to expire once refilled, after about %v, got %v", tc.reset, ttl)
+			}
+		})
+	}
+}
+
+func checkDecision(t *testing.T, d Decision, allowed bool, remaining int, retryAfter, reset, slack time.Duration) {
+	t.Helper()
+	if d.Allowed != allowed || d.Remaining != remaining {
+		t.Errorf("Expected allowed %v with %d remaining, got %+v", allowed, remaining, d)
+	}
+	if (d.RetryAfter - retryAfter).Abs() > slack {
+		t.Errorf("Expected retry after %v, got %v", retryAfter, d.RetryAfter)
+	}
+	if (d.Reset - reset).Abs() > slack {
+		t.Errorf("Expected reset %v, got %v", reset, d.Reset)
+	}
+}
@@ -0,0 +1,92 @@
+package middleware
+
+import (
+	"log/slog"
+	"net/http"
+	"runtime/debug"
+	"sync/atomic"
+)
+
+var panics atomic.Int64
+
+// PanicCount returns the number of handler panics RecoverMiddleware has recovered from.
+func PanicCount() int64 {
+	return panics.Load()
+}
+
+// RecoverMiddleware turns a panic in a later handler into a 500 response, logging the
+// panic and its stack with the request ID. It must run after LoggingMiddleware.
+// http.ErrAbortHandler is re-raised, since it is the way a handler asks the server to abort.
+func RecoverMiddleware(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		rw := &responseWriter{ResponseWriter: w}
+
+		defer func() {
+			p := recover()
+			if p == nil {
+				return
+			}
+			if p == http.ErrAbortHandler {
+				panic(p)
+			}
+
+			panics.Add(1)
+
+			reqID, ok := GetRequestID(r.Context())
+			if !ok {
+				reqID = "unknown"
+			}
+			slog.Error("Panic",
+				"panic", p,
+				"method", r.Method,
+				"path", r.URL.Path,
+				"requestID", reqID,
+				"stack", string(debug.Stack()),
+			)
+
+			// Once the status is sent the response cannot be replaced, and the client is
+			// left with whatever was written.
+			if !rw.wroteHeader {
+				InternalServerError(rw)
+			}
+		}()
+
+		next(rw, r)
+	}
+}
+
+func InternalServerError(w http.ResponseWriter) {
+	w.Header().Add("Content-Type", "application/json")
+	w.WriteHeader(http.StatusInternalServerError)
+	w.Write([]byte(`{"status":500,"error":"Internal Server Error"}`))
+}
+
+// responseWriter records the status and size of the response written through it.
+type responseWriter struct {
+	http.ResponseWriter
+	status      int
+	bytes       int
+	wroteHeader bool
+}
+
+func (w *responseWriter) WriteHeader(status int) {
+	if !w.wroteHeader {
+		w.status = status
+		w.wroteHeader = true
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *responseWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	n, err := w.ResponseWriter.Write(b)
+	w.bytes += n
+	return n, err
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer.
+func (w *responseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
@@ -0,0 +1,82 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRecoverMiddleware(t *testing.T) {
+	testCases := []struct {
+		name           string
+		handler        http.HandlerFunc
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			name: "Panic",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				var rows *struct{ next bool }
+				_ = rows.next
+			},
+			expectedStatus: http.StatusInternalServerError,
+			expectedBody:   `{"status":500,"error":"Internal Server Error"}`,
+		},
+		{
+			name: "Panic After Write",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusAccepted)
+				w.Write([]byte("partial"))
+				panic("boom")
+			},
+			expectedStatus: http.StatusAccepted,
+			expectedBody:   "partial",
+		},
+		{
+			name: "No Panic",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte("ok"))
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody:   "ok",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			before := PanicCount()
+
+			handler := Chain(tc.handler, LoggingMiddleware, RecoverMiddleware)
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/user/me", nil))
+
+			if rr.Code != tc.expectedStatus {
+				t.Errorf("Expected status %d, got %d", tc.expectedStatus, rr.Code)
+			}
+			if rr.Body.String() != tc.expectedBody {
+				t.Errorf("Expected body %q, got %q", tc.expectedBody, rr.Body.String())
+			}
+
+			expectedPanics := before
+			if tc.name != "No Panic" {
+				expectedPanics++
+			}
+			if got := PanicCount(); got != expectedPanics {
+				t.Errorf("Expected panic count %d, got %d", expectedPanics, got)
+			}
+		})
+	}
+}
+
+func TestRecoverMiddlewareAbort(t *testing.T) {
+	handler := Chain(func(w http.ResponseWriter, r *http.Request) {
+		panic(http.ErrAbortHandler)
+	}, RecoverMiddleware)
+
+	defer func() {
+		if p := recover(); p != http.ErrAbortHandler {
+			t.Errorf("Expected http.ErrAbortHandler to be re-raised, got %v", p)
+		}
+	}()
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+}
@@ -9,7 +9,7 @@ import (
 func StoreMiddleware(store *data.Store) func(next http.HandlerFunc) http.HandlerFunc {
 	return func(next http.HandlerFunc) http.HandlerFunc {
 		return func(w http.ResponseWriter, r *http.Request) {
-			ctx := data.WithStore(r.Context(), store)
+			ctx := data.WithReadYourWrites(data.WithStore(r.Context(), store))
 			next(w, r.WithContext(ctx))
 
 		}
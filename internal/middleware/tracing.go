@@ -0,0 +1,33 @@
+package middleware
+
+import (
+	"net/http"
+
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// TracingMiddleware starts a server span for each request with otelhttp, continuing the trace
+// of an inbound traceparent header, and carries it in the request context down to the data
+// layer. It must run after LoggingMiddleware so that the span can be named after the matched route.
+func TracingMiddleware(next http.HandlerFunc) http.HandlerFunc {
+	named := func(w http.ResponseWriter, r *http.Request) {
+		span := trace.SpanFromContext(r.Context())
+		if reqID, ok := GetRequestID(r.Context()); ok {
+			span.SetAttributes(attribute.String("request.id", reqID))
+		}
+
+		next(w, r)
+
+		if route := routePattern(r); route != "" {
+			span.SetName(route)
+			span.SetAttributes(semconv.HTTPRoute(route))
+		}
+	}
+
+	return otelhttp.NewHandler(http.HandlerFunc(named), "",
+		otelhttp.WithSpanNameFormatter(func(_ string, r *http.Request) string { return r.Method }),
+	).ServeHTTP
+}
@@ -0,0 +1,75 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+func TestTracingMiddleware(t *testing.T) {
+	rec := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(rec)))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	defer func() {
+		otel.SetTracerProvider(noop.NewTracerProvider())
+		otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator())
+	}()
+
+	var handlerSpan trace.SpanContext
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /uld/{id}", func(w http.ResponseWriter, r *http.Request) {
+		handlerSpan = trace.SpanContextFromContext(r.Context())
+		w.WriteHeader(http.StatusServiceUnavailable)
+	})
+	handler := Chain(mux.ServeHTTP, LoggingMiddleware, TracingMiddleware, RouteMiddleware)
+
+	req := httptest.NewRequest(http.MethodGet, "/uld/42", nil)
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	spans := rec.Ended()
+	if len(spans) != 1 {
+		t.Fatalf("Expected 1 span, got %d", len(spans))
+	}
+	span := spans[0]
+
+	if !handlerSpan.Equal(span.SpanContext()) {
+		t.Errorf("Expected the handler's context to carry the server span")
+	}
+	if span.SpanContext().TraceID().String() != "4bf92f3577b34da6a3ce929d0e0e4736" || span.Parent().SpanID().String() != "00f067aa0ba902b7" {
+		t.Errorf("Expected the inbound trace to be continued, got %v under %v", span.SpanContext(), span.Parent())
+	}
+	if span.Name() != "GET /uld/{id}" || span.SpanKind() != trace.SpanKindServer {
+		t.Errorf("Expected a server span named after the route, got %q", span.Name())
+	}
+	if span.Status().Code != codes.Error {
+		t.Errorf("Expected a 503 to fail the span")
+	}
+
+	attrs := map[string]any{}
+	for _, a := range span.Attributes() {
+		attrs[string(a.Key)] = a.Value.AsInterface()
+	}
+	expected := map[string]any{
+		"http.method":      "GET",
+		"http.route":       "GET /uld/{id}",
+		"http.status_code": int64(http.StatusServiceUnavailable),
+		"http.target":      "/uld/42",
+	}
+	for key, want := range expected {
+		if attrs[key] != want {
+			t.Errorf("Expected %s=%v, got %v", key, want, attrs[key])
+		}
+	}
+	if attrs["request.id"] == nil {
+		t.Errorf("Expected the request ID attribute")
+	}
+}
@@ -0,0 +1,173 @@
+package outbox
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/kevin-griley/api/internal/data"
+)
+
+const (
+	DefaultBatchSize  = 100
+	DefaultInterval   = time.Second
+	DefaultLease      = 30 * time.Second
+	DefaultMinBackoff = time.Second
+	DefaultMaxBackoff = 10 * time.Minute
+)
+
+// Sink receives published events. An event may be delivered more than once, so sinks
+// should deduplicate on the event ID.
+type Sink interface {
+	Publish(ctx context.Context, event *data.OutboxEvent) error
+}
+
+// SinkFunc adapts a function to a Sink.
+type SinkFunc func(ctx context.Context, event *data.OutboxEvent) error
+
+func (f SinkFunc) Publish(ctx context.Context, event *data.OutboxEvent) error {
+	return f(ctx, event)
+}
+
+// LogSink logs every event, which is useful until a real broker is registered.
+type LogSink struct{}
+
+func (LogSink) Publish(ctx context.Context, event *data.OutboxEvent) error {
+	slog.Info("Outbox",
+		"event", event.EventType,
+		"id", event.ID,
+		"aggregate", event.AggregateID,
+		"payload", string(event.Payload),
+	)
+	return nil
+}
+
+// Dispatcher publishes pending outbox events to every registered sink. An event counts as
+// published once all sinks accept it; otherwise it is retried with exponential backoff, and
+// later events of the same aggregate wait until it goes through.
+type Dispatcher struct {
+	store      *data.Store
+	sinks      []Sink
+	batchSize  int
+	interval   time.Duration
+	lease      time.Duration
+	minBackoff time.Duration
+	maxBackoff time.Duration
+}
+
+type Option func(*Dispatcher)
+
+func WithBatchSize(n int) Option {
+	return func(d *Dispatcher) {
+		d.batchSize = n
+	}
+}
+
+// WithInterval sets how often the dispatcher polls for pending events.
+func WithInterval(interval time.Duration) Option {
+	return func(d *Dispatcher) {
+		d.interval = interval
+	}
+}
+
+// WithLease sets how long a claimed event is held before another dispatch may retry it.
+// It should comfortably exceed the time sinks take to publish a batch.
+func WithLease(lease time.Duration) Option {
+	return func(d *Dispatcher) {
+		d.lease = lease
+	}
+}
+
+// WithBackoff sets the delay before the first retry of a failed event, which doubles on
+// every further failure up to max.
+func WithBackoff(min, max time.Duration) Option {
+	return func(d *Dispatcher) {
+		d.minBackoff = min
+		d.maxBackoff = max
+	}
+}
+
+func NewDispatcher(store *data.Store, opts ...Option) *Dispatcher {
+	d := &Dispatcher{
+		store:      store,
+		batchSize:  DefaultBatchSize,
+		interval:   DefaultInterval,
+		lease:      DefaultLease,
+		minBackoff: DefaultMinBackoff,
+		maxBackoff: DefaultMaxBackoff,
+	}
+	for _, opt := range opts {
+		opt(d)
+	}
+	return d
+}
+
+// Register adds a sink. It must be called before Run.
+func (d *Dispatcher) Register(sink Sink) {
+	d.sinks = append(d.sinks, sink)
+}
+
+// Run dispatches pending events every interval until ctx is done.
+func (d *Dispatcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(d.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := d.DispatchOnce(ctx); err != nil {
+				slog.Error("Outbox", "DispatchOnce", err)
+			}
+		}
+	}
+}
+
+// DispatchOnce claims one batch of due events and publishes it, returning how many events
+// were published.
+func (d *Dispatcher) DispatchOnce(ctx context.Context) (int, error) {
+	events, err := d.store.Outbox.ClaimPending(ctx, d.batchSize, d.lease)
+	if err != nil {
+		return 0, err
+	}
+
+	published := 0
+	for _, event := range events {
+		if err := d.publish(ctx, event); err != nil {
+			next := time.Now().Add(d.backoff(event.Attempts + 1))
+			slog.Error("Outbox", "event", event.ID, "attempt", event.Attempts+1, "error", err)
+			if err := d.store.Outbox.MarkFailed(ctx, event.ID, err, next); err != nil {
+				return published, err
+			}
+			continue
+		}
+
+		if err := d.store.Outbox.MarkPublished(ctx, event.ID); err != nil {
+			return published, err
+		}
+		published++
+	}
+
+	return published, nil
+}
+
+func (d *Dispatcher) publish(ctx context.Context, event *data.OutboxEvent) error {
+	for _, sink := range d.sinks {
+		if err := sink.Publish(ctx, event); err != nil {
+			return fmt.Errorf("%s: %w", event.EventType, err)
+		}
+	}
+	return nil
+}
+
+// backoff returns the delay before the given attempt: minBackoff doubled for every
+// earlier failure, capped at maxBackoff.
+func (d *Dispatcher) backoff(attempt int) time.Duration {
+	delay := d.minBackoff
+	for i := 1; i < attempt && delay < d.maxBackoff; i++ {
+		delay *= 2
+	}
+	return min(delay, d.maxBackoff)
+}
@@ -0,0 +1,79 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/kevin-griley/api/internal/data"
+)
+
+func TestDispatcher(t *testing.T) {
+	ctx := context.Background()
+	store := data.NewMemoryStore()
+
+	o, _ := store.Organization.CreateRequest("outbox", "1 Cargo Way", "ops@example.com", data.Warehouse)
+	org, err := store.Organization.CreateOrganization(ctx, o)
+	if err != nil {
+		t.Fatalf("Failed to create organization: %v", err)
+	}
+	u, _ := store.UldInventory.CreateRequest("AKE12345", data.UldTypeAKE, data.UldInWarehouse, org.ID, data.Warehouse, org.ID)
+	uld, err := store.UldInventory.CreateUldInventory(ctx, u)
+	if err != nil {
+		t.Fatalf("Failed to create uld: %v", err)
+	}
+
+	for _, status := range []data.UldStatus{data.UldInTransit, data.UldDelivered} {
+		if _, err := store.UldInventory.UpdateUldStatus(ctx, uld.ID, status); err != nil {
+			t.Fatalf("Failed to update uld status: %v", err)
+		}
+	}
+
+	var delivered []string
+	failures := 1
+	d := NewDispatcher(store, WithBackoff(0, 0))
+	d.Register(SinkFunc(func(ctx context.Context, event *data.OutboxEvent) error {
+		if failures > 0 {
+			failures--
+			return errors.New("sink unavailable")
+		}
+		delivered = append(delivered, string(event.Payload))
+		return nil
+	}))
+
+	// The first event fails, and the second must wait for it rather than overtake it.
+	for _, expected := range []int{0, 1, 1, 0} {
+		published, err := d.DispatchOnce(ctx)
+		if err != nil {
+			t.Fatalf("DispatchOnce failed: %v", err)
+		}
+		if published != expected {
+			t.Fatalf("Expected %d published, got %d", expected, published)
+		}
+	}
+
+	want := []string{
+		`{"uld_id":"` + uld.ID.String() + `","from":"in_warehouse","to":"in_transit"}`,
+		`{"uld_id":"` + uld.ID.String() + `","from":"in_transit","to":"delivered"}`,
+	}
+	if len(delivered) != len(want) || delivered[0] != want[0] || delivered[1] != want[1] {
+		t.Errorf("Expected %v, got %v", want, delivered)
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	d := NewDispatcher(nil, WithBackoff(time.Second, 5*time.Second))
+
+	for attempt, expected := range map[int]time.Duration{
+		1: time.Second,
+		2: 2 * time.Second,
+		3: 4 * time.Second,
+		4: 5 * time.Second,
+		9: 5 * time.Second,
+	} {
+		if got := d.backoff(attempt); got != expected {
+			t.Errorf("Attempt %d: expected %s, got %s", attempt, expected, got)
+		}
+	}
+}
@@ -363,7 +363,7 @@ export interface components {
             updated_at?: string;
         };
         /** @enum {string} */
-        'data.OrganizationType': 'Airline' | 'Carrier' | 'Warehouse';
+        'data.OrganizationType': 'airline' | 'carrier' | 'warehouse';
         'data.User': {
             created_at?: string;
             email?: string;